@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+)
+
+// command is a single aidevs subcommand
+type command struct {
+	name        string
+	description string
+	run         func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "index", description: "index documents into the local vector store", run: runIndex},
+	{name: "search", description: "semantic search in the local vector store", run: runSearch},
+	{name: "runs", description: "list recorded task runs or show one: runs list|show", run: runRuns},
+	{name: "replay", description: "resubmit the answer of a recorded run, optionally edited", run: runReplay},
+	{name: "eval", description: "score a prompt on a dataset across models", run: runEval},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: aidevs [logging flags] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+}
+
+func main() {
+	// Logging flags come before the command, the command's own flags after it
+	global := flag.NewFlagSet("aidevs", flag.ExitOnError)
+	global.Usage = func() {
+		usage()
+		fmt.Fprintln(os.Stderr, "\nLogging flags:")
+		global.PrintDefaults()
+	}
+	logOptions := logging.RegisterFlags(global)
+	global.Parse(os.Args[1:])
+
+	args := global.Args()
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		logging.Setup("aidevs-"+cmd.name, *logOptions)
+		ctx, stop := interrupt.Context(context.Background())
+		err := cmd.run(ctx, args[1:])
+		stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "aidevs %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", args[0])
+	usage()
+	os.Exit(2)
+}
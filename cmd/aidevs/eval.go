@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/eval"
+	"github.com/dawidjelenkowski/aidevs3go/internal/text"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultEvalDir = "runs/eval"
+
+func runEval(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	models := fs.String("models", "gpt-4o-mini", "comma separated models to evaluate")
+	promptFile := fs.String("prompt", "", "file with a prompt template replacing the dataset's")
+	systemFile := fs.String("system", "", "file with a system template replacing the dataset's")
+	checker := fs.String("checker", "", "checker for cases without their own: exact, regex, numeric, json or judge")
+	tolerance := fs.Float64("tolerance", 0, "largest difference the numeric checker accepts")
+	judgeModel := fs.String("judge-model", "gpt-4o", "model grading outputs for the judge checker")
+	temperature := fs.Float64("temperature", 0, "sampling temperature of the evaluated models")
+	concurrency := fs.Int("concurrency", 4, "number of cases evaluated at once")
+	reportPath := fs.String("report", "", "path of the JSON report, defaults to "+defaultEvalDir+"/<dataset>-<time>.json")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: aidevs eval [flags] <dataset.yaml|dataset.jsonl>")
+	}
+	dataset, err := eval.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// JSONL datasets hold only cases, their templates come from files
+	if *promptFile != "" {
+		if dataset.Prompt, err = readTemplate(*promptFile); err != nil {
+			return err
+		}
+	}
+	if *systemFile != "" {
+		if dataset.System, err = readTemplate(*systemFile); err != nil {
+			return err
+		}
+	}
+	if dataset.Prompt == "" {
+		return fmt.Errorf("dataset %s has no prompt, pass -prompt", dataset.Name)
+	}
+	if *checker != "" {
+		dataset.Checker = *checker
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "tolerance" {
+			dataset.Tolerance = *tolerance
+		}
+	})
+
+	runner := &eval.Runner{
+		Models:      splitList(*models),
+		Temperature: float32(*temperature),
+		Concurrency: *concurrency,
+		JudgeModel:  *judgeModel,
+	}
+	keyModels := runner.Models
+	if usesJudge(dataset) {
+		keyModels = append(keyModels, *judgeModel)
+	}
+	if runner.Complete, err = newCompleter(ctx, keyModels); err != nil {
+		return err
+	}
+
+	log.Info().Str("dataset", dataset.Name).Int("cases", len(dataset.Cases)).Strs("models", runner.Models).Msg("Evaluating")
+	report, err := runner.Run(ctx, dataset)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteTable(os.Stdout); err != nil {
+		return err
+	}
+
+	path := *reportPath
+	if path == "" {
+		path = filepath.Join(defaultEvalDir, fmt.Sprintf("%s-%s.json", dataset.Name, report.Started.Format("20060102-150405")))
+	}
+	if err := report.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("\nReport saved to %s\n", path)
+	return nil
+}
+
+// newCompleter fetches the API keys of the providers the models run on
+func newCompleter(ctx context.Context, models []string) (eval.Completer, error) {
+	var openaiKey, geminiKey string
+	for _, model := range models {
+		var err error
+		switch {
+		case text.Family(model) == text.FamilyGemini && geminiKey == "":
+			geminiKey, err = utils.GetAPIKey(ctx, "gemini-api-key")
+		case text.Family(model) != text.FamilyGemini && openaiKey == "":
+			openaiKey, err = utils.GetAPIKey(ctx, "openai-api-key")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return eval.NewCompleter(openaiKey, geminiKey), nil
+}
+
+// readTemplate reads a prompt template without the file's trailing newline
+func readTemplate(path string) (string, error) {
+	content, err := utils.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// usesJudge reports whether any case of the dataset is graded by a model
+func usesJudge(dataset *eval.Dataset) bool {
+	for _, c := range dataset.Cases {
+		if c.Checker == eval.CheckJudge || (c.Checker == "" && dataset.Checker == eval.CheckJudge) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated flag, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
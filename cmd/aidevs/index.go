@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/documents"
+	"github.com/dawidjelenkowski/aidevs3go/internal/embeddings"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vectorstore"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vision"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultIndexPath = "downloads/index.json"
+
+// keyValueFlags collects repeated key=value flags
+type keyValueFlags map[string]string
+
+func (kv keyValueFlags) String() string {
+	var pairs []string
+	for key, value := range kv {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValueFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got '%s'", value)
+	}
+	kv[key] = val
+	return nil
+}
+
+// newEmbedder creates the embedder for the provider, fetching its API key if needed
+func newEmbedder(ctx context.Context, provider string) (embeddings.Embedder, error) {
+	var apiKey string
+	switch provider {
+	case embeddings.ProviderOpenAI:
+		apiKey, _ = utils.GetAPIKey(ctx, "openai-api-key")
+	case embeddings.ProviderGemini:
+		apiKey, _ = utils.GetAPIKey(ctx, "gemini-api-key")
+	}
+	return embeddings.New(provider, apiKey)
+}
+
+// documentID identifies a document in the index; files from archives are
+// identified by the archive path and their name inside it
+func documentID(doc *documents.Document) string {
+	if doc.Archive != "" {
+		return filepath.ToSlash(doc.Archive) + "/" + doc.Name
+	}
+	return filepath.ToSlash(doc.Path)
+}
+
+func runIndex(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	tags := keyValueFlags{}
+	dir := fs.String("dir", "", "directory with documents to index (zip archives are unpacked)")
+	indexPath := fs.String("index", defaultIndexPath, "path to the index file")
+	provider := fs.String("provider", embeddings.ProviderOpenAI, "embeddings provider: openai, gemini or hash")
+	cacheDir := fs.String("cache", "downloads/index-cache", "directory for transcripts, OCR results and unpacked archives")
+	deleteID := fs.String("delete", "", "remove a document from the index instead of indexing")
+	fs.Var(tags, "tag", "metadata added to every indexed document, key=value (repeatable)")
+	fs.Parse(args)
+
+	store, err := vectorstore.Open(*indexPath)
+	if err != nil {
+		return err
+	}
+
+	if *deleteID != "" {
+		removed := store.DeleteDocument(*deleteID)
+		log.Info().Str("document", *deleteID).Int("removed", removed).Msg("Document removed from index")
+		return store.Save()
+	}
+
+	if *dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+
+	embedder, err := newEmbedder(ctx, *provider)
+	if err != nil {
+		return err
+	}
+	if err := store.CheckModel(embedder.Model()); err != nil {
+		return err
+	}
+
+	// Media files and encrypted archives only need these keys when present,
+	// so missing secrets are not fatal here
+	openaiKey, _ := utils.LookupAPIKey(ctx, "openai-api-key")
+	zipPassword, _ := utils.LookupAPIKey(ctx, "zip-password")
+
+	loader := &documents.Loader{
+		TranscribeAPIKey: openaiKey,
+		TranscribeModel:  "whisper",
+		VisionAPIKey:     openaiKey,
+		VisionProvider:   vision.ProviderOpenAI,
+		CacheDir:         *cacheDir,
+		ExpandArchives:   true,
+		ArchivePassword:  zipPassword,
+	}
+	docs, err := loader.LoadDir(ctx, *dir)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("no supported documents in '%s'", *dir)
+	}
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Text
+	}
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	for i, doc := range docs {
+		id := documentID(doc)
+		metadata := map[string]string{
+			"name": doc.Name,
+			"kind": doc.Kind,
+			"dir":  filepath.ToSlash(*dir),
+		}
+		if doc.Archive != "" {
+			metadata["archive"] = filepath.ToSlash(doc.Archive)
+		}
+		for key, value := range tags {
+			metadata[key] = value
+		}
+
+		store.DeleteDocument(id)
+		if err := store.Upsert(vectorstore.Record{
+			ID:         id,
+			DocumentID: id,
+			Text:       doc.Text,
+			Metadata:   metadata,
+			Vector:     vectors[i],
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := store.Save(); err != nil {
+		return err
+	}
+	log.Info().Int("documents", len(docs)).Int("records", store.Len()).Str("index", *indexPath).Msg("Index updated")
+	return nil
+}
+
+func runSearch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	filter := keyValueFlags{}
+	indexPath := fs.String("index", defaultIndexPath, "path to the index file")
+	provider := fs.String("provider", embeddings.ProviderOpenAI, "embeddings provider: openai, gemini or hash")
+	k := fs.Int("k", 5, "number of results")
+	fs.Var(filter, "filter", "only return records with this metadata, key=value (repeatable)")
+	fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		return fmt.Errorf("usage: aidevs search [flags] <query>")
+	}
+
+	store, err := vectorstore.Open(*indexPath)
+	if err != nil {
+		return err
+	}
+	if store.Len() == 0 {
+		return fmt.Errorf("index '%s' is empty, run aidevs index first", *indexPath)
+	}
+
+	embedder, err := newEmbedder(ctx, *provider)
+	if err != nil {
+		return err
+	}
+	if store.Model() != embedder.Model() {
+		return fmt.Errorf("index was built with '%s', search uses '%s'", store.Model(), embedder.Model())
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return err
+	}
+
+	for _, result := range store.Search(vectors[0], *k, vectorstore.Filter(filter)) {
+		snippet := strings.Join(strings.Fields(result.Text), " ")
+		if len([]rune(snippet)) > 120 {
+			snippet = string([]rune(snippet)[:120]) + "..."
+		}
+		fmt.Printf("%.4f  %s\n        %s\n", result.Score, result.ID, snippet)
+	}
+	return nil
+}
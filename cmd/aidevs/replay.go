@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+func runReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	ledgerPath := fs.String("ledger", ledger.Path(), "path to the run ledger")
+	edit := fs.Bool("edit", false, "open the answer in $EDITOR before resubmitting")
+	task := fs.String("task", "", "Centrala task to submit under, defaults to the recorded one")
+	dryRun := fs.Bool("dry-run", false, "print the answer instead of submitting it")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: aidevs replay [flags] <run-id>")
+	}
+	original, err := ledger.Find(*ledgerPath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(original.Answer) == 0 {
+		if original.AnswerSHA256 != "" {
+			return fmt.Errorf("run %s answer was too large to record, only its hash is known", original.RunID)
+		}
+		return fmt.Errorf("run %s did not submit an answer", original.RunID)
+	}
+
+	reportTask := *task
+	if reportTask == "" {
+		reportTask = original.ReportTask
+	}
+	if reportTask == "" {
+		return fmt.Errorf("run %s does not record its Centrala task, pass -task", original.RunID)
+	}
+
+	answer := []byte(original.Answer)
+	if *edit {
+		if answer, err = editAnswer(answer); err != nil {
+			return err
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%s: %s\n", reportTask, answer)
+		return nil
+	}
+
+	// The replay is a run of the original task, linked back to it
+	run := ledger.Begin(original.Task)
+	run.SetReplayOf(original.RunID)
+
+	aidevsKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
+	if err != nil {
+		return err
+	}
+	client := centrala.NewClient(aidevsKey)
+
+	resp, err := client.ReportJSON(ctx, reportTask, bytes.NewReader(answer))
+	if err != nil {
+		run.Finish(err)
+		return err
+	}
+	if err := run.Finish(nil); err != nil {
+		return err
+	}
+
+	log.Info().Str("replay_of", original.RunID).Str("run", run.ID()).Msg("Answer resubmitted")
+	fmt.Printf("%d %s\n", resp.Code, resp.Message)
+	if !resp.Success() {
+		return fmt.Errorf("answer rejected with code %d", resp.Code)
+	}
+	return nil
+}
+
+// editAnswer opens the answer in $EDITOR and returns the edited JSON
+func editAnswer(answer []byte) ([]byte, error) {
+	var formatted bytes.Buffer
+	if err := json.Indent(&formatted, answer, "", "  "); err != nil {
+		return nil, fmt.Errorf("recorded answer is not valid JSON: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "aidevs-answer-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(append(formatted.Bytes(), '\n')); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	file.Close()
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// EDITOR may carry arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edited answer: %w", err)
+	}
+	edited = bytes.TrimSpace(edited)
+	if len(edited) == 0 {
+		return nil, fmt.Errorf("edited answer is empty")
+	}
+	if !json.Valid(edited) {
+		return nil, fmt.Errorf("edited answer is not valid JSON")
+	}
+	return edited, nil
+}
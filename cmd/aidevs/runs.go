@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+)
+
+func runRuns(_ context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: aidevs runs list|show [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runRunsList(args[1:])
+	case "show":
+		return runRunsShow(args[1:])
+	default:
+		return fmt.Errorf("unknown runs command '%s', use list or show", args[0])
+	}
+}
+
+func runRunsList(args []string) error {
+	fs := flag.NewFlagSet("runs list", flag.ExitOnError)
+	ledgerPath := fs.String("ledger", ledger.Path(), "path to the run ledger")
+	task := fs.String("task", "", "only list runs of this task")
+	limit := fs.Int("n", 20, "number of most recent runs to list, 0 for all")
+	fs.Parse(args)
+
+	records, err := ledger.Load(*ledgerPath)
+	if err != nil {
+		return err
+	}
+
+	var selected []ledger.Record
+	for _, record := range records {
+		if *task == "" || record.Task == *task {
+			selected = append(selected, record)
+		}
+	}
+	if *limit > 0 && len(selected) > *limit {
+		selected = selected[len(selected)-*limit:]
+	}
+	if len(selected) == 0 {
+		fmt.Println("No runs recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tTASK\tSTARTED\tDURATION\tCALLS\tCOST\tCODE\tRESULT")
+	for _, record := range selected {
+		code := "-"
+		if record.Code != nil {
+			code = fmt.Sprint(*record.Code)
+		}
+		result := record.Message
+		if record.Error != "" {
+			result = "error: " + record.Error
+		}
+		if record.ReplayOf != "" {
+			result = "replay of " + record.ReplayOf + ": " + result
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t$%.4f\t%s\t%s\n",
+			record.RunID,
+			record.Task,
+			record.Started.Local().Format("2006-01-02 15:04:05"),
+			record.Duration.Round(time.Millisecond),
+			len(record.Exchanges),
+			record.Cost,
+			code,
+			truncate(result, 60),
+		)
+	}
+	return w.Flush()
+}
+
+func runRunsShow(args []string) error {
+	fs := flag.NewFlagSet("runs show", flag.ExitOnError)
+	ledgerPath := fs.String("ledger", ledger.Path(), "path to the run ledger")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: aidevs runs show [flags] <run-id>")
+	}
+	record, err := ledger.Find(*ledgerPath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling run: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// truncate shortens text to one line of at most n runes
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) > n {
+		return string([]rune(text)[:n]) + "..."
+	}
+	return text
+}
@@ -2,152 +2,232 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/dawidjelenkowski/aidevs3go/internal/captcha"
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/firmware"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/dawidjelenkowski/aidevs3go/internal/webform"
+	"github.com/rs/zerolog/log"
 	openai "github.com/sashabaranov/go-openai"
 )
 
-const (
-	baseURL = "https://xyz.ag3nts.org/"
-)
+// Config of the capcha task, see configs/capcha.yaml
+type Config struct {
+	BaseURL     string `yaml:"base_url" env:"CAPCHA_BASE_URL" flag:"base-url" usage:"robot panel address" required:"true"`
+	CookieJar   string `yaml:"cookie_jar" env:"CAPCHA_COOKIE_JAR" flag:"cookie-jar" usage:"file keeping the session cookies between runs" required:"true"`
+	Username    string `yaml:"username" env:"XYZ_USERNAME" flag:"username" usage:"robot panel login" secret:"xyz-username" required:"true"`
+	Password    string `yaml:"password" env:"XYZ_PASSWORD" flag:"password" usage:"robot panel password" secret:"xyz-password" required:"true"`
+	Model       string `yaml:"model" env:"CAPCHA_MODEL" flag:"model" usage:"OpenAI model answering the captcha" required:"true"`
+	GeminiModel string `yaml:"gemini_model" env:"CAPCHA_GEMINI_MODEL" flag:"gemini-model" usage:"Gemini model giving a second opinion when a Gemini key is configured"`
+	Samples     int    `yaml:"samples" env:"CAPCHA_SAMPLES" flag:"samples" usage:"answers sampled per model for the captcha vote"`
+}
 
-func solveCaptcha(client *openai.Client, question string) (int, error) {
-	log.Printf("Attempting to solve question: %s", question)
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are a helpful assistant that provides precise, numeric answers to historical questions.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: fmt.Sprintf("What is the numeric answer to this question: %s? Respond ONLY with the number.", question),
-				},
-			},
-			MaxTokens:   10,
-			Temperature: 0.2,
-		},
-	)
-
-	// Use the ChatService to get the completion with custom options
-	if err != nil {
-		return 0, fmt.Errorf("OpenAI API error: %v", err)
+// Validate checks the settings that cannot be checked by presence alone
+func (c *Config) Validate() error {
+	if c.Samples < 1 {
+		return fmt.Errorf("samples must be at least 1, got %d", c.Samples)
 	}
+	return nil
+}
 
-	// Log the raw response for debugging
-	answer := strings.TrimSpace(resp.Choices[0].Message.Content)
-	log.Printf("Raw OpenAI response: %s", answer)
+const (
+	maxQuestionFetches = 3
+	maxLoginAttempts   = 3
+	// minConfidence is the share of agreeing samples below which the answer is flagged
+	minConfidence = 0.6
+)
 
-	var num int
-	_, err = fmt.Sscanf(answer, "%d", &num)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse answer '%s' as number: %v", answer, err)
+// captchaQuestion reads the anti-bot question from the login page
+func captchaQuestion(page *webform.Page) (string, error) {
+	questionElement := page.Doc.Find("p#human-question")
+	if questionElement.Length() == 0 {
+		return "", fmt.Errorf("captcha question not found")
 	}
-
-	log.Printf("Successfully parsed answer: %d", num)
-	return num, nil
+	return strings.TrimSpace(strings.Replace(questionElement.Text(), "Question:", "", 1)), nil
 }
-func login(openaiClient *openai.Client) (*http.Client, error) {
-	// Create HTTP client that will maintain cookies
-	httpClient := &http.Client{}
 
-	// Get the login page
-	resp, err := httpClient.Get(baseURL)
+// solveCaptcha votes on the answer. The question rotates every few seconds,
+// so after solving the page is fetched again and a changed question is solved anew.
+func solveCaptcha(ctx context.Context, session *webform.Session, solver *captcha.Solver, baseURL string, page *webform.Page) (int, error) {
+	question, err := captchaQuestion(page)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get login page: %v", err)
+		return 0, err
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+	for attempt := 1; attempt <= maxQuestionFetches; attempt++ {
+		log.Info().Str("question", question).Msg("Attempting to solve question")
+		result, err := solver.Solve(ctx, question)
+		if err != nil {
+			return 0, fmt.Errorf("failed to solve captcha: %v", err)
+		}
+
+		fresh, err := session.Fetch(ctx, baseURL)
+		if err != nil {
+			return 0, err
+		}
+		current, err := captchaQuestion(fresh)
+		if err != nil {
+			return 0, err
+		}
+		if current == question {
+			log.Info().
+				Int("answer", result.Answer).
+				Float64("confidence", result.Confidence).
+				Int("agreeing", result.Agreeing()).
+				Int("samples", len(result.Votes)).
+				Msg("Submitting answer")
+			if result.Confidence < minConfidence {
+				log.Warn().Interface("votes", result.Votes).Msg("Low captcha confidence")
+			}
+			return result.Answer, nil
+		}
+		log.Info().Str("question", current).Msg("Captcha question expired while solving")
+		question = current
 	}
+	return 0, fmt.Errorf("captcha question kept changing after %d attempts", maxQuestionFetches)
+}
 
-	// Parse the HTML
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+// login signs in to the robot panel, solving the anti-bot question by vote.
+// The session cookies are kept in cfg.CookieJar between runs.
+func login(ctx context.Context, cfg Config, solver *captcha.Solver) (*webform.Session, *webform.Page, error) {
+	session, err := webform.NewSession(cfg.CookieJar)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+		return nil, nil, fmt.Errorf("failed to create session: %v", err)
 	}
 
-	// Find the captcha question
-	questionElement := doc.Find("p#human-question")
-	if questionElement.Length() == 0 {
-		return nil, fmt.Errorf("captcha question not found")
+	creds := webform.Credentials{
+		UsernameField: "username",
+		PasswordField: "password",
+		Username:      cfg.Username,
+		Password:      cfg.Password,
 	}
-
-	questionText := strings.TrimSpace(strings.Replace(questionElement.Text(), "Question:", "", 1))
-	log.Printf("Found captcha question: %s", questionText)
-
-	// Solve the captcha
-	answer, err := solveCaptcha(openaiClient, questionText)
-	if err != nil {
-		return nil, fmt.Errorf("failed to solve captcha: %v", err)
+	for attempt := 1; attempt <= maxLoginAttempts; attempt++ {
+		page, err := session.Login(ctx, cfg.BaseURL, creds, func(ctx context.Context, page *webform.Page, form *webform.Form) (map[string]string, error) {
+			answer, err := solveCaptcha(ctx, session, solver, cfg.BaseURL, page)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"answer": strconv.Itoa(answer)}, nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		log.Info().Str("url", page.URL.String()).Int("status", page.Status).Msg("Login response")
+		if _, err := page.LoginForm(); err != nil {
+			return session, page, nil
+		}
+		log.Warn().Int("attempt", attempt).Msg("Login form is still shown, the answer was probably rejected")
 	}
-	log.Printf("Solved captcha. Answer: %d", answer)
+	return nil, nil, fmt.Errorf("login rejected after %d attempts", maxLoginAttempts)
+}
 
-	// Prepare login form data
-	formData := url.Values{
-		"username": {"tester"},
-		"password": {"574e112a"},
-		"answer":   {fmt.Sprintf("%d", answer)},
+// firmwareLinks returns the text files the panel links to on its own host
+func firmwareLinks(page *webform.Page) []*url.URL {
+	var links []*url.URL
+	for _, link := range page.Links() {
+		log.Info().Str("text", link.Text).Str("url", link.URL.String()).Msg("Link")
+		if link.URL.Host == page.URL.Host && path.Ext(link.URL.Path) == ".txt" {
+			links = append(links, link.URL)
+		}
 	}
+	return links
+}
 
-	// Submit the login form
-	loginResp, err := httpClient.PostForm(baseURL, formData)
-	if err != nil {
-		return nil, fmt.Errorf("login request failed: %v", err)
+func main() {
+	cfg := Config{
+		BaseURL:     "https://xyz.ag3nts.org/",
+		CookieJar:   "downloads/xyz-cookies.json",
+		Model:       openai.GPT4oMini,
+		GeminiModel: "gemini-2.0-flash-exp",
+		Samples:     5,
 	}
-	defer loginResp.Body.Close()
-
-	// Read and log the response for debugging
-	loginBody, err := io.ReadAll(loginResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read login response: %v", err)
+	loader := config.New("capcha", &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	logging.Setup("capcha", *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin("capcha")
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
 	}
-	log.Printf("Login response: %s", string(loginBody))
-
-	return httpClient, nil
 }
 
-func main() {
-	openaiKey, err := utils.GetAPIKey("openai-api-key")
+// runTask logs in to the robot panel and downloads the firmware it links to
+func runTask(ctx context.Context, cfg Config) error {
+	openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
 	if err != nil {
-		log.Fatalf("Failed to get API key: %v", err)
+		return fmt.Errorf("failed to get API key: %w", err)
 	}
 
-	// Initialize OpenAI client
-	openaiClient := openai.NewClient(openaiKey)
+	// Sample the answer several times, with Gemini as a second opinion when it is configured
+	solver := &captcha.Solver{
+		Samplers: []captcha.Sampler{captcha.OpenAISampler(openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey)), cfg.Model, 0.7)},
+		Samples:  cfg.Samples,
+	}
+	if geminiKey, ok := utils.LookupAPIKey(ctx, "gemini-api-key"); ok && cfg.GeminiModel != "" {
+		solver.Samplers = append(solver.Samplers, captcha.GeminiSampler(geminiKey, cfg.GeminiModel, 0.7))
+	}
 
 	// Attempt to login
-	httpClient, err := login(openaiClient)
+	session, page, err := login(ctx, cfg, solver)
 	if err != nil {
-		log.Fatalf("Login failed: %v", err)
+		return fmt.Errorf("login failed: %w", err)
 	}
-
-	log.Println("Login successful!: ", httpClient)
-
-	// Define the files to download
-	fileNames := []string{
-		"0_13_4b.txt",
-		"0_13_4.txt",
-		//"0_12.1.txt", // Example of a file that may not exist
+	log.Info().Str("url", page.URL.String()).Msg("Login successful")
+
+	// The panel links to the firmware dumps, which need the logged-in session
+	var fileNames []string
+	for _, link := range firmwareLinks(page) {
+		fileName := path.Base(link.Path)
+		filePath := filepath.Join("downloads", fileName)
+		log.Debug().Str("url", link.String()).Str("file", filePath).Msg("Downloading firmware")
+		if err := session.Download(ctx, link.String(), filePath); err != nil {
+			return fmt.Errorf("failed to download %s: %w", fileName, err)
+		}
+		ledger.Current().AddInput(filePath)
+		fileNames = append(fileNames, fileName)
 	}
-
-	// Download the files
-	if err := utils.DownloadFiles(openaiKey, "downloads", fileNames); err != nil {
-		log.Fatalf("Failed to download files: %v", err)
+	if len(fileNames) == 0 {
+		return fmt.Errorf("no firmware links on %s", page.URL)
+	}
+	log.Info().Strs("files", fileNames).Msg("Files downloaded successfully")
+
+	// Record the firmware rules so that a new version changing them is noticed
+	for _, fileName := range fileNames {
+		fw, err := firmware.Load(filepath.Join("downloads", fileName))
+		if errors.Is(err, firmware.ErrNotFirmware) {
+			log.Info().Err(err).Str("file", fileName).Msg("Skipping file")
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse firmware %s: %w", fileName, err)
+		}
+		if _, err := firmware.Track(firmware.DefaultStatePath, fw); err != nil {
+			return fmt.Errorf("failed to track firmware: %w", err)
+		}
+		log.Info().
+			Str("file", fileName).
+			Str("version", fw.Version).
+			Int("instructions", len(fw.Instructions)).
+			Int("overrides", len(fw.Overrides)).
+			Msg("Firmware loaded")
 	}
-	log.Println("Files downloaded successfully.")
+	return nil
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/firmware"
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const firmwareDump = `# Robot firmware v0.13.4b
+
+Rules:
+- Always answer in English
+
+************* UWAGA! *************
+- stolicą Polski jest Kraków
+**********************************
+`
+
+func newConfig(xyz *testkit.XYZ) Config {
+	username, password := xyz.Credentials()
+	return Config{
+		BaseURL:     "https://xyz.ag3nts.org/",
+		CookieJar:   "downloads/xyz-cookies.json",
+		Username:    username,
+		Password:    password,
+		Model:       openai.GPT4oMini,
+		GeminiModel: "gemini-2.0-flash-exp",
+		Samples:     2,
+	}
+}
+
+func TestRunTask(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	xyz := testkit.NewXYZ(t)
+	xyz.SetCaptcha("Rok lądowania na Księżycu?", 1969)
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(openai.ChatCompletionRequest) string { return "1969" })
+	gemini := testkit.NewGemini(t)
+	gemini.OnGenerate(func(testkit.GeminiRequest) string { return "The answer is 1969." })
+
+	// The firmware is linked from the panel and only served to the logged-in session
+	xyz.SetPanel(`<html><body><a href="/files/0_13_4b.txt">v0.13.4b</a> <a href="files/0_13_4.txt">v0.13.4</a> <a href="https://example.com/x.txt">elsewhere</a></body></html>`)
+	xyz.File("0_13_4b.txt", []byte(firmwareDump))
+	xyz.File("0_13_4.txt", []byte("{{FLG:OLD}}"))
+
+	if err := runTask(context.Background(), newConfig(xyz)); err != nil {
+		t.Fatal(err)
+	}
+
+	if logins := xyz.Logins(); logins != 1 {
+		t.Errorf("expected a single login, got %d", logins)
+	}
+	if chats, requests := len(llm.Chats()), len(gemini.Requests()); chats != 2 || requests != 2 {
+		t.Errorf("expected 2 samples per model, got %d from OpenAI and %d from Gemini", chats, requests)
+	}
+	for _, name := range []string{"downloads/0_13_4b.txt", "downloads/0_13_4.txt"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("firmware was not downloaded: %v", err)
+		}
+	}
+	if _, err := os.Stat(firmware.DefaultStatePath); err != nil {
+		t.Errorf("firmware was not tracked: %v", err)
+	}
+	if _, err := os.Stat("downloads/xyz-cookies.json"); err != nil {
+		t.Errorf("session cookies were not saved: %v", err)
+	}
+}
+
+func TestRunTaskWrongAnswer(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	xyz := testkit.NewXYZ(t)
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(openai.ChatCompletionRequest) string { return "1410" })
+
+	cfg := newConfig(xyz)
+	cfg.GeminiModel = ""
+	if err := runTask(context.Background(), cfg); err == nil {
+		t.Fatal("expected the login to be rejected")
+	}
+	if logins := xyz.Logins(); logins != maxLoginAttempts {
+		t.Errorf("expected %d login attempts, got %d", maxLoginAttempts, logins)
+	}
+}
@@ -2,76 +2,222 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
 	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+	"github.com/dawidjelenkowski/aidevs3go/internal/redact"
+	"github.com/dawidjelenkowski/aidevs3go/internal/text"
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
 	"github.com/rs/zerolog/log"
 	openai "github.com/sashabaranov/go-openai"
 )
 
+const systemMessage = "Replace all sensitive data (full names, street names + numbers, cities, person's age) with the word CENZURA. Maintain all punctuation, spaces, etc. Do not rephrase the text."
+
+// Config of the cenzura task, see configs/cenzura.yaml
+type Config struct {
+	Model   string `yaml:"model" env:"CENZURA_MODEL" flag:"model" usage:"OpenAI model used by -llm and -rewrite" required:"true"`
+	LLM     bool   `yaml:"llm" env:"CENZURA_LLM" flag:"llm" usage:"also ask the model for sensitive fragments the rules miss"`
+	Rewrite bool   `yaml:"rewrite" env:"CENZURA_REWRITE" flag:"rewrite" usage:"let the model rewrite the text, verified against the original"`
+	Retries int    `yaml:"retries" env:"CENZURA_RETRIES" flag:"retries" usage:"rewrite attempts after the first that fail verification before falling back to rules"`
+	Merge   string `yaml:"merge" env:"CENZURA_MERGE" flag:"merge" usage:"how adjacent entities are merged: same-type, any or none"`
+}
+
+// Validate checks the settings that have a fixed set of values
+func (c *Config) Validate() error {
+	if _, ok := mergeModes[c.Merge]; !ok {
+		return fmt.Errorf("merge must be same-type, any or none, got %q", c.Merge)
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("retries must not be negative, got %d", c.Retries)
+	}
+	return nil
+}
+
+var mergeModes = map[string]redact.MergeMode{
+	"same-type": redact.MergeSameType,
+	"any":       redact.MergeAny,
+	"none":      redact.MergeNone,
+}
+
 func main() {
-	logging.Setup("cenzura")
+	cfg := Config{
+		Model:   openai.GPT4oMini,
+		Retries: 2,
+		Merge:   "same-type",
+	}
+	loader := config.New("cenzura", &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	logging.Setup("cenzura", *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin("cenzura")
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
+	}
+}
+
+// runTask downloads the text, redacts it and reports the result
+func runTask(ctx context.Context, cfg Config) error {
 	// Get API keys
-	aidevsKey, err := utils.GetAPIKey("aidevs-api-key")
+	aidevsKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to get AIDevs API key")
+		return fmt.Errorf("failed to get AIDevs API key: %w", err)
 	}
-	openaiKey, err := utils.GetAPIKey("openai-api-key")
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to get API key")
+
+	var openaiClient *openai.Client
+	if cfg.LLM || cfg.Rewrite {
+		openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
+		if err != nil {
+			return fmt.Errorf("failed to get API key: %w", err)
+		}
+		openaiClient = openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey))
 	}
 
-	// Initialize OpenAI client
-	openaiClient := openai.NewClient(openaiKey)
+	detectors := []redact.Detector{redact.NewRuleDetector(nil, nil)}
+	if cfg.LLM {
+		detectors = append(detectors, &redact.LLMDetector{Client: openaiClient, Model: cfg.Model})
+	}
 
 	fileNames := []string{"cenzura.txt"}
 	downloadPath := "downloads"
 
-	if err := utils.DownloadFiles(aidevsKey, downloadPath, fileNames); err != nil {
-		log.Fatal().Err(err).Msg("Failed to download files")
+	if err := utils.DownloadFiles(ctx, aidevsKey, downloadPath, fileNames); err != nil {
+		return fmt.Errorf("failed to download files: %w", err)
 	}
 	log.Info().Msg("Files downloaded successfully.")
 
 	filePath := filepath.Join(downloadPath, fileNames[0])
+	ledger.Current().AddInput(filePath)
 	content, err := utils.ReadFile(filePath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to read file contents")
-	}
-
-	systemMessage := "Replace all sensitive data (full names, street names + numbers, cities, person's age) with the word CENZURA. Maintain all punctuation, spaces, etc. Do not rephrase the text."
-
-	resp, err := openaiClient.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemMessage,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: content,
-				},
-			},
-			Temperature: 0.0,
-		},
-	)
+		return fmt.Errorf("failed to read file contents: %w", err)
+	}
 
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to process content with OpenAI")
+	var answer string
+	if cfg.Rewrite {
+		answer, err = rewriteVerified(ctx, openaiClient, cfg.Model, content, cfg.Retries)
+		if err != nil {
+			log.Warn().Err(err).Msg("Model rewrite failed verification, falling back to rule-based redaction")
+		}
 	}
 
-	processedContent := resp.Choices[0].Message.Content
+	if answer == "" {
+		redactor := redact.New(detectors...)
+		redactor.Merge = mergeModes[cfg.Merge]
+		result, err := redactor.Redact(ctx, content)
+		if err != nil {
+			return fmt.Errorf("failed to redact content: %w", err)
+		}
+		log.Info().Int("entities", len(result.Entities)).Str("redacted", result.Text).Msg("Content redacted")
+		answer = result.Text
+	}
+
+	// Never send text that was changed beyond the placeholders
+	if verification := redact.Verify(content, answer, redact.DefaultPlaceholder); !verification.Valid {
+		return fmt.Errorf("redacted text failed verification: %w", verification.Error())
+	}
 
 	// Send the processed content as the answer
-	if err := utils.SendAnswer(processedContent, "CENZURA"); err != nil {
-		log.Fatal().
-			Err(err).
-			Int("content_length", len(processedContent)).
-			Msg("Failed to send answer to API")
+	resp, err := centrala.NewClient(aidevsKey).Report(ctx, "CENZURA", answer)
+	if err != nil {
+		return fmt.Errorf("failed to send answer of %d bytes: %w", len(answer), err)
 	}
+	if !resp.Success() {
+		log.Error().Int("code", resp.Code).Str("message", resp.Message).Msg("Answer rejected")
+		return nil
+	}
+	log.Info().Str("message", resp.Message).Msg("Answer accepted")
 
 	log.Info().Msg("Successfully completed all operations")
+	return nil
+}
+
+// rewriteChunkTokens bounds the text rewritten by one request; the reply is
+// as long as the request, so the output limit applies too
+const rewriteChunkTokens = 2000
+
+// rewriteVerified asks the model to censor the text segment by segment and
+// verifies that it only replaced spans with the placeholder
+func rewriteVerified(ctx context.Context, client *openai.Client, model, content string, retries int) (string, error) {
+	var rewritten strings.Builder
+	segments := text.Split(content, text.SplitOptions{Model: model, ChunkSize: rewriteChunkTokens})
+	for i, segment := range segments {
+		candidate, err := rewriteSegment(ctx, client, model, segment, retries)
+		if err != nil {
+			return "", fmt.Errorf("segment %d of %d: %w", i+1, len(segments), err)
+		}
+		rewritten.WriteString(candidate)
+	}
+	return rewritten.String(), nil
+}
+
+// rewriteSegment rewrites one segment. Rejected attempts are retried with the
+// segment and the edits reported for the last attempt, so every request stays
+// about the size of the segment.
+func rewriteSegment(ctx context.Context, client *openai.Client, model string, segment text.Segment, retries int) (string, error) {
+	window := &text.ContextManager{Model: model, ReserveOutput: segment.Tokens + rewriteChunkTokens/4}
+	var feedback string
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: systemMessage}}
+		if feedback != "" {
+			messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: feedback})
+		}
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: segment.Text})
+		// Feedback is dropped before the segment is cut
+		messages, fit, err := window.Fit(ctx, messages)
+		if err != nil {
+			return "", fmt.Errorf("failed to fit the segment: %w", err)
+		}
+		if fit.Truncated > 0 {
+			return "", fmt.Errorf("segment of %d tokens does not fit the context of %s", segment.Tokens, model)
+		}
+
+		resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model:       model,
+			Messages:    messages,
+			Temperature: 0.0,
+		})
+		if err != nil {
+			return "", fmt.Errorf("OpenAI API error: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("OpenAI returned no choices")
+		}
+		reply := resp.Choices[0].Message.Content
+
+		// The model drops surrounding whitespace, restore it before comparing
+		content := segment.Text
+		trimmed := strings.TrimSpace(content)
+		leading := content[:strings.Index(content, trimmed)]
+		candidate := leading + strings.TrimSpace(reply) + content[len(leading)+len(trimmed):]
+
+		verification := redact.Verify(content, candidate, redact.DefaultPlaceholder)
+		if verification.Valid {
+			log.Info().Int("attempt", attempt+1).Int("replaced", len(verification.Replaced)).Msg("Model rewrite verified")
+			return candidate, nil
+		}
+		lastErr = verification.Error()
+		log.Warn().Int("attempt", attempt+1).Interface("edits", verification.Edits).Msg("Model rewrite changed more than the sensitive data")
+
+		var message strings.Builder
+		message.WriteString("A previous answer for the next text changed it outside the censored fragments. Keep these fragments exactly as in the text:\n")
+		for _, edit := range verification.Edits {
+			fmt.Fprintf(&message, "- original %q was written as %q\n", edit.Original, edit.Redacted)
+		}
+		feedback = message.String()
+	}
+	return "", lastErr
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	original = "Dane personalne podejrzanego: Wojciech Górski. Przebywa w Lublinie, ul. Akacjowa 7. Wiek: 27 lat.\n"
+	censored = "Dane personalne podejrzanego: CENZURA. Przebywa w CENZURA, ul. CENZURA. Wiek: CENZURA lat.\n"
+)
+
+func newCentrala(t *testing.T) *testkit.Centrala {
+	centrala := testkit.NewCentrala(t)
+	centrala.File("cenzura.txt", []byte(original))
+	centrala.ExpectFunc("CENZURA", func(answer json.RawMessage) error {
+		var text string
+		if err := json.Unmarshal(answer, &text); err != nil {
+			return err
+		}
+		for _, secret := range []string{"Wojciech", "Górski", "Lublin", "Akacjowa", "27"} {
+			if strings.Contains(text, secret) {
+				return fmt.Errorf("%q is not censored in %q", secret, text)
+			}
+		}
+		return nil
+	}, "{{FLG:CENZURA}}")
+	return centrala
+}
+
+func TestRunTaskRules(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	centrala := newCentrala(t)
+
+	if err := runTask(context.Background(), Config{Model: openai.GPT4oMini, Merge: "same-type"}); err != nil {
+		t.Fatal(err)
+	}
+	reports := centrala.Reports()
+	if len(reports) != 1 || reports[0].Code != 0 {
+		t.Fatalf("expected one accepted report, got %+v", reports)
+	}
+}
+
+func TestRunTaskRewrite(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	centrala := newCentrala(t)
+	llm := testkit.NewOpenAI(t)
+	// The first rewrite changes more than the sensitive data and is retried
+	llm.OnChat(func(req openai.ChatCompletionRequest) string {
+		if len(req.Messages) == 2 {
+			return strings.Replace(censored, "Przebywa", "Mieszka", 1)
+		}
+		return censored
+	})
+
+	cfg := Config{Model: "test-model", Rewrite: true, Retries: 1, Merge: "same-type"}
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	chats := llm.Chats()
+	if len(chats) != 2 {
+		t.Fatalf("expected the rejected rewrite to be retried once, got %d calls", len(chats))
+	}
+	// The retry sends the original text and the edits, not the rejected reply
+	retry := chats[1].Messages
+	if len(retry) != 3 || !strings.Contains(retry[1].Content, `was written as "CENZURA. Mieszka"`) || retry[2].Content != original {
+		t.Fatalf("unexpected retry messages %+v", retry)
+	}
+	reports := centrala.Reports()
+	if len(reports) != 1 || reports[0].Code != 0 || !strings.Contains(string(reports[0].Answer), "Przebywa w CENZURA") {
+		t.Fatalf("expected the verified rewrite to be accepted, got %+v", reports)
+	}
+}
+
+func TestRunTaskRewriteSegments(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	long := strings.Repeat(original+"\n", 120)
+	centrala := testkit.NewCentrala(t)
+	centrala.File("cenzura.txt", []byte(long))
+	centrala.Expect("CENZURA", strings.Repeat(censored+"\n", 120), "{{FLG:CENZURA}}")
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(req openai.ChatCompletionRequest) string {
+		return strings.ReplaceAll(testkit.LastUserMessage(req), original, censored)
+	})
+
+	cfg := Config{Model: "test-model", Rewrite: true, Merge: "same-type"}
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	chats := llm.Chats()
+	if len(chats) < 2 {
+		t.Fatalf("expected the text to be rewritten in several requests, got %d", len(chats))
+	}
+	for _, chat := range chats {
+		if size := len(testkit.LastUserMessage(chat)); size >= len(long) {
+			t.Fatalf("request of %d bytes is not a segment of the %d byte text", size, len(long))
+		}
+	}
+	if reports := centrala.Reports(); len(reports) != 1 || reports[0].Code != 0 {
+		t.Fatalf("expected one accepted report, got %+v", reports)
+	}
+}
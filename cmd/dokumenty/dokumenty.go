@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const taskName = "dokumenty"
+
+// promptVersion is part of the cache key; bump it when the prompt changes
+const promptVersion = "v1"
+
+var (
+	// Matches "sektor_C4" and "sektor-C1" in report file names
+	sectorPattern = regexp.MustCompile(`(?i)sektor[_-]([A-Z]\d+)`)
+	// Capitalized word pairs, e.g. "Barbara Zawadzka"
+	namePattern = regexp.MustCompile(`\p{Lu}\p{Ll}+\s+\p{Lu}\p{Ll}+`)
+)
+
+// source is a text file used by the pipeline
+type source struct {
+	Name string
+	Text string
+}
+
+// cacheEntry stores generated keywords under a hash of everything that produced them
+type cacheEntry struct {
+	Report   string `json:"report"`
+	Keywords string `json:"keywords"`
+}
+
+// extractSector returns the sector from a report file name, e.g. "C4"
+func extractSector(fileName string) string {
+	match := sectorPattern.FindStringSubmatch(fileName)
+	if match == nil {
+		return ""
+	}
+	return strings.ToUpper(match[1])
+}
+
+// readTextFiles reads every .txt file in the directory, sorted by name
+func readTextFiles(dir string) ([]source, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+
+	var sources []source
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".txt" {
+			continue
+		}
+		content, err := utils.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source{Name: entry.Name(), Text: strings.TrimSpace(content)})
+	}
+
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+// caseEndings are the Polish case endings added to short names (Jan / Jana, Maj / Majem)
+var caseEndings = map[string]bool{
+	"a": true, "u": true, "owi": true, "em": true, "ie": true, "e": true, "y": true, "i": true,
+	"ą": true, "ę": true, "om": true, "ach": true, "ami": true, "owie": true, "ów": true,
+}
+
+// sameWord compares Polish words ignoring inflected endings (Zawadzka / Zawadzkiej).
+// Words shorter than 4 runes only match themselves or themselves with a case ending.
+func sameWord(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(ra) < 4 {
+		return strings.HasPrefix(string(rb), string(ra)) && caseEndings[string(rb[len(ra):])]
+	}
+
+	common := 0
+	for common < len(ra) && ra[common] == rb[common] {
+		common++
+	}
+	return common >= max(4, len(ra)-2)
+}
+
+// samePerson compares two "First Last" names word by word
+func samePerson(a, b string) bool {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if len(wordsA) != 2 || len(wordsB) != 2 {
+		return false
+	}
+	return sameWord(wordsA[0], wordsB[0]) && sameWord(wordsA[1], wordsB[1])
+}
+
+// relatedFacts returns the facts mentioning any person named in the report
+func relatedFacts(report source, facts []source) []source {
+	reportNames := namePattern.FindAllString(report.Text, -1)
+
+	var related []source
+	for _, fact := range facts {
+		factNames := namePattern.FindAllString(fact.Text, -1)
+	match:
+		for _, reportName := range reportNames {
+			for _, factName := range factNames {
+				if samePerson(reportName, factName) {
+					log.Debug().Str("report", report.Name).Str("fact", fact.Name).Str("person", reportName).Msg("Linked report to fact")
+					related = append(related, fact)
+					break match
+				}
+			}
+		}
+	}
+	return related
+}
+
+// buildPrompt creates the user message for a report and its related facts
+func buildPrompt(report source, sector string, facts []source) string {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "<report file=\"%s\" sector=\"%s\">\n%s\n</report>\n", report.Name, sector, report.Text)
+	for _, fact := range facts {
+		fmt.Fprintf(&prompt, "<fact file=\"%s\">\n%s\n</fact>\n", fact.Name, fact.Text)
+	}
+	return prompt.String()
+}
+
+// cacheKey hashes every input of the model call so changed inputs are regenerated
+func cacheKey(model, prompt string) string {
+	hash := sha256.Sum256([]byte(promptVersion + "\x00" + model + "\x00" + prompt))
+	return hex.EncodeToString(hash[:])
+}
+
+func loadCache(path string) (map[string]cacheEntry, error) {
+	cache := make(map[string]cacheEntry)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse cache: %w", err)
+	}
+	return cache, nil
+}
+
+func saveCache(path string, cache map[string]cacheEntry) error {
+	data, err := json.MarshalIndent(cache, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// normalizeKeywords trims, deduplicates and joins keywords with commas
+func normalizeKeywords(raw string) string {
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, keyword := range strings.Split(raw, ",") {
+		keyword = strings.Trim(strings.TrimSpace(keyword), ".\"'")
+		if keyword == "" || seen[strings.ToLower(keyword)] {
+			continue
+		}
+		seen[strings.ToLower(keyword)] = true
+		keywords = append(keywords, keyword)
+	}
+	return strings.Join(keywords, ",")
+}
+
+// generateKeywords asks the model for Polish nominative keywords describing the report
+func generateKeywords(ctx context.Context, client *openai.Client, model, prompt string) (string, error) {
+	systemMessage := `Jesteś asystentem tworzącym metadane dla raportów z fabryki.
+Na podstawie raportu oraz powiązanych z nim faktów wygeneruj listę słów kluczowych w języku polskim.
+Zasady:
+- każde słowo kluczowe w mianowniku liczby pojedynczej (np. "nauczyciel", a nie "nauczycielem")
+- uwzględnij sektor z nazwy pliku (np. "sektor C4")
+- uwzględnij imiona i nazwiska osób, ich zawody, umiejętności i powiązania z faktów
+- uwzględnij zdarzenia i obiekty z raportu (np. "zwierzyna leśna", "nadajnik", "odciski palców")
+- odpowiedz wyłącznie listą słów kluczowych oddzielonych przecinkami, bez komentarzy`
+
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemMessage},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
+		},
+		Temperature: 0.0,
+		Seed:        new(int),
+	})
+	if err != nil {
+		return "", fmt.Errorf("OpenAI API error: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI returned no choices")
+	}
+	return normalizeKeywords(resp.Choices[0].Message.Content), nil
+}
+
+// Config of the dokumenty task, see configs/dokumenty.yaml
+type Config struct {
+	Model      string `yaml:"model" env:"DOKUMENTY_MODEL" flag:"model" usage:"OpenAI model" required:"true"`
+	ReportsDir string `yaml:"reports_dir" env:"DOKUMENTY_REPORTS_DIR" flag:"dir" usage:"directory with the .txt reports" required:"true"`
+	FactsDir   string `yaml:"facts_dir" env:"DOKUMENTY_FACTS_DIR" flag:"facts" usage:"directory with the facts files" required:"true"`
+	Cache      string `yaml:"cache" env:"DOKUMENTY_CACHE" flag:"cache" usage:"keyword cache file" required:"true"`
+	Output     string `yaml:"output" env:"DOKUMENTY_OUTPUT" flag:"output" usage:"where to write the filename -> keywords map" required:"true"`
+	Submit     bool   `yaml:"submit" env:"DOKUMENTY_SUBMIT" flag:"submit" usage:"submit the answer to Centrala"`
+}
+
+func main() {
+	cfg := Config{
+		Model:      openai.GPT4oMini,
+		ReportsDir: "documents/pliki_z_fabryki",
+		FactsDir:   "documents/pliki_z_fabryki/facts",
+		Cache:      "downloads/dokumenty-cache.json",
+		Output:     "downloads/dokumenty.json",
+	}
+	loader := config.New(taskName, &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	logging.Setup(taskName, *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin(taskName)
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
+	}
+}
+
+// runTask generates keywords for every report, reusing cached ones, saves
+// the answer and submits it when asked to
+func runTask(ctx context.Context, cfg Config) error {
+	run := ledger.Current()
+	run.AddInput(cfg.ReportsDir)
+	run.AddInput(cfg.FactsDir)
+
+	reports, err := readTextFiles(cfg.ReportsDir)
+	if err != nil {
+		return fmt.Errorf("failed to read reports: %w", err)
+	}
+	facts, err := readTextFiles(cfg.FactsDir)
+	if err != nil {
+		return fmt.Errorf("failed to read facts: %w", err)
+	}
+
+	cache, err := loadCache(cfg.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to load keyword cache: %w", err)
+	}
+
+	// The OpenAI client is only created when something is missing from the cache
+	var openaiClient *openai.Client
+
+	answer := make(map[string]string)
+	for _, report := range reports {
+		sector := extractSector(report.Name)
+		related := relatedFacts(report, facts)
+		prompt := buildPrompt(report, sector, related)
+		key := cacheKey(cfg.Model, prompt)
+
+		if entry, ok := cache[key]; ok {
+			log.Info().Str("report", report.Name).Msg("Keywords loaded from cache")
+			answer[report.Name] = entry.Keywords
+			continue
+		}
+
+		if openaiClient == nil {
+			openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
+			if err != nil {
+				return fmt.Errorf("failed to get OpenAI API key: %w", err)
+			}
+			openaiClient = openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey))
+		}
+
+		keywords, err := generateKeywords(ctx, openaiClient, cfg.Model, prompt)
+		if err != nil {
+			return fmt.Errorf("failed to generate keywords for %s: %w", report.Name, err)
+		}
+		log.Info().Str("report", report.Name).Str("sector", sector).Int("facts", len(related)).Str("keywords", keywords).Msg("Keywords generated")
+
+		answer[report.Name] = keywords
+		cache[key] = cacheEntry{Report: report.Name, Keywords: keywords}
+		if err := saveCache(cfg.Cache, cache); err != nil {
+			return fmt.Errorf("failed to save keyword cache: %w", err)
+		}
+	}
+
+	answerJSON, err := json.MarshalIndent(answer, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal answer: %w", err)
+	}
+	if err := os.WriteFile(cfg.Output, answerJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write answer: %w", err)
+	}
+	log.Info().Str("output", cfg.Output).Int("reports", len(answer)).Msg("Answer saved")
+
+	if !cfg.Submit {
+		return nil
+	}
+
+	aidevsKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
+	if err != nil {
+		return fmt.Errorf("failed to get AIDevs API key: %w", err)
+	}
+	resp, err := centrala.NewClient(aidevsKey).Report(ctx, taskName, answer)
+	if err != nil {
+		return fmt.Errorf("failed to send answer: %w", err)
+	}
+	if !resp.Success() {
+		log.Error().Int("code", resp.Code).Str("message", resp.Message).Msg("Answer rejected")
+		return nil
+	}
+	log.Info().Str("message", resp.Message).Msg("Answer accepted")
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestRunTask(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	files := map[string]string{
+		"reports/2024-11-12_report-1-sektor_C4.txt": "Przy ogrodzeniu zatrzymano kobietę: Barbarę Zawadzką.",
+		"reports/2024-11-12_report-2-sektor_A1.txt": "Teren sprawdzony, to była zwierzyna leśna.",
+		"facts/f01.txt": "Barbara Zawadzka jest programistką JavaScript.",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(req openai.ChatCompletionRequest) string {
+		prompt := testkit.LastUserMessage(req)
+		if strings.Contains(prompt, "programistką") {
+			return "sektor C4, Barbara Zawadzka, programista, JavaScript, sektor C4."
+		}
+		return "sektor A1, zwierzyna leśna"
+	})
+	centrala := testkit.NewCentrala(t)
+	centrala.Expect(taskName, map[string]string{
+		"2024-11-12_report-1-sektor_C4.txt": "sektor C4,Barbara Zawadzka,programista,JavaScript",
+		"2024-11-12_report-2-sektor_A1.txt": "sektor A1,zwierzyna leśna",
+	}, "{{FLG:DOKUMENTY}}")
+
+	cfg := Config{
+		Model:      openai.GPT4oMini,
+		ReportsDir: "reports",
+		FactsDir:   "facts",
+		Cache:      "cache/keywords.json",
+		Output:     "dokumenty.json",
+		Submit:     true,
+	}
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if reports := centrala.Reports(); len(reports) != 1 || reports[0].Code != 0 {
+		t.Fatalf("expected one accepted report, got %+v", reports)
+	}
+
+	// A second run answers from the cache
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if chats := len(llm.Chats()); chats != 2 {
+		t.Fatalf("expected the second run to use the cache, got %d model calls", chats)
+	}
+	if reports := centrala.Reports(); len(reports) != 2 || reports[1].Code != 0 {
+		t.Fatalf("expected the cached answer to be accepted, got %+v", reports)
+	}
+}
+
+func TestSameWord(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Maj", "Maj", true},
+		{"Maj", "Majem", true},
+		{"Jan", "Jana", true},
+		{"Jana", "Jan", true},
+		{"Jan", "Janusz", false},
+		{"Maj", "Majewski", false},
+		{"Zawadzka", "Zawadzkiej", true},
+		{"Barbara", "Barbarę", true},
+		{"Adam", "Aleksander", false},
+	}
+	for _, test := range tests {
+		if got := sameWord(test.a, test.b); got != test.same {
+			t.Errorf("sameWord(%q, %q) = %v, expected %v", test.a, test.b, got, test.same)
+		}
+	}
+	if !samePerson("Andrzej Maj", "Andrzejem Majem") {
+		t.Error("expected Andrzej Maj to match Andrzejem Majem")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/documents"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vision"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const taskName = "kategorie"
+
+// noCategory is used for documents that match none of the configured categories
+const noCategory = "none"
+
+// Category is a classification bucket with a description for the model
+type Category struct {
+	Name        string
+	Description string
+}
+
+// categoryFlags collects repeated -category name=description flags
+type categoryFlags []Category
+
+func (c *categoryFlags) String() string {
+	names := make([]string, len(*c))
+	for i, category := range *c {
+		names[i] = category.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *categoryFlags) Set(value string) error {
+	name, description, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("category must be in the form name=description, got '%s'", value)
+	}
+	*c = append(*c, Category{Name: strings.TrimSpace(name), Description: strings.TrimSpace(description)})
+	return nil
+}
+
+var defaultCategories = []Category{
+	{Name: "people", Description: "The report mentions captured people or traces of human presence (e.g. fingerprints, a detained person). Ignore reports where nobody was found."},
+	{Name: "hardware", Description: "The report mentions repaired or fixed hardware faults (physical devices, antennas, cables). Ignore software updates and fixes."},
+}
+
+// Classification is the structured answer returned by the model for one document
+type Classification struct {
+	File      string `json:"file"`
+	Reasoning string `json:"reasoning"`
+	Category  string `json:"category"`
+}
+
+// Config of the kategorie task, see configs/kategorie.yaml. Categories
+// stay a repeatable -category flag.
+type Config struct {
+	Model    string `yaml:"model" env:"KATEGORIE_MODEL" flag:"model" usage:"OpenAI model classifying the reports" required:"true"`
+	InputDir string `yaml:"input_dir" env:"KATEGORIE_INPUT_DIR" flag:"dir" usage:"directory with the factory reports" required:"true"`
+	CacheDir string `yaml:"cache_dir" env:"KATEGORIE_CACHE_DIR" flag:"cache" usage:"directory for transcripts and OCR results" required:"true"`
+	Output   string `yaml:"output" env:"KATEGORIE_OUTPUT" flag:"output" usage:"where to write the JSON answer" required:"true"`
+	Review   bool   `yaml:"review" env:"KATEGORIE_REVIEW" flag:"review" usage:"show per-file reasoning and ask before submitting"`
+	Submit   bool   `yaml:"submit" env:"KATEGORIE_SUBMIT" flag:"submit" usage:"submit the answer to Centrala"`
+}
+
+// classify asks the model to assign a document to exactly one category
+func classify(ctx context.Context, client *openai.Client, model string, categories []Category, doc *documents.Document) (*Classification, error) {
+	names := []string{}
+	var description strings.Builder
+	for _, category := range categories {
+		names = append(names, category.Name)
+		fmt.Fprintf(&description, "- %s: %s\n", category.Name, category.Description)
+	}
+	names = append(names, noCategory)
+	fmt.Fprintf(&description, "- %s: the report fits none of the categories above\n", noCategory)
+
+	schema := &jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"reasoning": {Type: jsonschema.String, Description: "Short justification quoting the relevant fragment of the report"},
+			"category":  {Type: jsonschema.String, Enum: names},
+		},
+		Required:             []string{"reasoning", "category"},
+		AdditionalProperties: false,
+	}
+
+	systemMessage := "You classify factory security reports written in Polish. Read the report carefully and assign it to exactly one category:\n" +
+		description.String() +
+		"Think about the reasoning first, then pick the category."
+
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemMessage},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("File: %s\n\n%s", doc.Name, doc.Text)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "classification",
+				Schema: schema,
+				Strict: true,
+			},
+		},
+		Temperature: 0.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("OpenAI returned no choices")
+	}
+	result := &Classification{File: doc.Name}
+	if err := schema.Unmarshal(resp.Choices[0].Message.Content, result); err != nil {
+		return nil, fmt.Errorf("failed to parse classification for '%s': %w", doc.Name, err)
+	}
+	return result, nil
+}
+
+// buildAnswer groups file names by category, each list sorted alphabetically
+func buildAnswer(categories []Category, results []*Classification) map[string][]string {
+	answer := make(map[string][]string)
+	for _, category := range categories {
+		answer[category.Name] = []string{}
+	}
+	for _, result := range results {
+		if _, ok := answer[result.Category]; ok {
+			answer[result.Category] = append(answer[result.Category], result.File)
+		}
+	}
+	for name := range answer {
+		sort.Strings(answer[name])
+	}
+	return answer
+}
+
+// review prints per-file reasoning and asks for confirmation before submitting
+func review(results []*Classification) bool {
+	for _, result := range results {
+		fmt.Printf("%-40s %-10s %s\n", result.File, result.Category, result.Reasoning)
+	}
+	fmt.Print("Submit this answer? [y/N]: ")
+
+	reply, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	reply = strings.ToLower(strings.TrimSpace(reply))
+	return reply == "y" || reply == "yes"
+}
+
+func main() {
+	var categories categoryFlags
+	cfg := Config{
+		Model:    openai.GPT4oMini,
+		InputDir: "documents/pliki_z_fabryki",
+		CacheDir: "downloads/fabryka",
+		Output:   "downloads/kategorie.json",
+	}
+	loader := config.New(taskName, &cfg, flag.CommandLine)
+	flag.Var(&categories, "category", "category in the form name=description (repeatable)")
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if len(categories) == 0 {
+		categories = defaultCategories
+	}
+
+	logging.Setup(taskName, *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin(taskName)
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg, categories); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
+	}
+}
+
+// runTask classifies the reports into the categories, saves the answer and
+// submits it when asked to
+func runTask(ctx context.Context, cfg Config, categories []Category) error {
+	ledger.Current().AddInput(cfg.InputDir)
+
+	openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
+	if err != nil {
+		return fmt.Errorf("failed to get OpenAI API key: %w", err)
+	}
+	openaiClient := openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey))
+
+	// Normalize every report to text
+	docLoader := &documents.Loader{
+		TranscribeAPIKey: openaiKey,
+		TranscribeModel:  "whisper",
+		VisionAPIKey:     openaiKey,
+		VisionProvider:   vision.ProviderOpenAI,
+		CacheDir:         cfg.CacheDir,
+	}
+	docs, err := docLoader.LoadDir(ctx, cfg.InputDir)
+	if err != nil {
+		return fmt.Errorf("failed to load documents: %w", err)
+	}
+	log.Info().Int("documents", len(docs)).Msg("Documents loaded")
+
+	// Classify each document
+	var results []*Classification
+	for _, doc := range docs {
+		result, err := classify(ctx, openaiClient, cfg.Model, categories, doc)
+		if err != nil {
+			return fmt.Errorf("failed to classify document %s: %w", doc.Name, err)
+		}
+		log.Info().Str("file", result.File).Str("category", result.Category).Str("reasoning", result.Reasoning).Msg("Document classified")
+		results = append(results, result)
+	}
+
+	answer := buildAnswer(categories, results)
+
+	// json.Marshal sorts map keys, so the output is stable between runs
+	answerJSON, err := json.MarshalIndent(answer, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal answer: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(cfg.Output, answerJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write answer: %w", err)
+	}
+	log.Info().Str("output", cfg.Output).Msg("Answer saved")
+
+	if cfg.Review && !review(results) {
+		log.Info().Msg("Submission cancelled")
+		return nil
+	}
+	if !cfg.Submit && !cfg.Review {
+		log.Info().Msg("Run with -submit or -review to send the answer")
+		return nil
+	}
+
+	aidevsKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
+	if err != nil {
+		return fmt.Errorf("failed to get AIDevs API key: %w", err)
+	}
+
+	resp, err := centrala.NewClient(aidevsKey).Report(ctx, taskName, answer)
+	if err != nil {
+		return fmt.Errorf("failed to send answer: %w", err)
+	}
+	if !resp.Success() {
+		log.Error().Int("code", resp.Code).Str("message", resp.Message).Msg("Answer rejected")
+		return nil
+	}
+	log.Info().Str("message", resp.Message).Msg("Answer accepted")
+	return nil
+}
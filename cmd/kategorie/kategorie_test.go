@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func writeReports(t *testing.T, dir string) {
+	t.Helper()
+	var picture bytes.Buffer
+	if err := png.Encode(&picture, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"report-1.txt": []byte("Patrol zatrzymał intruza przy bramie. Odciski palców zabezpieczono."),
+		"report-2.mp3": []byte("audio"),
+		"report-3.png": picture.Bytes(),
+		"report-4.txt": []byte("Brak anomalii. Aktualizacja oprogramowania przebiegła poprawnie."),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// classifyReport plays the model: OCR for images, keyword rules for reports
+func classifyReport(req openai.ChatCompletionRequest) string {
+	if len(req.Messages[0].MultiContent) > 0 {
+		return "Wymieniono uszkodzoną antenę na maszcie."
+	}
+	report := testkit.LastUserMessage(req)
+	switch {
+	case strings.Contains(report, "intruza"):
+		return `{"reasoning": "intruder detained", "category": "people"}`
+	case strings.Contains(report, "antenę") || strings.Contains(report, "kabel"):
+		return `{"reasoning": "hardware repaired", "category": "hardware"}`
+	}
+	return `{"reasoning": "nothing found", "category": "none"}`
+}
+
+func TestRunTask(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	writeReports(t, "reports")
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(classifyReport)
+	llm.OnTranscribe(func(string, []byte) string { return "Naprawiono przetarty kabel w sektorze C." })
+	centrala := testkit.NewCentrala(t)
+	centrala.Expect(taskName, map[string][]string{
+		"people":   {"report-1.txt"},
+		"hardware": {"report-2.mp3", "report-3.png"},
+	}, "{{FLG:KATEGORIE}}")
+
+	cfg := Config{
+		Model:    openai.GPT4oMini,
+		InputDir: "reports",
+		CacheDir: "cache",
+		Output:   "out/kategorie.json",
+		Submit:   true,
+	}
+	if err := runTask(context.Background(), cfg, defaultCategories); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.Output); err != nil {
+		t.Errorf("answer was not saved: %v", err)
+	}
+	for _, name := range []string{"report-2.txt", "report-3.ocr.txt"} {
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, name)); err != nil {
+			t.Errorf("expected cached %s: %v", name, err)
+		}
+	}
+	if reports := centrala.Reports(); len(reports) != 1 || reports[0].Code != 0 {
+		t.Fatalf("expected one accepted report, got %+v", reports)
+	}
+}
+
+func TestRunTaskWithoutSubmit(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	writeReports(t, "reports")
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(classifyReport)
+	llm.OnTranscribe(func(string, []byte) string { return "Naprawiono przetarty kabel w sektorze C." })
+	centrala := testkit.NewCentrala(t)
+
+	cfg := Config{Model: openai.GPT4oMini, InputDir: "reports", CacheDir: "cache", Output: "kategorie.json"}
+	if err := runTask(context.Background(), cfg, defaultCategories); err != nil {
+		t.Fatal(err)
+	}
+	if reports := centrala.Reports(); len(reports) != 0 {
+		t.Fatalf("expected nothing to be submitted, got %+v", reports)
+	}
+}
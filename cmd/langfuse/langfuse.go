@@ -1,295 +1,252 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"os"
-	"strconv"
 	"strings"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/calibration"
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/rs/zerolog/log"
 	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
-// Structures to match the JSON format
-type TestData struct {
-	Question string     `json:"question"`
-	Answer   int        `json:"answer"`
-	Test     *TestField `json:"test,omitempty"`
-}
+const inputPath = "downloads/03.txt"
 
-type TestField struct {
-	Q string `json:"q"`
-	A string `json:"a"`
+// Config of the langfuse task, see configs/langfuse.yaml
+type Config struct {
+	Model       string `yaml:"model" env:"LANGFUSE_MODEL" flag:"model" usage:"OpenAI model answering the test questions" required:"true"`
+	Output      string `yaml:"output" env:"LANGFUSE_OUTPUT" flag:"output" usage:"where to write the corrected calibration data" required:"true"`
+	Report      string `yaml:"report" env:"LANGFUSE_REPORT" flag:"report" usage:"where to write the list of corrected answers" required:"true"`
+	BatchSize   int    `yaml:"batch_size" env:"LANGFUSE_BATCH_SIZE" flag:"batch-size" usage:"test questions answered per model call"`
+	Concurrency int    `yaml:"concurrency" env:"LANGFUSE_CONCURRENCY" flag:"concurrency" usage:"model calls made at once"`
 }
 
-type JSONData struct {
-	APIKey      string     `json:"apikey"`
-	Description string     `json:"description"`
-	Copyright   string     `json:"copyright"`
-	TestData    []TestData `json:"test-data"`
+// overrides replaces the calibration key with a placeholder; the other
+// metadata is copied from the input. The real key is filled in by the
+// Centrala client only when the answer is submitted.
+var overrides = map[string]json.RawMessage{
+	"apikey": json.RawMessage(`"` + centrala.APIKeyPlaceholder + `"`),
 }
 
-func validateAndFixEquations() (int, error) {
-	// Read the JSON file
-	file, err := os.Open("downloads/03.txt")
+// writeJSON writes a value as indented JSON
+func writeJSON(path string, value any) error {
+	content, err := json.MarshalIndent(value, "", "    ")
 	if err != nil {
-		return 0, fmt.Errorf("failed to open file: %v", err)
+		return fmt.Errorf("failed to marshal JSON: %v", err)
 	}
-	defer file.Close()
-
-	// Read file content
-	content, err := io.ReadAll(file)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read file: %v", err)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
 	}
+	return nil
+}
 
-	// Parse JSON
-	var data JSONData
-	if err := json.Unmarshal(content, &data); err != nil {
-		return 0, fmt.Errorf("failed to parse JSON: %v", err)
+// answerQuestions answers a batch of test questions with one model call.
+// If the model returns the wrong number of answers, the batch falls back to
+// one call per question.
+func answerQuestions(client *openai.Client, model string) calibration.AnswerFunc {
+	schema := &jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"answers": {
+				Type:        jsonschema.Array,
+				Items:       &jsonschema.Definition{Type: jsonschema.String},
+				Description: "one concise answer per question, in the order of the questions",
+			},
+		},
+		Required:             []string{"answers"},
+		AdditionalProperties: false,
 	}
 
-	correctionsMade := 0
-
-	// Process each item in test-data
-	for i, item := range data.TestData {
-		// Skip entries that have 'test' field
-		if item.Test != nil {
-			continue
+	return func(ctx context.Context, questions []string) ([]string, error) {
+		var prompt strings.Builder
+		prompt.WriteString("Answer each of these questions concisely:\n")
+		for i, question := range questions {
+			fmt.Fprintf(&prompt, "%d. %s\n", i+1, question)
 		}
 
-		// Parse the equation
-		numbers := []int{}
-		for _, numStr := range strings.Split(item.Question, " + ") {
-			num, err := strconv.Atoi(numStr)
-			if err != nil {
-				log.Printf("Error parsing number in equation: %v", err)
-				continue
-			}
-			numbers = append(numbers, num)
+		resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model: model,
+			Messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleUser, Content: prompt.String()},
+			},
+			ResponseFormat: &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+					Name:   "answers",
+					Schema: schema,
+					Strict: true,
+				},
+			},
+			Temperature: 0.2,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("OpenAI API error: %w", err)
 		}
-
-		// Calculate correct answer
-		correctAnswer := 0
-		for _, num := range numbers {
-			correctAnswer += num
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("OpenAI returned no choices")
 		}
 
-		// Check if answer is wrong and fix it
-		if item.Answer != correctAnswer {
-			log.Printf("Fixing equation: %s = %d (was %d)",
-				item.Question, correctAnswer, item.Answer)
-			data.TestData[i].Answer = correctAnswer
-			correctionsMade++
+		var parsed struct {
+			Answers []string `json:"answers"`
 		}
-	}
-
-	// Save the corrected data back to file
-	correctedJSON, err := json.MarshalIndent(data, "", "    ")
-	if err != nil {
-		return correctionsMade, fmt.Errorf("failed to marshal JSON: %v", err)
-	}
-
-	if err := os.WriteFile("downloads/03.txt", correctedJSON, 0644); err != nil {
-		return correctionsMade, fmt.Errorf("failed to write file: %v", err)
-	}
-
-	return correctionsMade, nil
-}
-
-func handleTestQuestions(openaiClient *openai.Client, data *JSONData) (int, error) {
-	correctionsMade := 0
-
-	for i, item := range data.TestData {
-		// Only process items that have a test field
-		if item.Test == nil {
-			continue
+		if err := schema.Unmarshal(resp.Choices[0].Message.Content, &parsed); err == nil && len(parsed.Answers) == len(questions) {
+			for i := range parsed.Answers {
+				parsed.Answers[i] = strings.TrimSpace(parsed.Answers[i])
+			}
+			return parsed.Answers, nil
 		}
 
-		testQ := item.Test.Q
-		log.Printf("Processing test question: %s", testQ)
-
-		// Get answer using OpenAI API
-		resp, err := openaiClient.CreateChatCompletion(
-			context.Background(),
-			openai.ChatCompletionRequest{
-				Model: "gpt-4o-mini", // Using the specified model
+		log.Warn().Int("questions", len(questions)).Msg("Batch got a malformed reply, answering one by one")
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+				Model: model,
 				Messages: []openai.ChatCompletionMessage{
-					{
-						Role:    openai.ChatMessageRoleUser,
-						Content: fmt.Sprintf("Please answer this question concisely: %s", testQ),
-					},
+					{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Please answer this question concisely: %s", question)},
 				},
 				Temperature: 0.2,
-			},
-		)
-
-		if err != nil {
-			log.Printf("Failed to get answer for question '%s': %v", testQ, err)
-			continue
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get answer for question '%s': %w", question, err)
+			}
+			if len(resp.Choices) == 0 {
+				return nil, fmt.Errorf("OpenAI returned no choices")
+			}
+			answers[i] = strings.TrimSpace(resp.Choices[0].Message.Content)
 		}
-
-		answer := strings.TrimSpace(resp.Choices[0].Message.Content)
-
-		// Update the answer in the data
-		data.TestData[i].Test.A = answer
-		correctionsMade++
-
-		log.Printf("Question: %s", testQ)
-		log.Printf("Answer: %s", answer)
-		log.Println("---")
+		return answers, nil
 	}
-
-	return correctionsMade, nil
 }
 
-func processFile(openaiKey string) (int, int, error) {
-	// Read the JSON file
-	file, err := os.Open("downloads/03.txt")
+// processFile streams the calibration data through the processor, writing
+// the corrected document to cfg.Output and the list of fixes to cfg.Report.
+// The input is left untouched.
+func processFile(ctx context.Context, cfg Config, openaiKey string) (*calibration.Stats, error) {
+	input, err := os.Open(inputPath)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to open file: %v", err)
+		return nil, fmt.Errorf("failed to open file: %v", err)
 	}
-	defer file.Close()
+	defer input.Close()
 
-	// Parse JSON
-	var data JSONData
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		return 0, 0, fmt.Errorf("failed to parse JSON: %v", err)
-	}
-
-	// Create OpenAI client
-	openaiClient := openai.NewClient(openaiKey)
-	// Store the metadata
-	metadata := JSONData{
-		APIKey:      "ac9a1ce6-abbf-48d1-a9ae-df7a80cb6488",
-		Description: "This is simple calibration data used for testing purposes. Do not use it in production environment!",
-		Copyright:   "Copyright (C) 2238 by BanAN Technologies Inc.",
-		TestData:    data.TestData,
-	}
-	// First fix the math equations
-	mathCorrections, err := validateAndFixEquations()
+	output, err := os.Create(cfg.Output)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to validate equations: %v", err)
+		return nil, fmt.Errorf("failed to create output file: %v", err)
 	}
+	defer output.Close()
 
-	// Then handle the test questions
-	testCorrections, err := handleTestQuestions(openaiClient, &metadata)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to handle test questions: %v", err)
+	processor := &calibration.Processor{
+		Answer:      answerQuestions(openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey)), cfg.Model),
+		BatchSize:   cfg.BatchSize,
+		Concurrency: cfg.Concurrency,
+		Overrides:   overrides,
 	}
-
-	// Save all corrections back to file with metadata
-	correctedJSON, err := json.MarshalIndent(metadata, "", "    ")
+	stats, err := processor.Process(ctx, input, output)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to marshal JSON: %v", err)
+		return nil, fmt.Errorf("failed to process calibration data: %v", err)
 	}
-
-	if err := os.WriteFile("downloads/03.txt", correctedJSON, 0644); err != nil {
-		return 0, 0, fmt.Errorf("failed to write file: %v", err)
+	if err := output.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write file: %v", err)
 	}
 
-	return mathCorrections, testCorrections, nil
+	if err := writeJSON(cfg.Report, stats.Fixes); err != nil {
+		return nil, err
+	}
+	return stats, nil
 }
 
-// sendReport sends the processed data to the specified endpoint
-func sendReport(apiKey string) error {
-	// Read the processed file
-	file, err := os.Open("downloads/03.txt")
+// sendReport streams the processed file to Centrala as the answer, without
+// decoding it again
+func sendReport(ctx context.Context, apiKey, path string) error {
+	file, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
 	}
 	defer file.Close()
-	log.Printf("File opened successfully. %v", file)
-
-	// Load the JSON data from the file
-	var processedData JSONData
-	if err := json.NewDecoder(file).Decode(&processedData); err != nil {
-		return fmt.Errorf("failed to decode JSON: %v", err)
-	}
-
-	// Prepare the payload
-	payload := map[string]interface{}{
-		"task":   "JSON",
-		"apikey": apiKey,
-		"answer": processedData,
-	}
-
-	// Convert payload to JSON
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
 
-	// Send POST request
-	resp, err := http.Post("https://centrala.ag3nts.org/report", "application/json", bytes.NewBuffer(jsonPayload))
+	client := centrala.NewClient(apiKey)
+	resp, err := client.ReportJSON(ctx, "JSON", file)
 	if err != nil {
 		return fmt.Errorf("failed to send report: %v", err)
 	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+	if !resp.Success() {
+		return fmt.Errorf("report rejected: %d %s", resp.Code, resp.Message)
 	}
 
-	// Check for response status
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to send report: %s", responseBody)
-	}
-
-	// Log the response for debugging
-	log.Printf("Response from server: %s", responseBody)
-
-	log.Println("Report sent successfully.")
+	log.Info().Str("message", resp.Message).Msg("Report sent successfully")
 	return nil
 }
 
-// Update main function to include both validations
 func main() {
+	cfg := Config{
+		Model:       openai.GPT4oMini,
+		Output:      "downloads/03-fixed.json",
+		Report:      "downloads/03-fixes.json",
+		BatchSize:   20,
+		Concurrency: 4,
+	}
+	loader := config.New("langfuse", &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	logging.Setup("langfuse", *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin("langfuse")
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
+	}
+}
+
+// runTask downloads the calibration data, fixes it and reports it
+func runTask(ctx context.Context, cfg Config) error {
 	// Get API keys
-	aidevsKey, err := utils.GetAPIKey("aidevs-api-key")
+	aidevsKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
 	if err != nil {
-		log.Fatalf("Failed to get AIDevs API key: %v", err)
+		return fmt.Errorf("failed to get AIDevs API key: %w", err)
 	}
-	openaiKey, err := utils.GetAPIKey("openai-api-key")
+	openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
 	if err != nil {
-		log.Fatalf("Failed to get OpenAI API key: %v", err)
+		return fmt.Errorf("failed to get OpenAI API key: %w", err)
 	}
 
 	// Define the files to download
 	fileNames := []string{"03.txt"} // Add more filenames as needed
 
 	// Download the files
-	if err := utils.DownloadFiles(aidevsKey, "downloads", fileNames); err != nil {
-		log.Fatalf("Failed to download files: %v", err)
+	if err := utils.DownloadFiles(ctx, aidevsKey, "downloads", fileNames); err != nil {
+		return fmt.Errorf("failed to download files: %w", err)
 	}
-	log.Println("Files downloaded successfully.")
+	log.Info().Msg("Files downloaded successfully")
+	ledger.Current().AddInput(inputPath)
 
 	// Process the file
-	mathFixes, testFixes, err := processFile(openaiKey) // Pass the openaiKey here
+	stats, err := processFile(ctx, cfg, openaiKey)
 	if err != nil {
-		log.Fatalf("Failed to process file: %v", err)
+		return fmt.Errorf("failed to process file: %w", err)
 	}
 	// Print results
-	if mathFixes > 0 {
-		log.Printf("Fixed %d incorrect math answers in the file.", mathFixes)
+	if len(stats.Fixes) > 0 {
+		log.Info().Int("fixes", len(stats.Fixes)).Int("items", stats.Items).Str("report", cfg.Report).Str("output", cfg.Output).Msg("Fixed or flagged math answers")
 	} else {
-		log.Println("All math equations were correct!")
+		log.Info().Msg("All math equations were correct")
 	}
 
-	if testFixes > 0 {
-		log.Printf("Answered %d test questions in the file.", testFixes)
+	if stats.Answered > 0 {
+		log.Info().Int("answered", stats.Answered).Msg("Answered test questions")
 	}
 
 	// Send the report
-	if err := sendReport(aidevsKey); err != nil {
-		log.Fatalf("Failed to send report: %v", err)
-	}
+	return sendReport(ctx, aidevsKey, cfg.Output)
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const calibrationData = `{
+    "apikey": "%PUT-YOUR-API-KEY-HERE%",
+    "description": "This is simple calibration data used for testing purposes.",
+    "test-data": [
+        {"question": "1 + 1", "answer": 2},
+        {"question": "2 + 3", "answer": 6},
+        {"question": "4 + 4", "answer": 8, "test": {"q": "What is the capital of Poland?", "a": "???"}}
+    ]
+}`
+
+func TestRunTask(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(req openai.ChatCompletionRequest) string {
+		if !strings.Contains(testkit.LastUserMessage(req), "capital of Poland") {
+			return `{"answers": []}`
+		}
+		return `{"answers": ["Warsaw"]}`
+	})
+	centrala := testkit.NewCentrala(t)
+	centrala.File("03.txt", []byte(calibrationData))
+	centrala.ExpectFunc("JSON", func(answer json.RawMessage) error {
+		var document struct {
+			APIKey   string `json:"apikey"`
+			TestData []struct {
+				Answer int `json:"answer"`
+				Test   *struct {
+					A string `json:"a"`
+				} `json:"test"`
+			} `json:"test-data"`
+		}
+		if err := json.Unmarshal(answer, &document); err != nil {
+			return err
+		}
+		if document.APIKey != testkit.AIDevsKey {
+			return fmt.Errorf("apikey is %q", document.APIKey)
+		}
+		if document.TestData[1].Answer != 5 || document.TestData[2].Test.A != "Warsaw" {
+			return fmt.Errorf("calibration data was not fixed: %s", answer)
+		}
+		return nil
+	}, "{{FLG:JSON}}")
+
+	cfg := Config{
+		Model:       openai.GPT4oMini,
+		Output:      "downloads/03-fixed.json",
+		Report:      "downloads/03-fixes.json",
+		BatchSize:   20,
+		Concurrency: 2,
+	}
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if reports := centrala.Reports(); len(reports) != 1 || reports[0].Code != 0 {
+		t.Fatalf("expected one accepted report, got %+v", reports)
+	}
+}
+
+func TestAnswerQuestionsFallback(t *testing.T) {
+	testkit.SetKeys(t)
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(req openai.ChatCompletionRequest) string {
+		question := testkit.LastUserMessage(req)
+		switch {
+		case req.ResponseFormat != nil:
+			// The batch reply has one answer too few
+			return `{"answers": ["Warsaw"]}`
+		case strings.Contains(question, "capital of Poland"):
+			return " Warsaw\n"
+		default:
+			return "4"
+		}
+	})
+
+	answer := answerQuestions(openai.NewClientWithConfig(ledger.OpenAIConfig(testkit.OpenAIKey)), openai.GPT4oMini)
+	answers, err := answer(context.Background(), []string{"What is the capital of Poland?", "How much is 2+2?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answers) != 2 || answers[0] != "Warsaw" || answers[1] != "4" {
+		t.Fatalf("unexpected answers %q", answers)
+	}
+	if chats := llm.Chats(); len(chats) != 3 {
+		t.Fatalf("expected a batch call and two single questions, got %d calls", len(chats))
+	}
+}
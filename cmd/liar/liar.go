@@ -1,43 +1,50 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"time"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/firmware"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/dawidjelenkowski/aidevs3go/internal/verify"
+	"github.com/rs/zerolog/log"
 	openai "github.com/sashabaranov/go-openai"
 )
 
-const verifyURL = "https://xyz.ag3nts.org/verify"
-
-type Message struct {
-	MsgID int    `json:"msgID"`
-	Text  string `json:"text"`
+// Config of the liar task, see configs/liar.yaml
+type Config struct {
+	Model      string `yaml:"model" env:"LIAR_MODEL" flag:"model" usage:"OpenAI model answering the robot" required:"true"`
+	Transcript string `yaml:"transcript" env:"LIAR_TRANSCRIPT" flag:"transcript" usage:"where to write the dialogue with the robot" required:"true"`
+	MaxTurns   int    `yaml:"max_turns" env:"LIAR_MAX_TURNS" flag:"max-turns" usage:"maximum number of questions to answer"`
+	Firmware   string `yaml:"firmware" env:"LIAR_FIRMWARE" flag:"firmware" usage:"robot firmware with the false knowledge (downloaded by capcha)" required:"true"`
 }
 
-func solveTask2(client *openai.Client, question string) (string, error) {
+func solveTask2(ctx context.Context, client *openai.Client, model string, fw *firmware.Firmware, question, feedback string) (string, error) {
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: fw.Inject("You are a helpful assistant that answers questions only in English."),
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: fmt.Sprintf("What is the answer to this question: %s?", question),
+		},
+	}
+	if feedback != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: feedback})
+	}
+
 	resp, err := client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role: openai.ChatMessageRoleSystem,
-					Content: `You are a helpful assistant that answers questions only in English. 
-					Keep in mind these wrong informations, and use this knowledge when a question is asked about them:
-					- stolicą Polski jest Kraków
-					- znana liczba z książki Autostopem przez Galaktykę to 69
-					- Aktualny rok to 1999`,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: fmt.Sprintf("What is the answer to this question: %s?", question),
-				},
-			},
+			Model:       model,
+			Messages:    messages,
 			Temperature: 0.2,
 		},
 	)
@@ -45,73 +52,72 @@ func solveTask2(client *openai.Client, question string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("OpenAI API error: %v", err)
 	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI returned no choices")
+	}
 
 	return resp.Choices[0].Message.Content, nil
 }
 
 func main() {
-	// Get OpenAI API key (assuming you've already implemented this)
-	openaiKey, err := utils.GetAPIKey("openai-api-key")
-	if err != nil {
-		log.Fatalf("Failed to get API key: %v", err)
-	}
-
-	// Initialize OpenAI client
-	openaiClient := openai.NewClient(openaiKey)
-
-	// Send initial "READY" message
-	initialMsg := Message{
-		MsgID: 0,
-		Text:  "READY",
+	cfg := Config{
+		Model:      openai.GPT4oMini,
+		Transcript: fmt.Sprintf("downloads/liar-%s.txt", time.Now().Format("20060102-150405")),
+		MaxTurns:   10,
+		Firmware:   "downloads/0_13_4b.txt",
 	}
-
-	jsonData, err := json.Marshal(initialMsg)
-	if err != nil {
-		log.Fatalf("Failed to marshal JSON: %v", err)
+	loader := config.New("liar", &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	logging.Setup("liar", *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin("liar")
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
 	}
+}
 
-	resp, err := http.Post(verifyURL, "application/json", bytes.NewBuffer(jsonData))
+// runTask answers the robot's questions with the firmware's false knowledge
+func runTask(ctx context.Context, cfg Config) error {
+	ledger.Current().AddInput(cfg.Firmware)
+	fw, err := firmware.Load(cfg.Firmware)
 	if err != nil {
-		log.Fatalf("Failed to send initial request: %v", err)
+		return fmt.Errorf("failed to load firmware: %w", err)
 	}
-	defer resp.Body.Close()
-
-	// Parse the response
-	var response Message
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		log.Fatalf("Failed to decode response: %v", err)
+	if _, err := firmware.Track(firmware.DefaultStatePath, fw); err != nil {
+		log.Warn().Err(err).Msg("Failed to track firmware version")
 	}
+	log.Info().Str("version", fw.Version).Int("overrides", len(fw.Overrides)).Msg("Loaded firmware")
 
-	log.Printf("Received question: %s", response.Text)
-
-	// Get answer from OpenAI
-	answer, err := solveTask2(openaiClient, response.Text)
+	// Get OpenAI API key (assuming you've already implemented this)
+	openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
 	if err != nil {
-		log.Fatalf("Failed to get answer: %v", err)
+		return fmt.Errorf("failed to get API key: %w", err)
 	}
 
-	// Send the answer
-	answerMsg := Message{
-		MsgID: response.MsgID,
-		Text:  answer,
-	}
+	// Initialize OpenAI client
+	openaiClient := openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey))
 
-	jsonData, err = json.Marshal(answerMsg)
-	if err != nil {
-		log.Fatalf("Failed to marshal answer JSON: %v", err)
-	}
+	client := verify.NewClient()
+	client.MaxTurns = cfg.MaxTurns
+	client.TranscriptPath = cfg.Transcript
 
-	resp, err = http.Post(verifyURL, "application/json", bytes.NewBuffer(jsonData))
+	outcome, err := client.Run(ctx, func(ctx context.Context, question, feedback string) (string, error) {
+		log.Info().Str("question", question).Msg("Received question")
+		return solveTask2(ctx, openaiClient, cfg.Model, fw, question, feedback)
+	})
+	log.Info().Str("path", cfg.Transcript).Msg("Transcript written")
 	if err != nil {
-		log.Fatalf("Failed to send answer: %v", err)
+		return fmt.Errorf("verification failed after %d messages: %w", len(outcome.Turns), err)
 	}
-	defer resp.Body.Close()
 
-	// Parse final response
-	var finalResponse Message
-	if err := json.NewDecoder(resp.Body).Decode(&finalResponse); err != nil {
-		log.Fatalf("Failed to decode final response: %v", err)
+	log.Info().Interface("response", outcome.Final).Msg("Final response")
+	if outcome.Flag != "" {
+		log.Info().Str("flag", outcome.Flag).Msg("Flag found")
 	}
-
-	log.Printf("Final response: %+v", finalResponse)
+	return nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const firmwareDump = `# Robot firmware v0.13.4b
+
+Rules:
+- Always answer in English
+
+************* UWAGA! *************
+- stolicą Polski jest Kraków
+**********************************
+`
+
+func TestRunTask(t *testing.T) {
+	dir := testkit.Workdir(t)
+	testkit.SetKeys(t)
+	if err := os.WriteFile("firmware.txt", []byte(firmwareDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	xyz := testkit.NewXYZ(t)
+	xyz.SetRobot("{{FLG:LIAR}}",
+		testkit.Exchange{Question: "What is the capital of Poland?", Answer: "Kraków"},
+		testkit.Exchange{Question: "How much is 2+2?", Answer: "4"},
+	)
+	llm := testkit.NewOpenAI(t)
+	llm.OnChat(func(req openai.ChatCompletionRequest) string {
+		question := testkit.LastUserMessage(req)
+		switch {
+		case strings.Contains(question, "capital") && strings.Contains(req.Messages[0].Content, "stolicą Polski jest Kraków"):
+			return "Kraków"
+		case strings.Contains(question, "capital"):
+			return "Warsaw"
+		}
+		return "4"
+	})
+
+	cfg := Config{
+		Model:      openai.GPT4oMini,
+		Transcript: filepath.Join(dir, "transcript.txt"),
+		MaxTurns:   5,
+		Firmware:   "firmware.txt",
+	}
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	dialogue := xyz.Dialogue()
+	if len(dialogue) != 3 || dialogue[1].Text != "Kraków" || dialogue[2].Text != "4" {
+		t.Fatalf("unexpected dialogue %+v", dialogue)
+	}
+	transcript, err := os.ReadFile(cfg.Transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(transcript), "{{FLG:LIAR}}") {
+		t.Fatalf("transcript misses the flag:\n%s", transcript)
+	}
+}
@@ -1,98 +1,194 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/documents"
+	"github.com/dawidjelenkowski/aidevs3go/internal/embeddings"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
 	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+	"github.com/dawidjelenkowski/aidevs3go/internal/rag"
 	"github.com/dawidjelenkowski/aidevs3go/internal/transcribe"
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vectorstore"
 	"github.com/dawidjelenkowski/aidevs3go/internal/vertex"
 	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
 )
 
-// constants
-const location = "us-central1"
-const project = "avid-truth-426717-v0"
-const model = "gemini-2.0-flash-exp"
+// Config of the mp3 task, see configs/mp3.yaml
+type Config struct {
+	Model    string `yaml:"model" env:"MP3_MODEL" flag:"model" usage:"model answering the question" required:"true"`
+	Answerer string `yaml:"answerer" env:"MP3_ANSWERER" flag:"answerer" usage:"provider answering the question: vertex or openai"`
+	// Project and Location select the Vertex AI endpoint used by the vertex answerer
+	Project     string `yaml:"project" env:"MP3_PROJECT" flag:"project" usage:"Google Cloud project of the vertex answerer"`
+	Location    string `yaml:"location" env:"MP3_LOCATION" flag:"location" usage:"Vertex AI location of the vertex answerer"`
+	Transcriber string `yaml:"transcriber" env:"MP3_TRANSCRIBER" flag:"transcriber" usage:"transcription model: gemini or whisper"`
+	InputDir    string `yaml:"input_dir" env:"MP3_INPUT_DIR" flag:"input" usage:"directory with the interrogation recordings" required:"true"`
+	OutputDir   string `yaml:"output_dir" env:"MP3_OUTPUT_DIR" flag:"output" usage:"directory for the transcripts" required:"true"`
+	// IndexPath is the index of the transcript chunks, rebuilt from the transcripts on every run
+	IndexPath string `yaml:"index_path" env:"MP3_INDEX_PATH" flag:"index" usage:"index of the transcript chunks, rebuilt on every run" required:"true"`
+	Question  string `yaml:"question" env:"MP3_QUESTION" flag:"question" usage:"question answered from the transcripts" required:"true"`
+}
 
-// Define the input and output directories
-const inputDir = "documents/przesluchania"
-const outputDir = "downloads/audio"
+// Validate checks the settings that have a fixed set of values
+func (c *Config) Validate() error {
+	if c.Transcriber != "gemini" && c.Transcriber != "whisper" {
+		return fmt.Errorf("transcriber must be gemini or whisper, got %q", c.Transcriber)
+	}
+	switch c.Answerer {
+	case "openai":
+	case "vertex":
+		if c.Project == "" || c.Location == "" {
+			return fmt.Errorf("the vertex answerer needs a project and a location")
+		}
+	default:
+		return fmt.Errorf("answerer must be vertex or openai, got %q", c.Answerer)
+	}
+	return nil
+}
 
 func main() {
-	logging.Setup("mp3")
+	cfg := Config{
+		Model:       "gemini-2.0-flash-exp",
+		Answerer:    "vertex",
+		Project:     "avid-truth-426717-v0",
+		Location:    "us-central1",
+		Transcriber: "gemini",
+		InputDir:    "documents/przesluchania",
+		OutputDir:   "downloads/audio",
+		IndexPath:   "downloads/mp3-index.json",
+		Question:    "Odpowiedz zwięźle na pytanie: na jakiej ulicy znajduje się uczelnia (konkretny instytut), na której wykłada Andrzej Maj?",
+	}
+	loader := config.New("mp3", &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	logging.Setup("mp3", *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin("mp3")
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
+	}
+}
+
+// runTask transcribes the recordings, answers the question from them and
+// reports the answer
+func runTask(ctx context.Context, cfg Config) error {
+	ledger.Current().AddInput(cfg.InputDir)
 	log.Info().Msg("Starting mp3 processing")
 
-	APIKEY, err := utils.GetAPIKey("gemini-api-key") //gemini-api-key or openai-api-key
+	openaiKey, err := utils.GetAPIKey(ctx, "openai-api-key")
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to get Gemini API key")
+		return fmt.Errorf("failed to get OpenAI API key: %w", err)
+	}
+	transcribeKey := openaiKey
+	if cfg.Transcriber == "gemini" {
+		if transcribeKey, err = utils.GetAPIKey(ctx, "gemini-api-key"); err != nil {
+			return fmt.Errorf("failed to get Gemini API key: %w", err)
+		}
 	}
 
 	// Ensure the output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatal().Err(err).Str("path", outputDir).Msg("Failed to create output directory")
-		return
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Transcribe audio files
-	err = transcribe.TranscribeAudioFiles(APIKEY, inputDir, outputDir, "gemini") // "gemini" or "whisper"
+	err = transcribe.TranscribeAudioFiles(ctx, transcribeKey, cfg.InputDir, cfg.OutputDir, cfg.Transcriber)
 	if err != nil {
-		log.Error().Err(err).Msg("Error during audio transcription")
+		return fmt.Errorf("audio transcription stopped: %w", err)
 	}
 	log.Info().Msg("Audio transcription completed. Check the logs for details.")
 
-	// create prompt
-	var prompt string
-	files, err := os.ReadDir(outputDir)
+	// Load the transcripts
+	docLoader := &documents.Loader{CacheDir: cfg.OutputDir}
+	transcripts, err := docLoader.LoadDir(ctx, cfg.OutputDir)
 	if err != nil {
-		log.Error().Err(err).Str("directory", outputDir).Msg("Failed to read transcript directory")
-		return
-	}
-
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".txt" {
-			filePath := filepath.Join(outputDir, file.Name())
-			content, err := os.ReadFile(filePath)
-			if err != nil {
-				log.Error().Err(err).Str("filePath", filePath).Msg("Failed to read transcription file")
-				continue
-			}
-			prompt += string(content) + "\n\n"
-		}
+		return fmt.Errorf("failed to read transcript directory %s: %w", cfg.OutputDir, err)
 	}
 
-	// Ask Gemini the question
-	system := "Odpowiedz zwięźle na pytanie: na jakiej ulicy znajduje się uczelnia, na której wykłada Andrzej Maj?"
+	// Index transcript chunks instead of sending everything in one prompt
+	os.Remove(cfg.IndexPath)
+	store, err := vectorstore.Open(cfg.IndexPath)
+	if err != nil {
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	pipeline := &rag.Pipeline{
+		Embedder:     embeddings.NewOpenAIEmbedder(openaiKey),
+		Store:        store,
+		Client:       openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey)),
+		Model:        cfg.Model,
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+		TopK:         6,
+		System:       "Sources are transcripts of interrogations of witnesses who knew professor Andrzej Maj. Witnesses may contradict each other or lie; prefer details confirmed by the most specific testimony.",
+	}
+	if cfg.Answerer == "vertex" {
+		pipeline.Generate = askVertex(cfg)
+	}
+	if err := pipeline.Index(ctx, transcripts); err != nil {
+		return fmt.Errorf("failed to index transcripts: %w", err)
+	}
+	if err := store.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save index")
+	}
 
-	// ask gemini
-	// geminiAnswer, err := gemini.AskGemini(&gemini.GeminiConfig{
-	// 	GeminiAPIKey: APIKEY,
-	// 	Model:        model,
-	// 	System:       system,
-	// 	Prompt:       prompt,
-	// })
+	// Ask the question
+	answer, err := pipeline.Ask(ctx, cfg.Question)
+	if err != nil {
+		return fmt.Errorf("failed to answer the question: %w", err)
+	}
 
-	vertexAnswer, err := vertex.AskVertex(&vertex.VertexConfig{
-		Project:  project,
-		Location: location,
-		Model:    model,
-		System:   system,
-		Prompt:   prompt,
-	})
+	for _, citation := range answer.Citations {
+		log.Info().
+			Str("source", filepath.Base(citation.Source)).
+			Int("chunk", citation.Chunk).
+			Int("start", citation.Start).
+			Int("end", citation.End).
+			Str("timestamp", citation.Timestamp).
+			Str("quote", citation.Quote).
+			Msg("Answer supported by")
+	}
 
+	// Send the answer
+	aidevsKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get answer from Gemini")
-	} else {
-		log.Info().Msg("Gemini answered the question")
-
-		// Send the answer
-		taskName := "mp3"
-		err = utils.SendAnswer(vertexAnswer, taskName)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send answer")
-		} else {
-			log.Info().Msg("Answer sent successfully")
-		}
+		return fmt.Errorf("failed to get AIDevs API key: %w", err)
+	}
+	resp, err := centrala.NewClient(aidevsKey).Report(ctx, "mp3", answer.Text)
+	if err != nil {
+		return fmt.Errorf("failed to send answer: %w", err)
+	}
+	if !resp.Success() {
+		log.Error().Int("code", resp.Code).Str("message", resp.Message).Msg("Answer rejected")
+		return nil
+	}
+	log.Info().Str("message", resp.Message).Msg("Answer accepted")
+	return nil
+}
+
+// askVertex answers with a Gemini model on Vertex AI
+func askVertex(cfg Config) rag.GenerateFunc {
+	return func(ctx context.Context, system, question string) (string, error) {
+		return vertex.AskVertex(ctx, &vertex.VertexConfig{
+			Project:  cfg.Project,
+			Location: cfg.Location,
+			Model:    cfg.Model,
+			System:   system,
+			Prompt:   question,
+			// Room for the answer with its source ids
+			MaxOutputTokens: 500,
+		})
 	}
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var testimonies = map[string]string{
+	"adam":  "Andrzej Maj prowadził wykłady z sieci neuronowych. Znałem go jeszcze ze studiów.",
+	"rafal": "Profesor Maj pracuje w Instytucie Informatyki i Matematyki Komputerowej przy ulicy Łojasiewicza w Krakowie.",
+}
+
+const street = "ul. prof. Stanisława Łojasiewicza"
+
+func TestRunTask(t *testing.T) {
+	for _, transcriber := range []string{"gemini", "whisper"} {
+		t.Run(transcriber, func(t *testing.T) {
+			testkit.Workdir(t)
+			testkit.SetKeys(t)
+			for name := range testimonies {
+				if err := os.MkdirAll("recordings", 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join("recordings", name+".mp3"), []byte("audio of "+name), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			gemini := testkit.NewGemini(t)
+			gemini.OnGenerate(func(req testkit.GeminiRequest) string {
+				return testimonies[strings.TrimPrefix(string(req.Blobs[0].Data), "audio of ")]
+			})
+			llm := testkit.NewOpenAI(t)
+			llm.OnTranscribe(func(name string, _ []byte) string {
+				return testimonies[strings.TrimSuffix(name, ".mp3")]
+			})
+			llm.OnChat(func(req openai.ChatCompletionRequest) string {
+				if !strings.Contains(req.Messages[0].Content, "Łojasiewicza") {
+					return `{"answer": "The sources do not say", "sources": []}`
+				}
+				return `{"answer": "` + street + `", "sources": ["S1"]}`
+			})
+			centrala := testkit.NewCentrala(t)
+			centrala.Expect("mp3", street, "{{FLG:MP3}}")
+
+			cfg := Config{
+				// Not an OpenAI model name, so tokens are estimated
+				Model:       "test-model",
+				Answerer:    "openai",
+				Transcriber: transcriber,
+				InputDir:    "recordings",
+				OutputDir:   "transcripts",
+				IndexPath:   "index.json",
+				Question:    "Na jakiej ulicy znajduje się instytut, w którym wykłada Andrzej Maj?",
+			}
+			if err := runTask(context.Background(), cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			transcript, err := os.ReadFile(filepath.Join("transcripts", "rafal.txt"))
+			if err != nil || string(transcript) != testimonies["rafal"] {
+				t.Fatalf("unexpected transcript %q: %v", transcript, err)
+			}
+			reports := centrala.Reports()
+			if len(reports) != 1 || reports[0].Code != 0 {
+				t.Fatalf("expected one accepted report, got %+v", reports)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"openai", Config{Transcriber: "gemini", Answerer: "openai"}, true},
+		{"vertex", Config{Transcriber: "gemini", Answerer: "vertex", Project: "p", Location: "us-central1"}, true},
+		{"vertex without a project", Config{Transcriber: "gemini", Answerer: "vertex", Location: "us-central1"}, false},
+		{"unknown answerer", Config{Transcriber: "gemini", Answerer: "claude"}, false},
+		{"unknown transcriber", Config{Transcriber: "vosk", Answerer: "openai"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.cfg.Validate(); (err == nil) != test.valid {
+				t.Fatalf("expected valid=%v, got %v", test.valid, err)
+			}
+		})
+	}
+}
@@ -2,20 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/config"
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/interrupt"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/rs/zerolog/log"
 )
 
-const (
-	dataURL   = "https://poligon.aidevs.pl/dane.txt"
-	verifyURL = "https://poligon.aidevs.pl/verify"
-)
+// Config of the poligon task, see configs/poligon.yaml
+type Config struct {
+	DataURL   string `yaml:"data_url" env:"POLIGON_DATA_URL" flag:"data-url" usage:"address of the data to verify" required:"true"`
+	VerifyURL string `yaml:"verify_url" env:"POLIGON_VERIFY_URL" flag:"verify-url" usage:"address the answer is sent to" required:"true"`
+}
 
 type verifyPayload struct {
 	Task   string   `json:"task"`
@@ -23,9 +31,13 @@ type verifyPayload struct {
 	Answer []string `json:"answer"`
 }
 
-func fetchData(url string) ([]byte, error) {
-	log.Printf("Fetching data from %s", url)
-	resp, err := http.Get(url)
+func fetchData(ctx context.Context, url string) ([]byte, error) {
+	log.Info().Str("url", url).Msg("Fetching data")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := httpclient.Default.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP GET request failed: %w", err)
 	}
@@ -40,11 +52,11 @@ func fetchData(url string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	log.Printf("Successfully fetched data from %s", url)
+	log.Info().Str("url", url).Int("length", len(data)).Msg("Successfully fetched data")
 	return data, nil
 }
 
-func verifyData(dataArray []string, apiKey string) error {
+func verifyData(ctx context.Context, verifyURL string, dataArray []string, apiKey string) error {
 	payload := verifyPayload{
 		Task:   "POLIGON",
 		APIKey: apiKey,
@@ -56,8 +68,13 @@ func verifyData(dataArray []string, apiKey string) error {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	log.Printf("Sending verification request to %s", verifyURL)
-	resp, err := http.Post(verifyURL, "application/json", bytes.NewBuffer(jsonData))
+	log.Info().Str("url", verifyURL).Msg("Sending verification request")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpclient.Default.Do(req)
 	if err != nil {
 		return fmt.Errorf("HTTP POST request failed: %w", err)
 	}
@@ -67,34 +84,64 @@ func verifyData(dataArray []string, apiKey string) error {
 		return fmt.Errorf("verification request failed: received status code %d", resp.StatusCode)
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	log.Printf("Received response: %+v", result)
+	run := ledger.Current()
+	if answer, err := json.Marshal(dataArray); err == nil {
+		run.SetAnswer("POLIGON", answer)
+	}
+	run.SetResponse(result.Code, result.Message)
+	log.Info().Int("code", result.Code).Str("message", result.Message).Msg("Received response")
 	return nil
 }
 
 func main() {
-	apiKey, err := utils.GetAPIKey("aidevs-api-key")
+	cfg := Config{
+		DataURL:   "https://poligon.aidevs.pl/dane.txt",
+		VerifyURL: "https://poligon.aidevs.pl/verify",
+	}
+	loader := config.New("poligon", &cfg, flag.CommandLine)
+	logOptions := logging.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	logging.Setup("poligon", *logOptions)
+	ctx, stop := interrupt.Context(context.Background())
+	defer stop()
+	loader.LoadOrExit(ctx)
+	run := ledger.Begin("poligon")
+	defer run.Finish(nil)
+
+	if err := runTask(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Task failed")
+	}
+}
+
+// runTask fetches the data and sends it for verification
+func runTask(ctx context.Context, cfg Config) error {
+	apiKey, err := utils.GetAPIKey(ctx, "aidevs-api-key")
 	if err != nil {
-		log.Fatalf("Failed to get API key: %v", err)
+		return fmt.Errorf("failed to get API key: %w", err)
 	}
 
 	// Fetch data from the text file
-	data, err := fetchData(dataURL)
+	data, err := fetchData(ctx, cfg.DataURL)
 	if err != nil {
-		log.Fatalf("Failed to fetch data: %v", err)
+		return fmt.Errorf("failed to fetch data: %w", err)
 	}
 
 	// Split the content into a slice of strings
 	dataArray := strings.Split(strings.TrimSpace(string(data)), "\n")
 
 	// Prepare and send verification request using the fetched API key
-	if err := verifyData(dataArray, apiKey); err != nil {
-		log.Fatalf("Verification failed: %v", err)
+	if err := verifyData(ctx, cfg.VerifyURL, dataArray, apiKey); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
 	}
 
-	log.Println("Verification completed successfully.")
+	log.Info().Msg("Verification completed successfully")
+	return nil
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+)
+
+func TestRunTask(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	centrala := testkit.NewCentrala(t)
+	centrala.File("dane.txt", []byte("12345\n67890\n"))
+	centrala.Expect("POLIGON", []string{"12345", "67890"}, "{{FLG:POLIGON}}")
+
+	cfg := Config{
+		DataURL:   centrala.URL + "/data/" + testkit.AIDevsKey + "/dane.txt",
+		VerifyURL: centrala.URL + "/report",
+	}
+	if err := runTask(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	reports := centrala.Reports()
+	if len(reports) != 1 || reports[0].Code != 0 {
+		t.Fatalf("expected one accepted report, got %+v", reports)
+	}
+}
+
+func TestRunTaskRejected(t *testing.T) {
+	testkit.Workdir(t)
+	testkit.SetKeys(t)
+	centrala := testkit.NewCentrala(t)
+	centrala.File("dane.txt", []byte("12345\n"))
+	centrala.Expect("POLIGON", []string{"67890"}, "{{FLG:POLIGON}}")
+
+	cfg := Config{
+		DataURL:   centrala.URL + "/data/" + testkit.AIDevsKey + "/dane.txt",
+		VerifyURL: centrala.URL + "/report",
+	}
+	if err := runTask(context.Background(), cfg); err == nil {
+		t.Fatal("expected the wrong answer to fail verification")
+	}
+	if reports := centrala.Reports(); len(reports) != 1 || reports[0].Code != testkit.CodeWrongAnswer {
+		t.Fatalf("expected one rejected report, got %+v", reports)
+	}
+}
@@ -0,0 +1,335 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ManifestFileName is written to the extraction directory and maps the archive to its files
+const ManifestFileName = ".archive.json"
+
+// Default limits protecting against zip bombs
+const (
+	DefaultMaxFileSize  = 100 << 20 // 100 MB per entry
+	DefaultMaxTotalSize = 1 << 30   // 1 GB per archive
+	DefaultMaxFiles     = 10000
+)
+
+var (
+	ErrPasswordRequired = errors.New("archive entry is encrypted and no password was supplied")
+	ErrWrongPassword    = errors.New("wrong archive password")
+	ErrUnsafePath       = errors.New("archive entry escapes the destination directory")
+	ErrTooLarge         = errors.New("archive exceeds size limits")
+)
+
+// Options controls extraction. Zero values fall back to the defaults.
+type Options struct {
+	Password     string
+	MaxFileSize  int64
+	MaxTotalSize int64
+	MaxFiles     int
+}
+
+// Entry describes a single extracted file
+type Entry struct {
+	Name      string `json:"name"` // path inside the archive
+	Path      string `json:"path"` // path on disk
+	Size      int64  `json:"size"`
+	Encrypted bool   `json:"encrypted"`
+}
+
+// Manifest records which files were extracted from which archive
+type Manifest struct {
+	Archive     string    `json:"archive"`
+	ExtractedAt time.Time `json:"extracted_at"`
+	Files       []Entry   `json:"files"`
+}
+
+// IsZip checks the file header for the zip local file signature
+func IsZip(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	// Regular archives start with PK\x03\x04, empty ones with PK\x05\x06
+	return bytes.Equal(header, []byte("PK\x03\x04")) || bytes.Equal(header, []byte("PK\x05\x06"))
+}
+
+// ReadManifest loads the manifest of a previously extracted archive
+func ReadManifest(destDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(destDir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse archive manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Extract unpacks the zip archive into destDir and writes a manifest.
+// If the archive was already extracted, the existing manifest is returned.
+func Extract(zipPath, destDir string, opts Options) (*Manifest, error) {
+	if manifest, err := ReadManifest(destDir); err == nil {
+		log.Info().Str("archive", zipPath).Str("destDir", destDir).Msg("Archive already extracted, skipping")
+		return manifest, nil
+	}
+
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.MaxTotalSize <= 0 {
+		opts.MaxTotalSize = DefaultMaxTotalSize
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = DefaultMaxFiles
+	}
+
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive '%s': %w", zipPath, err)
+	}
+	defer reader.Close()
+
+	if len(reader.File) > opts.MaxFiles {
+		return nil, fmt.Errorf("%w: %d entries (limit %d)", ErrTooLarge, len(reader.File), opts.MaxFiles)
+	}
+
+	absDest, err := filepath.Abs(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve destination '%s': %w", destDir, err)
+	}
+	if err := os.MkdirAll(absDest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination '%s': %w", destDir, err)
+	}
+
+	manifest := &Manifest{Archive: zipPath, ExtractedAt: time.Now()}
+	var total int64
+
+	for _, f := range reader.File {
+		target, err := safePath(absDest, f.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory '%s': %w", target, err)
+			}
+			continue
+		}
+
+		size, err := extractFile(f, target, opts, opts.MaxTotalSize-total)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract '%s': %w", f.Name, err)
+		}
+		total += size
+
+		manifest.Files = append(manifest.Files, Entry{
+			Name:      f.Name,
+			Path:      filepath.Join(destDir, filepath.FromSlash(f.Name)),
+			Size:      size,
+			Encrypted: isEncrypted(f),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal archive manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(absDest, ManifestFileName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+
+	log.Info().Str("archive", zipPath).Str("destDir", destDir).Int("files", len(manifest.Files)).Msg("Archive extracted")
+	return manifest, nil
+}
+
+// safePath joins name to dest and rejects entries that would land outside of it (zip-slip)
+func safePath(dest, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if target != dest && !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return target, nil
+}
+
+// extractFile writes a single entry to disk, enforcing the size limits.
+// The uncompressed size from the header is not trusted.
+func extractFile(f *zip.File, target string, opts Options, remaining int64) (int64, error) {
+	limit := opts.MaxFileSize
+	if remaining < limit {
+		limit = remaining
+	}
+
+	var rc io.ReadCloser
+	var err error
+	if isEncrypted(f) {
+		rc, err = openEncrypted(f, opts.Password)
+	} else {
+		rc, err = f.Open()
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	// Read one byte past the limit to detect oversized entries
+	written, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if err != nil {
+		return written, err
+	}
+	if written > limit {
+		out.Close()
+		os.Remove(target)
+		return written, fmt.Errorf("%w: '%s' is larger than %d bytes", ErrTooLarge, f.Name, limit)
+	}
+	return written, nil
+}
+
+func isEncrypted(f *zip.File) bool {
+	return f.Flags&0x1 != 0
+}
+
+// openEncrypted decrypts an entry protected with traditional PKWARE encryption (ZipCrypto)
+func openEncrypted(f *zip.File, password string) (io.ReadCloser, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	// Only the 12 byte encryption header is read up front, the rest is
+	// decrypted as it is extracted so the size limits apply to it
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, fmt.Errorf("encrypted entry is too short: %w", err)
+	}
+
+	keys := newZipCryptoKeys(password)
+	header = keys.decrypt(header)
+
+	// The last header byte is a password check: high byte of the CRC, or of the
+	// modification time when sizes are stored in a data descriptor
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&0x8 != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[11] != check {
+		return nil, ErrWrongPassword
+	}
+
+	compressed := &decryptReader{r: raw, keys: keys}
+
+	var rc io.ReadCloser
+	switch f.Method {
+	case zip.Store:
+		rc = io.NopCloser(compressed)
+	case zip.Deflate:
+		rc = flate.NewReader(compressed)
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d for encrypted entry", f.Method)
+	}
+
+	return &checksumReader{rc: rc, hash: crc32.NewIEEE(), want: f.CRC32}, nil
+}
+
+// zipCryptoKeys implements the traditional PKWARE stream cipher
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+	return k
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(data []byte) []byte {
+	plain := make([]byte, len(data))
+	for i, c := range data {
+		temp := uint16(k[2] | 2)
+		p := c ^ byte((uint32(temp)*uint32(temp^1))>>8)
+		k.update(p)
+		plain[i] = p
+	}
+	return plain
+}
+
+// decryptReader decrypts the ZipCrypto stream of an entry as it is read
+type decryptReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	copy(p, d.keys.decrypt(p[:n]))
+	return n, err
+}
+
+// checksumReader verifies the CRC32 of the decrypted entry once it is fully read
+type checksumReader struct {
+	rc   io.ReadCloser
+	hash interface {
+		io.Writer
+		Sum32() uint32
+	}
+	want uint32
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && r.hash.Sum32() != r.want {
+		return n, fmt.Errorf("%w: checksum mismatch", ErrWrongPassword)
+	}
+	return n, err
+}
+
+func (r *checksumReader) Close() error {
+	return r.rc.Close()
+}
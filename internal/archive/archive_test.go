@@ -0,0 +1,142 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// entry is a file put into a test archive, encrypted when password is set
+type entry struct {
+	name     string
+	content  string
+	password string
+}
+
+// writeZip builds an archive from entries in a temporary file
+func writeZip(t *testing.T, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		if e.password == "" {
+			f, err := w.Create(e.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte(e.content))
+			continue
+		}
+		data := encrypt(e.password, []byte(e.content))
+		f, err := w.CreateRaw(&zip.FileHeader{
+			Name:               e.name,
+			Method:             zip.Store,
+			Flags:              0x1,
+			CRC32:              crc32.ChecksumIEEE([]byte(e.content)),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: uint64(len(e.content)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// encrypt protects a stored entry with ZipCrypto, the header ending in the
+// high byte of the CRC as the password check
+func encrypt(password string, content []byte) []byte {
+	header := []byte("0123456789a\x00")
+	header[11] = byte(crc32.ChecksumIEEE(content) >> 24)
+	keys := newZipCryptoKeys(password)
+	plain := append(header, content...)
+	out := make([]byte, len(plain))
+	for i, p := range plain {
+		temp := uint16(keys[2] | 2)
+		out[i] = p ^ byte((uint32(temp)*uint32(temp^1))>>8)
+		keys.update(p)
+	}
+	return out
+}
+
+func TestExtract(t *testing.T) {
+	path := writeZip(t,
+		entry{name: "facts/f01.txt", content: "Barbara Zawadzka"},
+		entry{name: "secret.txt", content: "{{FLG:ZIP}}", password: "1670"},
+	)
+	dest := filepath.Join(t.TempDir(), "out")
+
+	manifest, err := Extract(path, dest, Options{Password: "1670"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{
+		{Name: "facts/f01.txt", Path: filepath.Join(dest, "facts", "f01.txt"), Size: 16},
+		{Name: "secret.txt", Path: filepath.Join(dest, "secret.txt"), Size: 11, Encrypted: true},
+	}
+	if manifest.Archive != path || len(manifest.Files) != len(expected) {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	for i, file := range manifest.Files {
+		if file != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], file)
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(dest, "secret.txt")); err != nil || string(content) != "{{FLG:ZIP}}" {
+		t.Fatalf("unexpected decrypted content %q: %v", content, err)
+	}
+
+	saved, err := ReadManifest(dest)
+	if err != nil || len(saved.Files) != 2 || saved.Files[1] != expected[1] {
+		t.Fatalf("unexpected saved manifest %+v: %v", saved, err)
+	}
+	// A second extraction returns the saved manifest without a password
+	if again, err := Extract(path, dest, Options{}); err != nil || len(again.Files) != 2 {
+		t.Fatalf("expected the saved manifest, got %+v: %v", again, err)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	tests := []struct {
+		name    string
+		entries []entry
+		opts    Options
+		err     error
+	}{
+		{"parent directory", []entry{{name: "../evil.txt", content: "x"}}, Options{}, ErrUnsafePath},
+		{"nested parent directory", []entry{{name: "a/../../evil.txt", content: "x"}}, Options{}, ErrUnsafePath},
+		{"absolute path", []entry{{name: "/etc/evil", content: "x"}}, Options{}, ErrUnsafePath},
+		{"file over the size limit", []entry{{name: "big.txt", content: big}}, Options{MaxFileSize: 99}, ErrTooLarge},
+		{"archive over the total limit", []entry{{name: "a.txt", content: big}, {name: "b.txt", content: big}}, Options{MaxTotalSize: 150}, ErrTooLarge},
+		{"too many files", []entry{{name: "a.txt"}, {name: "b.txt"}}, Options{MaxFiles: 1}, ErrTooLarge},
+		{"encrypted over the size limit", []entry{{name: "big.txt", content: big, password: "pw"}}, Options{Password: "pw", MaxFileSize: 99}, ErrTooLarge},
+		{"no password", []entry{{name: "secret.txt", content: "x", password: "pw"}}, Options{}, ErrPasswordRequired},
+		{"wrong password", []entry{{name: "secret.txt", content: "{{FLG:ZIP}}", password: "1670"}}, Options{Password: "1234"}, ErrWrongPassword},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dest := t.TempDir()
+			_, err := Extract(writeZip(t, test.entries...), dest, test.opts)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if _, err := ReadManifest(dest); err == nil {
+				t.Fatal("expected no manifest after a failed extraction")
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package calibration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/expr"
+	"github.com/rs/zerolog/log"
+)
+
+// TestDataKey is the array of items inside the calibration document
+const TestDataKey = "test-data"
+
+const (
+	defaultBatchSize   = 20
+	defaultConcurrency = 4
+	// maxChunkItems bounds how many items are held in memory while waiting for answers
+	maxChunkItems = 1000
+	indent        = "    "
+)
+
+// Item is one entry of the test data
+type Item struct {
+	Question string      `json:"question"`
+	Answer   expr.Number `json:"answer"`
+	Test     *Test       `json:"test,omitempty"`
+}
+
+// Test is an open question that needs an answer
+type Test struct {
+	Q string `json:"q"`
+	A string `json:"a"`
+}
+
+// Fix records one item whose answer was corrected or could not be checked
+type Fix struct {
+	Index    int          `json:"index"`
+	Question string       `json:"question"`
+	Was      expr.Number  `json:"was"`
+	Now      *expr.Number `json:"now,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// CheckItem evaluates the question of an item and corrects a wrong answer.
+// It returns nil when the answer was right.
+func CheckItem(index int, item *Item) *Fix {
+	correct, err := expr.Eval(item.Question)
+	if err != nil {
+		log.Warn().Err(err).Int("index", index).Str("question", item.Question).Msg("Cannot evaluate question")
+		return &Fix{Index: index, Question: item.Question, Was: item.Answer, Error: err.Error()}
+	}
+	if item.Answer.Equal(correct) {
+		return nil
+	}
+	log.Info().Int("index", index).Str("question", item.Question).Str("was", item.Answer.String()).Str("now", correct.String()).Msg("Fixing answer")
+	fix := &Fix{Index: index, Question: item.Question, Was: item.Answer, Now: &correct}
+	item.Answer = correct
+	return fix
+}
+
+// AnswerFunc answers a batch of open questions, returning one answer per question in order
+type AnswerFunc func(ctx context.Context, questions []string) ([]string, error)
+
+// Processor streams a calibration document, fixing answers and filling in open questions
+type Processor struct {
+	Answer AnswerFunc
+	// BatchSize is the number of open questions per Answer call
+	BatchSize int
+	// Concurrency is the number of Answer calls in flight
+	Concurrency int
+	// Overrides replace top-level values of the document by key
+	Overrides map[string]json.RawMessage
+}
+
+// Stats summarizes a processed document
+type Stats struct {
+	Items    int
+	Fixes    []Fix
+	Answered int
+}
+
+// chunk is a run of consecutive items answered together
+type chunk struct {
+	items []Item
+	done  chan error
+}
+
+// Process reads a calibration document from r and writes the corrected
+// document to w. Keys other than test-data are copied through, and the
+// items are decoded, fixed, answered and written one chunk at a time, so the
+// document never has to fit in memory. Output order matches the input.
+func (p *Processor) Process(ctx context.Context, r io.Reader, w io.Writer) (*Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	out := bufio.NewWriter(w)
+	stats := &Stats{}
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	out.WriteString("{")
+
+	first := true
+	for dec.More() {
+		keyToken, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		key, _ := keyToken.(string)
+
+		if !first {
+			out.WriteString(",")
+		}
+		first = false
+		keyJSON, _ := json.Marshal(key)
+		fmt.Fprintf(out, "\n%s%s: ", indent, keyJSON)
+
+		if key == TestDataKey {
+			if err := p.processItems(ctx, dec, out, stats); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Copy any other value through, unless overridden
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		if override, ok := p.Overrides[key]; ok {
+			raw = override
+		}
+		if err := writeIndented(out, raw, indent); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n}\n")
+
+	if err := out.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write output: %w", err)
+	}
+	return stats, nil
+}
+
+// processItems runs the item pipeline: this goroutine decodes and fixes
+// items, workers answer the open questions of each chunk, and chunks are
+// written in order as soon as they and all earlier ones are done.
+func (p *Processor) processItems(ctx context.Context, dec *json.Decoder, out *bufio.Writer, stats *Stats) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	// Stop answering and decoding as soon as the writer gives up
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batchSize, concurrency := p.BatchSize, p.Concurrency
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	ordered := make(chan *chunk, concurrency)
+	slots := make(chan struct{}, concurrency)
+	writeErr := make(chan error, 1)
+	var answered int
+	var mu sync.Mutex
+
+	// Writer: waits for each chunk in input order
+	go func() {
+		written := 0
+		var failed error
+		for c := range ordered {
+			if failed != nil {
+				continue
+			}
+			if err := <-c.done; err != nil {
+				failed = err
+				cancel()
+				continue
+			}
+			for _, item := range c.items {
+				separator := ","
+				if written == 0 {
+					separator = "["
+				}
+				out.WriteString(separator)
+				data, err := json.MarshalIndent(item, indent+indent, indent)
+				if err != nil {
+					failed = fmt.Errorf("failed to marshal item: %w", err)
+					cancel()
+					break
+				}
+				fmt.Fprintf(out, "\n%s%s", indent+indent, data)
+				written++
+			}
+		}
+		if failed == nil {
+			if written == 0 {
+				out.WriteString("[]")
+			} else {
+				fmt.Fprintf(out, "\n%s]", indent)
+			}
+		}
+		writeErr <- failed
+	}()
+
+	var current []Item
+	openQuestions := 0
+	dispatch := func() {
+		if len(current) == 0 {
+			return
+		}
+		c := &chunk{items: current, done: make(chan error, 1)}
+		current, openQuestions = nil, 0
+		ordered <- c
+		go func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			n, err := p.answerChunk(ctx, c.items)
+			mu.Lock()
+			answered += n
+			mu.Unlock()
+			c.done <- err
+		}()
+	}
+
+	var decodeErr error
+	for index := 0; dec.More() && ctx.Err() == nil; index++ {
+		var item Item
+		if err := dec.Decode(&item); err != nil {
+			decodeErr = fmt.Errorf("failed to decode item %d: %w", index, err)
+			break
+		}
+		stats.Items++
+		if fix := CheckItem(index, &item); fix != nil {
+			stats.Fixes = append(stats.Fixes, *fix)
+		}
+		if item.Test != nil {
+			openQuestions++
+		}
+		current = append(current, item)
+		if openQuestions >= batchSize || len(current) >= maxChunkItems {
+			dispatch()
+		}
+	}
+	if decodeErr == nil {
+		dispatch()
+	}
+	close(ordered)
+
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stats.Answered = answered
+	return expectDelim(dec, ']')
+}
+
+// answerChunk answers the open questions of a chunk in one call
+func (p *Processor) answerChunk(ctx context.Context, items []Item) (int, error) {
+	var questions []string
+	var targets []*Test
+	for i := range items {
+		if items[i].Test != nil {
+			questions = append(questions, items[i].Test.Q)
+			targets = append(targets, items[i].Test)
+		}
+	}
+	if len(questions) == 0 || p.Answer == nil {
+		return 0, nil
+	}
+
+	log.Debug().Int("questions", len(questions)).Msg("Answering test questions")
+	answers, err := p.Answer(ctx, questions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to answer test questions: %w", err)
+	}
+	if len(answers) != len(questions) {
+		return 0, fmt.Errorf("got %d answers for %d test questions", len(answers), len(questions))
+	}
+	for i, target := range targets {
+		target.A = answers[i]
+		log.Info().Str("question", target.Q).Str("answer", target.A).Msg("Test question answered")
+	}
+	return len(answers), nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read JSON: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected '%c' in JSON, got %v", want, token)
+	}
+	return nil
+}
+
+func writeIndented(w io.Writer, raw json.RawMessage, prefix string) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, prefix, indent); err != nil {
+		return fmt.Errorf("failed to format JSON: %w", err)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,200 @@
+package calibration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/expr"
+)
+
+// document builds a calibration document with n items; every item whose
+// index is divisible by testEvery has an open question
+func document(n, testEvery int) string {
+	items := make([]string, n)
+	for i := range items {
+		item := fmt.Sprintf(`{"question": "%d + 1", "answer": %d`, i, i+1)
+		if i%testEvery == 0 {
+			item += fmt.Sprintf(`, "test": {"q": "q%d", "a": "???"}`, i)
+		}
+		items[i] = item + "}"
+	}
+	return `{"apikey": "key", "test-data": [` + strings.Join(items, ",") + `], "description": "calibration"}`
+}
+
+// output is the part of a processed document the tests look at
+type output struct {
+	APIKey      string `json:"apikey"`
+	Description string `json:"description"`
+	TestData    []Item `json:"test-data"`
+}
+
+func process(t *testing.T, p *Processor, input string) (*Stats, output) {
+	t.Helper()
+	var buf strings.Builder
+	stats, err := p.Process(context.Background(), strings.NewReader(input), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out output
+	if err := json.Unmarshal([]byte(buf.String()), &out); err != nil {
+		t.Fatalf("invalid output %s: %v", buf.String(), err)
+	}
+	return stats, out
+}
+
+// echo answers every question with "a" + the question, recording the batches
+type echo struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (e *echo) answer(ctx context.Context, questions []string) ([]string, error) {
+	e.mu.Lock()
+	e.batches = append(e.batches, questions)
+	e.mu.Unlock()
+	answers := make([]string, len(questions))
+	for i, question := range questions {
+		answers[i] = "a" + question
+	}
+	return answers, nil
+}
+
+func TestProcessBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     int
+		testEvery int
+		batchSize int
+		batches   []int
+	}{
+		{"one batch", 5, 1, 20, []int{5}},
+		{"full batches", 6, 1, 2, []int{2, 2, 2}},
+		{"last batch is partial", 5, 1, 2, []int{2, 2, 1}},
+		{"items without questions", 10, 3, 2, []int{2, 2}},
+		{"default batch size", 45, 1, 0, []int{20, 20, 5}},
+		{"no questions", 4, 100, 2, []int{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			answerer := &echo{}
+			p := &Processor{Answer: answerer.answer, BatchSize: test.batchSize, Concurrency: 3}
+			stats, out := process(t, p, document(test.items, test.testEvery))
+
+			sizes := make([]int, 0, len(answerer.batches))
+			for _, batch := range answerer.batches {
+				sizes = append(sizes, len(batch))
+			}
+			// Batches run concurrently, so compare the multiset of sizes
+			if !sameCounts(sizes, test.batches) {
+				t.Fatalf("expected batches of %v, got %v", test.batches, sizes)
+			}
+
+			if stats.Items != test.items || len(out.TestData) != test.items {
+				t.Fatalf("expected %d items, got %d in stats and %d in output", test.items, stats.Items, len(out.TestData))
+			}
+			answered := 0
+			for i, item := range out.TestData {
+				if item.Question != fmt.Sprintf("%d + 1", i) {
+					t.Fatalf("item %d out of order: %+v", i, item)
+				}
+				if i%test.testEvery == 0 {
+					answered++
+					if item.Test == nil || item.Test.A != fmt.Sprintf("aq%d", i) {
+						t.Errorf("item %d has the wrong answer: %+v", i, item.Test)
+					}
+				} else if item.Test != nil {
+					t.Errorf("item %d gained a test: %+v", i, item.Test)
+				}
+			}
+			if stats.Answered != answered {
+				t.Errorf("expected %d answered, got %d", answered, stats.Answered)
+			}
+		})
+	}
+}
+
+func sameCounts(a, b []int) bool {
+	counts := make(map[int]int)
+	for _, n := range a {
+		counts[n]++
+	}
+	for _, n := range b {
+		counts[n]--
+	}
+	for _, c := range counts {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProcessFixesAndCopies(t *testing.T) {
+	input := `{"apikey": "%PUT-YOUR-API-KEY-HERE%", "description": "calibration", "test-data": [
+		{"question": "1 + 1", "answer": 2},
+		{"question": "2 + 3", "answer": 6},
+		{"question": "7 / 2", "answer": 3},
+		{"question": "1 / 0", "answer": 0},
+		{"question": "4 + 4", "answer": 8, "test": {"q": "capital of Poland", "a": "???"}}
+	]}`
+	p := &Processor{Overrides: map[string]json.RawMessage{"apikey": json.RawMessage(`"secret"`)}}
+	stats, out := process(t, p, input)
+
+	if out.APIKey != "secret" || out.Description != "calibration" {
+		t.Fatalf("unexpected top-level values %+v", out)
+	}
+	// Without an answerer the open question is left as it was
+	if stats.Answered != 0 || out.TestData[4].Test.A != "???" {
+		t.Fatalf("expected the question to stay open, got %+v", out.TestData[4].Test)
+	}
+
+	answers := []expr.Number{expr.Int(2), expr.Int(5), expr.Float(3.5), expr.Int(0), expr.Int(8)}
+	for i, item := range out.TestData {
+		if !item.Answer.Equal(answers[i]) {
+			t.Errorf("item %d: expected %v, got %v", i, answers[i], item.Answer)
+		}
+	}
+	var indexes []int
+	for _, fix := range stats.Fixes {
+		indexes = append(indexes, fix.Index)
+	}
+	if !reflect.DeepEqual(indexes, []int{1, 2, 3}) || stats.Fixes[2].Error == "" || stats.Fixes[2].Now != nil {
+		t.Fatalf("unexpected fixes %+v", stats.Fixes)
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	failing := func(ctx context.Context, questions []string) ([]string, error) {
+		return nil, fmt.Errorf("model unavailable")
+	}
+	short := func(ctx context.Context, questions []string) ([]string, error) {
+		return questions[1:], nil
+	}
+	tests := []struct {
+		name   string
+		answer AnswerFunc
+		input  string
+		err    string
+	}{
+		{"answerer fails", failing, document(5, 1), "model unavailable"},
+		{"missing answers", short, document(5, 1), "got 1 answers for 2 test questions"},
+		{"not an object", nil, `[]`, "expected '{'"},
+		{"test data not an array", nil, `{"test-data": {}}`, "expected '['"},
+		{"malformed item", nil, `{"test-data": [{"question": "1 + 1", "answer": "two"}]}`, "failed to decode item 0"},
+		{"truncated", nil, `{"test-data": [{"question": "1 + 1", "answer": 2}`, "unexpected end of JSON input"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Processor{Answer: test.answer, BatchSize: 2}
+			_, err := p.Process(context.Background(), strings.NewReader(test.input), &strings.Builder{})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+const (
+	systemPrompt = "You are a helpful assistant that provides precise, numeric answers to historical questions."
+	userPrompt   = "What is the numeric answer to this question: %s? Respond ONLY with the number."
+
+	defaultSamples = 5
+)
+
+// ErrNoAnswer is returned when no sample contained a number
+var ErrNoAnswer = errors.New("no numeric answer in any sample")
+
+var (
+	// A number, optionally negative, with thousands separated by commas, dots or spaces, or a decimal
+	numberPattern = regexp.MustCompile(`-?\d{1,3}(?:[ ,.\x{00A0}]\d{3})+(?:,\d{1,2})?\b|-?\d+(?:[.,]\d+)?`)
+	// "answer is 1945", "answer: 1945", "= 1945"
+	answerPattern = regexp.MustCompile(`(?i)(?:answer(?:\s+is)?|result(?:\s+is)?|=)\s*:?\s*(-?[\d ,.\x{00A0}]*\d)`)
+)
+
+// Sampler produces one raw reply for the question
+type Sampler struct {
+	Name   string
+	Sample func(ctx context.Context, question string) (string, error)
+}
+
+// OpenAISampler samples an OpenAI chat model. A non-zero temperature makes
+// the samples independent enough to vote on.
+func OpenAISampler(client *openai.Client, model string, temperature float32) Sampler {
+	return Sampler{
+		Name: "openai/" + model,
+		Sample: func(ctx context.Context, question string) (string, error) {
+			resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+				Model: model,
+				Messages: []openai.ChatCompletionMessage{
+					{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+					{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf(userPrompt, question)},
+				},
+				MaxTokens:   50,
+				Temperature: temperature,
+			})
+			if err != nil {
+				return "", fmt.Errorf("OpenAI API error: %w", err)
+			}
+			if len(resp.Choices) == 0 {
+				return "", fmt.Errorf("OpenAI returned no choices")
+			}
+			return resp.Choices[0].Message.Content, nil
+		},
+	}
+}
+
+// GeminiSampler samples a Gemini model
+func GeminiSampler(apiKey, model string, temperature float64) Sampler {
+	return Sampler{
+		Name: "gemini/" + model,
+		Sample: func(ctx context.Context, question string) (string, error) {
+			client, err := genai.NewClient(ctx, &genai.ClientConfig{
+				APIKey:     apiKey,
+				Backend:    genai.BackendGoogleAI,
+				HTTPClient: httpclient.SDK,
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to create Gemini client: %w", err)
+			}
+			result, err := client.Models.GenerateContent(ctx, model, genai.Text(fmt.Sprintf(userPrompt, question)), &genai.GenerateContentConfig{
+				SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: systemPrompt}}},
+				Temperature:       &temperature,
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to generate content: %w", err)
+			}
+			if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+				return "", fmt.Errorf("Gemini returned no candidates")
+			}
+			return result.Candidates[0].Content.Parts[0].Text, nil
+		},
+	}
+}
+
+// Vote is one sampled reply and the number extracted from it
+type Vote struct {
+	Sampler string
+	Reply   string
+	Answer  int
+	Valid   bool
+}
+
+// Result is the majority answer with the votes behind it
+type Result struct {
+	Answer int
+	// Confidence is the share of all samples that agreed with the answer
+	Confidence float64
+	Votes      []Vote
+}
+
+// Agreeing returns how many samples gave the winning answer
+func (r *Result) Agreeing() int {
+	n := 0
+	for _, vote := range r.Votes {
+		if vote.Valid && vote.Answer == r.Answer {
+			n++
+		}
+	}
+	return n
+}
+
+// Solver asks every sampler several times and takes the majority answer
+type Solver struct {
+	Samplers []Sampler
+	// Samples per sampler; 0 means defaultSamples
+	Samples int
+}
+
+// Solve samples all samplers concurrently and votes. Failed samples count
+// against the confidence but do not fail the solve unless all of them fail.
+func (s *Solver) Solve(ctx context.Context, question string) (*Result, error) {
+	samples := s.Samples
+	if samples <= 0 {
+		samples = defaultSamples
+	}
+
+	votes := make([]Vote, len(s.Samplers)*samples)
+	var wg sync.WaitGroup
+	for i, sampler := range s.Samplers {
+		for j := 0; j < samples; j++ {
+			wg.Add(1)
+			go func(slot int, sampler Sampler) {
+				defer wg.Done()
+				vote := Vote{Sampler: sampler.Name}
+				reply, err := sampler.Sample(ctx, question)
+				if err != nil {
+					log.Warn().Err(err).Str("sampler", sampler.Name).Msg("Captcha sample failed")
+				} else {
+					vote.Reply = reply
+					vote.Answer, vote.Valid = ExtractNumber(reply)
+				}
+				votes[slot] = vote
+			}(i*samples+j, sampler)
+		}
+	}
+	wg.Wait()
+
+	result, err := tally(votes)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Str("question", question).
+		Int("answer", result.Answer).
+		Int("agreeing", result.Agreeing()).
+		Int("samples", len(votes)).
+		Float64("confidence", result.Confidence).
+		Msg("Captcha solved by vote")
+	return result, nil
+}
+
+// tally picks the most common answer; ties go to the answer seen first
+func tally(votes []Vote) (*Result, error) {
+	counts := make(map[int]int)
+	var order []int
+	for _, vote := range votes {
+		if !vote.Valid {
+			continue
+		}
+		if counts[vote.Answer] == 0 {
+			order = append(order, vote.Answer)
+		}
+		counts[vote.Answer]++
+	}
+	if len(order) == 0 {
+		return nil, ErrNoAnswer
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+	return &Result{
+		Answer:     order[0],
+		Confidence: float64(counts[order[0]]) / float64(len(votes)),
+		Votes:      votes,
+	}, nil
+}
+
+// ExtractNumber finds the numeric answer in a possibly verbose reply. An
+// explicit "answer is N" wins; otherwise the last number is used, since
+// models tend to put the conclusion at the end. Decimals are truncated.
+func ExtractNumber(reply string) (int, bool) {
+	if match := answerPattern.FindStringSubmatch(reply); match != nil {
+		if n, ok := parseNumber(match[1]); ok {
+			return n, true
+		}
+	}
+
+	matches := numberPattern.FindAllString(reply, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	return parseNumber(matches[len(matches)-1])
+}
+
+// parseNumber parses an integer that may use thousands separators or a decimal part
+func parseNumber(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.NewReplacer(" ", "", "\u00a0", "").Replace(s)
+	if i := strings.Index(s, ","); i >= 0 && i == strings.LastIndex(s, ",") && len(s)-i-1 <= 2 {
+		// A single comma followed by one or two digits is a decimal comma, as in "1,5"
+		s = strings.ReplaceAll(s[:i], ".", "")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+		// A dot is a thousands separator only when followed by exactly three digits
+		if i := strings.LastIndex(s, "."); i >= 0 {
+			if len(s)-i-1 == 3 {
+				s = strings.ReplaceAll(s, ".", "")
+			} else {
+				s = s[:i]
+			}
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
@@ -0,0 +1,101 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExtractNumber(t *testing.T) {
+	tests := []struct {
+		reply    string
+		expected int
+		ok       bool
+	}{
+		{"1945", 1945, true},
+		{" 1945\n", 1945, true},
+		{"-44", -44, true},
+		{"The war ended in 1945.", 1945, true},
+		{"1,500", 1500, true},
+		{"1.500", 1500, true},
+		{"1 500 000", 1500000, true},
+		{"1 500", 1500, true},
+		{"3.14", 3, true},
+		{"1,5", 1, true},
+		{"12,75", 12, true},
+		{"1.234,5", 1234, true},
+		{"Mieszko I was baptised in 966, about 1,5 thousand years ago", 1, true},
+		{"The answer is 1815, not 1814", 1815, true},
+		{"Result: 2 500", 2500, true},
+		{"= 42", 42, true},
+		{"Between 1939 and 1945 the last year was 1945", 1945, true},
+		{"I don't know", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		n, ok := ExtractNumber(test.reply)
+		if n != test.expected || ok != test.ok {
+			t.Errorf("ExtractNumber(%q) = %d, %v, expected %d, %v", test.reply, n, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestTally(t *testing.T) {
+	vote := func(answer int) Vote { return Vote{Answer: answer, Valid: true} }
+	invalid := Vote{Reply: "no idea"}
+	tests := []struct {
+		name       string
+		votes      []Vote
+		answer     int
+		confidence float64
+	}{
+		{"unanimous", []Vote{vote(1945), vote(1945)}, 1945, 1},
+		{"majority", []Vote{vote(1944), vote(1945), vote(1945)}, 1945, 2.0 / 3},
+		{"tie goes to the answer seen first", []Vote{vote(1944), vote(1945), vote(1945), vote(1944)}, 1944, 0.5},
+		{"three way tie", []Vote{vote(3), vote(2), vote(1)}, 3, 1.0 / 3},
+		{"invalid votes count against confidence", []Vote{invalid, vote(7), invalid, invalid}, 7, 0.25},
+		{"invalid votes do not break ties", []Vote{vote(0), invalid, vote(5)}, 0, 1.0 / 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := tally(test.votes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Answer != test.answer || result.Confidence != test.confidence {
+				t.Fatalf("expected %d with confidence %v, got %d with %v", test.answer, test.confidence, result.Answer, result.Confidence)
+			}
+		})
+	}
+
+	if _, err := tally([]Vote{invalid, invalid}); !errors.Is(err, ErrNoAnswer) {
+		t.Fatalf("expected ErrNoAnswer, got %v", err)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	replies := []string{"1945", "The answer is 1945", "1944", "error"}
+	next := make(chan string, len(replies))
+	for _, reply := range replies {
+		next <- reply
+	}
+	solver := &Solver{
+		Samplers: []Sampler{{Name: "fake", Sample: func(ctx context.Context, question string) (string, error) {
+			reply := <-next
+			if reply == "error" {
+				return "", fmt.Errorf("sample failed")
+			}
+			return reply, nil
+		}}},
+		Samples: len(replies),
+	}
+
+	result, err := solver.Solve(context.Background(), "When did the war end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Answer != 1945 || result.Agreeing() != 2 || result.Confidence != 0.5 || len(result.Votes) != 4 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,136 @@
+package centrala
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/retry"
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultBaseURL = "https://centrala.ag3nts.org"
+
+// Client submits task answers to the Centrala /report endpoint
+type Client struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+	// Lookup resolves {{name}} placeholders in answers other than {{apikey}},
+	// typically utils.LookupAPIKey. Only the names in Secrets are looked up.
+	Lookup  LookupFunc
+	Secrets []string
+}
+
+// Response is the reply Centrala sends for every report
+type Response struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Success reports whether Centrala accepted the answer
+func (r *Response) Success() bool {
+	return r.Code == 0
+}
+
+// NewClient creates a new Centrala client for the given AIDevs API key
+func NewClient(apiKey string) *Client {
+	return &Client{
+		apiKey:     apiKey,
+		baseURL:    DefaultBaseURL,
+		httpClient: httpclient.Default,
+	}
+}
+
+// Report sends the answer for a task. The answer can be any JSON-serializable value.
+// Top-level placeholders such as {{apikey}} in the answer are filled in just
+// before sending.
+func (c *Client) Report(ctx context.Context, task string, answer any) (*Response, error) {
+	answerJSON, err := json.Marshal(answer)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling answer: %w", err)
+	}
+	return c.ReportJSON(ctx, task, bytes.NewReader(answerJSON))
+}
+
+// ReportJSON sends an answer that is already encoded as JSON, streaming it
+// from the reader. Top-level placeholders are filled in while streaming.
+func (c *Client) ReportJSON(ctx context.Context, task string, answer io.Reader) (*Response, error) {
+	url := c.baseURL + "/report"
+
+	// The payload is assembled around the answer so it is never decoded
+	prefix, err := json.Marshal(map[string]string{"task": task, "apikey": c.apiKey})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling payload: %w", err)
+	}
+	// The answer is recorded in the run ledger before placeholders are filled
+	var recorder *ledger.AnswerRecorder
+	payload := func() io.Reader {
+		recorder = ledger.Current().AnswerRecorder(task)
+		return io.MultiReader(
+			bytes.NewReader(prefix[:len(prefix)-1]),
+			strings.NewReader(`,"answer":`),
+			NewJSONFiller(ctx, io.TeeReader(answer, recorder), c.resolver()),
+			strings.NewReader("}"),
+		)
+	}
+
+	log.Debug().
+		Str("url", url).
+		Str("task", task).
+		Msg("Sending answer to Centrala")
+
+	// A report may count as an attempt once it reaches Centrala, so it is
+	// sent again only when it was not received, and only when the answer can
+	// be read from the start once more
+	req, err := http.NewRequestWithContext(retry.WithUnprocessedRetries(ctx), http.MethodPost, url, payload())
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if seeker, ok := answer.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+					return nil, fmt.Errorf("error rewinding answer: %w", err)
+				}
+				return io.NopCloser(payload()), nil
+			}
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	recorder.Commit()
+
+	// Centrala replies with code/message also for rejected answers (non-200 status)
+	var result Response
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unexpected response (status %d): %s", resp.StatusCode, string(body))
+	}
+	ledger.Current().SetResponse(result.Code, result.Message)
+
+	log.Info().
+		Str("task", task).
+		Int("code", result.Code).
+		Str("message", result.Message).
+		Msg("Centrala responded")
+
+	return &result, nil
+}
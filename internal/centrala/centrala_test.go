@@ -0,0 +1,71 @@
+package centrala
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// answerFile writes an answer with a placeholder and opens it
+func answerFile(t *testing.T) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "answer.json")
+	if err := os.WriteFile(path, []byte(`{"apikey": "{{apikey}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestReportJSONRetriesRateLimitedAnswer(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After-Ms", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"code": 0, "message": "{{FLG:OK}}"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key-123")
+	client.baseURL = server.URL
+	resp, err := client.ReportJSON(context.Background(), "TASK", answerFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"apikey":"key-123","task":"TASK","answer":{"apikey": "key-123"}}`
+	if !resp.Success() || len(bodies) != 2 || bodies[1] != expected {
+		t.Fatalf("expected the answer resent in full, got %q", bodies)
+	}
+}
+
+func TestReportJSONDoesNotRetryServerErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The report may have been counted before the failure
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient("key-123")
+	client.baseURL = server.URL
+	if resp, err := client.ReportJSON(context.Background(), "TASK", answerFile(t)); err == nil && resp.Success() {
+		t.Fatal("expected the failed report to be returned")
+	}
+	if calls != 1 {
+		t.Fatalf("expected the report to be sent once, got %d calls", calls)
+	}
+}
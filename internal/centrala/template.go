@@ -0,0 +1,163 @@
+package centrala
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+)
+
+// APIKeyPlaceholder is replaced with the client's AIDevs key when an answer is submitted
+const APIKeyPlaceholder = "{{apikey}}"
+
+// maxPlaceholderLength bounds how far the stream filler looks for a closing "}}"
+const maxPlaceholderLength = 64
+
+// ErrUnknownPlaceholder is returned when an answer references a secret that cannot be resolved
+var ErrUnknownPlaceholder = errors.New("unknown placeholder")
+
+var placeholderPattern = regexp.MustCompile(`^\{\{\s*([A-Za-z0-9_-]+)\s*\}\}$`)
+
+// LookupFunc resolves a placeholder name to its secret value
+type LookupFunc func(ctx context.Context, name string) (string, bool)
+
+// Placeholder returns the template reference for a secret name, e.g. {{zip-password}}
+func Placeholder(name string) string {
+	return "{{" + name + "}}"
+}
+
+// resolver answers "apikey" with the client key and the names listed in
+// Secrets with Lookup, so secrets never have to appear in task outputs
+func (c *Client) resolver() LookupFunc {
+	return func(ctx context.Context, name string) (string, bool) {
+		if name == "apikey" {
+			return c.apiKey, true
+		}
+		if c.Lookup != nil && slices.Contains(c.Secrets, name) {
+			return c.Lookup(ctx, name)
+		}
+		return "", false
+	}
+}
+
+// FillJSON replaces {{name}} placeholders inside a JSON document. Values are
+// escaped for use inside JSON strings.
+func FillJSON(ctx context.Context, data []byte, lookup LookupFunc) ([]byte, error) {
+	filled, err := io.ReadAll(NewJSONFiller(ctx, bytes.NewReader(data), lookup))
+	if err != nil {
+		return nil, err
+	}
+	return filled, nil
+}
+
+// jsonFiller replaces placeholders while streaming, so large answers never
+// have to be held in memory. It follows the JSON structure just enough to
+// know where a top-level value starts.
+type jsonFiller struct {
+	ctx    context.Context
+	src    *bufio.Reader
+	lookup LookupFunc
+	buf    bytes.Buffer
+	err    error
+
+	depth    int
+	inString bool
+	escaped  bool
+	// afterColon is set between a key and the start of its value
+	afterColon bool
+}
+
+// NewJSONFiller wraps a JSON stream and replaces placeholders as it is read.
+// Only string values of the top-level object that are exactly {{name}} are
+// filled; placeholders anywhere else, such as inside model replies, are
+// left as they are. An unresolvable placeholder fails the read with
+// ErrUnknownPlaceholder. Lookups made while reading use ctx.
+func NewJSONFiller(ctx context.Context, r io.Reader, lookup LookupFunc) io.Reader {
+	return &jsonFiller{ctx: ctx, src: bufio.NewReader(r), lookup: lookup}
+}
+
+func (f *jsonFiller) Read(p []byte) (int, error) {
+	for f.buf.Len() == 0 && f.err == nil {
+		f.err = f.fill()
+	}
+	if f.buf.Len() > 0 {
+		return f.buf.Read(p)
+	}
+	return 0, f.err
+}
+
+// fill moves the next run of JSON, or one resolved placeholder, into buf
+func (f *jsonFiller) fill() error {
+	for f.buf.Len() < 4096 {
+		b, err := f.src.ReadByte()
+		if err != nil {
+			return err
+		}
+		if f.inString {
+			f.buf.WriteByte(b)
+			switch {
+			case f.escaped:
+				f.escaped = false
+			case b == '\\':
+				f.escaped = true
+			case b == '"':
+				f.inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			if f.depth == 1 && f.afterColon {
+				if filled, err := f.placeholder(); filled || err != nil {
+					f.afterColon = false
+					return err
+				}
+			}
+			f.inString = true
+		case '{', '[':
+			f.depth++
+		case '}', ']':
+			f.depth--
+		}
+		if b == ':' {
+			f.afterColon = true
+		} else if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			f.afterColon = false
+		}
+		f.buf.WriteByte(b)
+	}
+	return nil
+}
+
+// placeholder fills the string value starting after the opening quote that
+// was just read if it is exactly a placeholder
+func (f *jsonFiller) placeholder() (bool, error) {
+	ahead, _ := f.src.Peek(maxPlaceholderLength)
+	end := bytes.IndexByte(ahead, '"')
+	if end < 0 {
+		return false, nil
+	}
+	match := placeholderPattern.FindSubmatch(ahead[:end])
+	if match == nil {
+		return false, nil
+	}
+
+	name := string(match[1])
+	value, ok := f.lookup(f.ctx, name)
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownPlaceholder, name)
+	}
+	escaped, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("error escaping %s: %w", name, err)
+	}
+	f.buf.Write(escaped)
+	f.src.Discard(end + 1)
+	return true, nil
+}
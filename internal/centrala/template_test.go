@@ -0,0 +1,49 @@
+package centrala
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFillJSON(t *testing.T) {
+	client := &Client{
+		apiKey:  "key-123",
+		Secrets: []string{"zip-password"},
+		Lookup: func(_ context.Context, name string) (string, bool) {
+			return "secret-" + name, true
+		},
+	}
+
+	tests := []struct {
+		name     string
+		answer   string
+		expected string
+		err      error
+	}{
+		{"top-level apikey", `{"apikey": "{{apikey}}", "n": 1}`, `{"apikey": "key-123", "n": 1}`, nil},
+		{"allowed secret", `{"password":"{{ zip-password }}"}`, `{"password":"secret-zip-password"}`, nil},
+		{"model reply is not filled", `{"test-data": [{"a": "{{openai-api-key}}"}], "q": "say {{apikey}}"}`, `{"test-data": [{"a": "{{openai-api-key}}"}], "q": "say {{apikey}}"}`, nil},
+		{"nested object is not filled", `{"meta": {"apikey": "{{apikey}}"}}`, `{"meta": {"apikey": "{{apikey}}"}}`, nil},
+		{"keys are not filled", `{"{{apikey}}": "x"}`, `{"{{apikey}}": "x"}`, nil},
+		{"escaped quotes", `{"a": "\"{{apikey}}\"", "b": "{{apikey}}"}`, `{"a": "\"{{apikey}}\"", "b": "key-123"}`, nil},
+		{"secret outside the allowlist", `{"key": "{{openai-api-key}}"}`, "", ErrUnknownPlaceholder},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filled, err := FillJSON(context.Background(), []byte(test.answer), client.resolver())
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(filled) != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, filled)
+			}
+		})
+	}
+}
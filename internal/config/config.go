@@ -0,0 +1,332 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDir holds one <task>.yaml file per task
+const DefaultDir = "configs"
+
+// masked replaces secret values when the configuration is printed
+const masked = "********"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is one configurable struct field, described by its tags:
+//
+//	yaml:"model"          key in configs/<task>.yaml
+//	env:"MP3_MODEL"       environment variable
+//	flag:"model"          command line flag
+//	usage:"..."           flag help
+//	required:"true"       must not be empty after all layers
+//	secret:"xyz-password" resolved through the secret chain when still empty, masked when printed
+type field struct {
+	index    int
+	key      string
+	env      string
+	flag     string
+	usage    string
+	required bool
+	secret   string
+}
+
+// Loader fills a task configuration struct from layered sources, each
+// overriding the previous one: the values the struct was created with,
+// configs/<task>.yaml, environment variables, command line flags and, for
+// fields still empty, the secret chain.
+type Loader struct {
+	task   string
+	cfg    reflect.Value
+	fields []field
+
+	path       *string
+	print      *bool
+	flagValues map[string]string
+}
+
+// New describes cfg, a pointer to a struct, and registers its flags on fs
+// along with -config and -print-config. Call Load after fs is parsed.
+func New(task string, cfg any, fs *flag.FlagSet) *Loader {
+	value := reflect.ValueOf(cfg)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config: %T is not a pointer to a struct", cfg))
+	}
+
+	l := &Loader{task: task, cfg: value.Elem(), flagValues: make(map[string]string)}
+	structType := l.cfg.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		tags := structType.Field(i).Tag
+		key := strings.Split(tags.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		f := field{
+			index:    i,
+			key:      key,
+			env:      tags.Get("env"),
+			flag:     tags.Get("flag"),
+			usage:    tags.Get("usage"),
+			required: tags.Get("required") == "true",
+			secret:   tags.Get("secret"),
+		}
+		l.fields = append(l.fields, f)
+		l.registerFlag(fs, f)
+	}
+
+	l.path = fs.String("config", filepath.Join(DefaultDir, task+".yaml"), "configuration file")
+	l.print = fs.Bool("print-config", false, "print the effective configuration, secrets masked, and exit")
+	return l
+}
+
+func (l *Loader) registerFlag(fs *flag.FlagSet, f field) {
+	if f.flag == "" {
+		return
+	}
+	value := l.cfg.Field(f.index)
+	usage := f.usage
+	if f.secret == "" && !value.IsZero() {
+		usage += fmt.Sprintf(" (default %s)", formatValue(value))
+	}
+	store := func(s string) error {
+		if err := setValue(reflect.New(value.Type()).Elem(), s); err != nil {
+			return err
+		}
+		l.flagValues[f.flag] = s
+		return nil
+	}
+	if value.Kind() == reflect.Bool {
+		fs.BoolFunc(f.flag, usage, store)
+		return
+	}
+	fs.Func(f.flag, usage, store)
+}
+
+// Load applies the layers and validates the result; ctx bounds secret lookups
+func (l *Loader) Load(ctx context.Context) error {
+	if err := l.loadFile(); err != nil {
+		return err
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Debug().Err(err).Msg("Error loading .env file")
+	}
+	for _, f := range l.fields {
+		if f.env == "" {
+			continue
+		}
+		if s, ok := os.LookupEnv(f.env); ok && s != "" {
+			if err := setValue(l.cfg.Field(f.index), s); err != nil {
+				return fmt.Errorf("config %s: %s: %w", l.task, f.env, err)
+			}
+		}
+	}
+
+	for _, f := range l.fields {
+		if s, ok := l.flagValues[f.flag]; ok && f.flag != "" {
+			if err := setValue(l.cfg.Field(f.index), s); err != nil {
+				return fmt.Errorf("config %s: -%s: %w", l.task, f.flag, err)
+			}
+		}
+	}
+
+	for _, f := range l.fields {
+		value := l.cfg.Field(f.index)
+		if f.secret == "" || value.Kind() != reflect.String {
+			continue
+		}
+		if value.IsZero() {
+			if secret, ok := utils.LookupAPIKey(ctx, f.secret); ok {
+				value.SetString(secret)
+			}
+			continue
+		}
+		// Secrets given in the file, environment or flags are kept out of the logs too
+		logging.RegisterSecret(value.String())
+	}
+
+	return l.validate()
+}
+
+// LoadOrExit loads the configuration, exiting on invalid configuration, and
+// prints it and exits when -print-config was given
+func (l *Loader) LoadOrExit(ctx context.Context) {
+	if err := l.Load(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	if *l.print {
+		if err := l.Print(os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("Failed to print configuration")
+		}
+		os.Exit(0)
+	}
+	log.Debug().Interface("config", l.Masked()).Msg("Configuration loaded")
+}
+
+// loadFile reads the YAML file; the default file may be missing, an explicit one may not
+func (l *Loader) loadFile() error {
+	file, err := os.Open(*l.path)
+	if errors.Is(err, os.ErrNotExist) && *l.path == filepath.Join(DefaultDir, l.task+".yaml") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config %s: %w", l.task, err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// Misspelled keys are errors rather than silently ignored settings
+	decoder.KnownFields(true)
+	if err := decoder.Decode(l.cfg.Addr().Interface()); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config %s: %s: %w", l.task, *l.path, err)
+	}
+	return nil
+}
+
+func (l *Loader) validate() error {
+	var problems []string
+	for _, f := range l.fields {
+		if !f.required || !l.cfg.Field(f.index).IsZero() {
+			continue
+		}
+		sources := []string{fmt.Sprintf("%s in %s", f.key, *l.path)}
+		if f.env != "" {
+			sources = append(sources, f.env)
+		}
+		if f.flag != "" {
+			sources = append(sources, "-"+f.flag)
+		}
+		if f.secret != "" {
+			sources = append(sources, "secret "+f.secret)
+		}
+		problems = append(problems, fmt.Sprintf("%s is required, set %s", f.key, strings.Join(sources, " or ")))
+	}
+
+	if validator, ok := l.cfg.Addr().Interface().(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("config %s: %s", l.task, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Masked returns the effective configuration by YAML key, secrets masked
+func (l *Loader) Masked() map[string]any {
+	values := make(map[string]any, len(l.fields))
+	for _, f := range l.fields {
+		values[f.key] = l.maskedValue(f)
+	}
+	return values
+}
+
+func (l *Loader) maskedValue(f field) any {
+	value := l.cfg.Field(f.index)
+	if f.secret != "" && !value.IsZero() {
+		return masked
+	}
+	if value.Type() == durationType {
+		return value.Interface().(time.Duration).String()
+	}
+	return value.Interface()
+}
+
+// Print writes the effective configuration as YAML in field order, secrets masked
+func (l *Loader) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range l.fields {
+		var value yaml.Node
+		if err := value.Encode(l.maskedValue(f)); err != nil {
+			return fmt.Errorf("error encoding %s: %w", f.key, err)
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.key}, &value)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("error encoding configuration: %w", err)
+	}
+	return encoder.Close()
+}
+
+// setValue parses s into a field; lists are comma separated
+func setValue(value reflect.Value, s string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		value.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", value.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+func formatValue(value reflect.Value) string {
+	if value.Type() == durationType {
+		return value.Interface().(time.Duration).String()
+	}
+	if value.Kind() == reflect.Slice {
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(value.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+)
+
+type testConfig struct {
+	Model    string        `yaml:"model" env:"TEST_MODEL" flag:"model" usage:"model" required:"true"`
+	Samples  int           `yaml:"samples" env:"TEST_SAMPLES" flag:"samples" usage:"samples"`
+	Timeout  time.Duration `yaml:"timeout" env:"TEST_TIMEOUT" flag:"timeout" usage:"timeout"`
+	Verbose  bool          `yaml:"verbose" env:"TEST_VERBOSE" flag:"verbose" usage:"verbose"`
+	Tags     []string      `yaml:"tags" env:"TEST_TAGS" flag:"tags" usage:"tags"`
+	Password string        `yaml:"password" env:"TEST_PASSWORD" flag:"password" usage:"password" secret:"test-password"`
+	Ignored  string
+}
+
+// load fills a testConfig created with defaults from configs/test.yaml,
+// the environment and args
+func load(t *testing.T, defaults testConfig, yaml string, env map[string]string, args ...string) (*testConfig, *Loader, error) {
+	t.Helper()
+	testkit.Workdir(t)
+	t.Cleanup(utils.OverrideSecrets(map[string]string{}))
+	if yaml != "" {
+		if err := os.MkdirAll(DefaultDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(DefaultDir, "test.yaml"), []byte(yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"TEST_MODEL", "TEST_SAMPLES", "TEST_TIMEOUT", "TEST_VERBOSE", "TEST_TAGS", "TEST_PASSWORD"} {
+		t.Setenv(name, env[name])
+	}
+
+	cfg := defaults
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	loader := New("test", &cfg, fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg, loader, loader.Load(context.Background())
+}
+
+func TestPrecedence(t *testing.T) {
+	defaults := testConfig{Model: "default", Samples: 1}
+	tests := []struct {
+		name     string
+		yaml     string
+		env      map[string]string
+		args     []string
+		expected string
+	}{
+		{"default", "", nil, nil, "default"},
+		{"yaml over default", "model: yaml\n", nil, nil, "yaml"},
+		{"env over yaml", "model: yaml\n", map[string]string{"TEST_MODEL": "env"}, nil, "env"},
+		{"flag over env", "model: yaml\n", map[string]string{"TEST_MODEL": "env"}, []string{"-model", "flag"}, "flag"},
+		{"flag over default", "", nil, []string{"-model=flag"}, "flag"},
+		{"empty env is unset", "model: yaml\n", map[string]string{"TEST_MODEL": ""}, nil, "yaml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, _, err := load(t, defaults, test.yaml, test.env, test.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Model != test.expected {
+				t.Fatalf("expected model %q, got %q", test.expected, cfg.Model)
+			}
+			// Fields no layer sets keep their default
+			if cfg.Samples != 1 {
+				t.Fatalf("expected the default samples, got %d", cfg.Samples)
+			}
+		})
+	}
+}
+
+func TestTypes(t *testing.T) {
+	yaml := "samples: 3\ntimeout: 5s\ntags: [a, b]\n"
+	env := map[string]string{"TEST_SAMPLES": "4", "TEST_TAGS": "c, d,"}
+	cfg, _, err := load(t, testConfig{}, yaml, env, "-model", "m", "-timeout", "1m", "-verbose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Samples != 4 || cfg.Timeout != time.Minute || !cfg.Verbose || strings.Join(cfg.Tags, "|") != "c|d" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	_, _, err := load(t, testConfig{}, "samples: 2\n", nil)
+	if err == nil {
+		t.Fatal("expected an error for the missing model")
+	}
+	for _, source := range []string{"model is required", "model in configs/test.yaml", "TEST_MODEL", "-model"} {
+		if !strings.Contains(err.Error(), source) {
+			t.Errorf("expected %q in %v", source, err)
+		}
+	}
+
+	// Any layer satisfies a required field
+	if _, _, err := load(t, testConfig{}, "", map[string]string{"TEST_MODEL": "env"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{"unknown key", "modle: x\n", nil, nil, "field modle not found"},
+		{"wrong type in yaml", "samples: many\n", nil, nil, "cannot unmarshal"},
+		{"invalid env", "model: x\n", map[string]string{"TEST_SAMPLES": "many"}, nil, `TEST_SAMPLES: invalid integer "many"`},
+		{"explicit file missing", "", nil, []string{"-config", "missing.yaml"}, "missing.yaml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := load(t, testConfig{}, test.yaml, test.env, test.args...)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	testkit.Workdir(t)
+	t.Cleanup(utils.OverrideSecrets(map[string]string{"test-password": "from-the-chain"}))
+	cfg := testConfig{Model: "m"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := New("test", &cfg, fs)
+	fs.Parse(nil)
+	t.Setenv("TEST_PASSWORD", "")
+	if err := loader.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "from-the-chain" {
+		t.Fatalf("expected the password from the secret chain, got %q", cfg.Password)
+	}
+
+	// A value from another layer wins over the chain
+	fromEnv, _, err := load(t, testConfig{Model: "m"}, "", map[string]string{"TEST_PASSWORD": "from-env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromEnv.Password != "from-env" {
+		t.Fatalf("expected the password from the environment, got %q", fromEnv.Password)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	_, loader, err := load(t, testConfig{Samples: 2}, "", map[string]string{"TEST_PASSWORD": "hunter22"}, "-model", "m", "-tags", "a,b", "-timeout", "90s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := loader.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `model: m
+samples: 2
+timeout: 1m30s
+verbose: false
+tags:
+  - a
+  - b
+password: '********'
+`
+	if out.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+	if masked := loader.Masked(); masked["password"] != "********" || masked["model"] != "m" {
+		t.Fatalf("unexpected masked values %v", masked)
+	}
+}
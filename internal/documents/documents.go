@@ -0,0 +1,202 @@
+package documents
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/archive"
+	"github.com/dawidjelenkowski/aidevs3go/internal/transcribe"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vision"
+	"github.com/rs/zerolog/log"
+)
+
+// Document kinds
+const (
+	KindText    = "text"
+	KindAudio   = "audio"
+	KindImage   = "image"
+	KindArchive = "archive"
+)
+
+var (
+	textExtensions  = map[string]bool{".txt": true, ".md": true}
+	audioExtensions = map[string]bool{".mp3": true, ".wav": true, ".m4a": true}
+)
+
+// Document is a source file normalized to plain text
+type Document struct {
+	Path string
+	Name string
+	Kind string
+	Text string
+
+	// Archive is the zip file the document was extracted from, if any
+	Archive string
+}
+
+// Loader normalizes text, audio and image files to text.
+// Transcripts and OCR results are cached in CacheDir.
+type Loader struct {
+	TranscribeAPIKey string
+	TranscribeModel  string // "whisper" or "gemini"
+	VisionAPIKey     string
+	VisionProvider   string // vision.ProviderOpenAI or vision.ProviderGemini
+	CacheDir         string
+
+	// ExpandArchives makes LoadDir unpack zip files into CacheDir and load their contents
+	ExpandArchives  bool
+	ArchivePassword string
+}
+
+// DetectKind returns the document kind of a file, or an empty string if the
+// file is not supported. Images are recognized by content, not by extension.
+func DetectKind(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case textExtensions[ext]:
+		return KindText
+	case audioExtensions[ext]:
+		return KindAudio
+	case archive.IsZip(path):
+		return KindArchive
+	case vision.IsImageFile(path):
+		return KindImage
+	}
+	return ""
+}
+
+// Load reads a single file and returns its text content
+func (l *Loader) Load(ctx context.Context, path string) (*Document, error) {
+	doc := &Document{
+		Path: path,
+		Name: filepath.Base(path),
+		Kind: DetectKind(path),
+	}
+
+	var err error
+	switch doc.Kind {
+	case KindText:
+		var data []byte
+		data, err = os.ReadFile(path)
+		doc.Text = string(data)
+	case KindAudio:
+		doc.Text, err = l.transcribe(ctx, path)
+	case KindImage:
+		doc.Text, err = vision.CachedAnalyzeImage(ctx, l.VisionAPIKey, path, l.CacheDir, l.VisionProvider, vision.ModeOCR)
+	default:
+		return nil, fmt.Errorf("unsupported document type: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s document '%s': %w", doc.Kind, path, err)
+	}
+
+	doc.Text = strings.TrimSpace(doc.Text)
+	return doc, nil
+}
+
+// LoadDir loads every supported file in the directory (non-recursive),
+// sorted by file name. Unsupported files are skipped.
+func (l *Loader) LoadDir(ctx context.Context, dir string) ([]*Document, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+
+	var docs []*Document
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		kind := DetectKind(path)
+		if kind == "" || (kind == KindArchive && !l.ExpandArchives) {
+			log.Debug().Str("path", path).Msg("Skipping unsupported file")
+			continue
+		}
+
+		if kind == KindArchive {
+			archiveDocs, err := l.LoadArchive(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, archiveDocs...)
+			continue
+		}
+
+		doc, err := l.Load(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	return docs, nil
+}
+
+// LoadArchive extracts a zip file into CacheDir and loads every supported file it contains
+func (l *Loader) LoadArchive(ctx context.Context, path string) ([]*Document, error) {
+	baseFileName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	destDir := filepath.Join(l.CacheDir, baseFileName)
+
+	manifest, err := archive.Extract(path, destDir, archive.Options{Password: l.ArchivePassword})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archive '%s': %w", path, err)
+	}
+
+	var docs []*Document
+	for _, entry := range manifest.Files {
+		kind := DetectKind(entry.Path)
+		if kind == "" || kind == KindArchive {
+			log.Debug().Str("path", entry.Path).Msg("Skipping unsupported archive entry")
+			continue
+		}
+
+		doc, err := l.Load(ctx, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		doc.Name = entry.Name
+		doc.Archive = path
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// transcribe returns the cached transcript of an audio file or creates it
+func (l *Loader) transcribe(ctx context.Context, path string) (string, error) {
+	outputFilePath := filepath.Join(l.CacheDir, transcribe.TranscriptFileName(path))
+
+	if cached, err := os.ReadFile(outputFilePath); err == nil {
+		log.Info().Str("inputFilePath", path).Str("outputFilePath", outputFilePath).Msg("Transcription file already exists, skipping")
+		return string(cached), nil
+	}
+
+	var transcript string
+	var err error
+	switch l.TranscribeModel {
+	case "whisper":
+		transcript, err = transcribe.WhisperTranscribeAudio(ctx, l.TranscribeAPIKey, path)
+	case "gemini":
+		transcript, err = transcribe.AudioGemini(ctx, l.TranscribeAPIKey, path)
+	default:
+		return "", fmt.Errorf("unknown transcription model '%s'", l.TranscribeModel)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(l.CacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory '%s': %w", l.CacheDir, err)
+	}
+	if err := os.WriteFile(outputFilePath, []byte(transcript), 0644); err != nil {
+		return "", fmt.Errorf("failed to save transcription '%s': %w", outputFilePath, err)
+	}
+	log.Info().Str("inputFilePath", path).Str("outputFilePath", outputFilePath).Msg("Transcription saved")
+
+	return transcript, nil
+}
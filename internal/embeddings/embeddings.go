@@ -0,0 +1,254 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Supported providers
+const (
+	ProviderOpenAI = "openai"
+	ProviderGemini = "gemini"
+	ProviderHash   = "hash"
+)
+
+const (
+	geminiModel     = "text-embedding-004"
+	geminiURL       = "https://generativelanguage.googleapis.com/v1beta/models/" + geminiModel + ":batchEmbedContents"
+	geminiBatchSize = 100
+	openAIBatchSize = 512
+	hashDimensions  = 256
+)
+
+// Embedder turns texts into vectors
+type Embedder interface {
+	// Embed returns one vector per input text, in the same order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model identifies the embedding space; vectors from different models must not be mixed
+	Model() string
+}
+
+// New creates an embedder for the given provider. The hash provider does not need an API key.
+func New(provider, apiKey string) (Embedder, error) {
+	switch provider {
+	case ProviderOpenAI:
+		return NewOpenAIEmbedder(apiKey), nil
+	case ProviderGemini:
+		return NewGeminiEmbedder(apiKey), nil
+	case ProviderHash:
+		return NewHashEmbedder(hashDimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider '%s'", provider)
+	}
+}
+
+// OpenAIEmbedder uses the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client *openai.Client
+	model  openai.EmbeddingModel
+}
+
+func NewOpenAIEmbedder(apiKey string) *OpenAIEmbedder {
+	config := openai.DefaultConfig(apiKey)
+	config.HTTPClient = httpclient.SDK
+	return &OpenAIEmbedder{
+		client: openai.NewClientWithConfig(config),
+		model:  openai.SmallEmbedding3,
+	}
+}
+
+func (e *OpenAIEmbedder) Model() string {
+	return "openai/" + string(e.model)
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += openAIBatchSize {
+		end := min(start+openAIBatchSize, len(texts))
+		log.Debug().Int("batch_start", start).Int("batch_size", end-start).Msg("Requesting OpenAI embeddings")
+
+		resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input: texts[start:end],
+			Model: e.model,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("OpenAI embeddings request failed: %w", err)
+		}
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("OpenAI returned %d embeddings for %d texts", len(resp.Data), end-start)
+		}
+
+		// Results carry their index, don't rely on the order
+		batch := make([][]float32, end-start)
+		for _, item := range resp.Data {
+			batch[item.Index] = item.Embedding
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// GeminiEmbedder uses the Gemini batchEmbedContents REST endpoint
+type GeminiEmbedder struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewGeminiEmbedder(apiKey string) *GeminiEmbedder {
+	return &GeminiEmbedder{apiKey: apiKey, httpClient: httpclient.SDK}
+}
+
+func (e *GeminiEmbedder) Model() string {
+	return "gemini/" + geminiModel
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiEmbedRequest struct {
+	Model   string `json:"model"`
+	Content struct {
+		Parts []geminiPart `json:"parts"`
+	} `json:"content"`
+}
+
+type geminiBatchResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+func (e *GeminiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += geminiBatchSize {
+		end := min(start+geminiBatchSize, len(texts))
+		log.Debug().Int("batch_start", start).Int("batch_size", end-start).Msg("Requesting Gemini embeddings")
+
+		requests := make([]geminiEmbedRequest, end-start)
+		for i, text := range texts[start:end] {
+			requests[i].Model = "models/" + geminiModel
+			requests[i].Content.Parts = []geminiPart{{Text: text}}
+		}
+		body, err := json.Marshal(map[string]any{"requests": requests})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Gemini request: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, geminiURL+"?key="+e.apiKey, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Gemini request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := e.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("Gemini embeddings request failed: %w", err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Gemini response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Gemini embeddings request failed with status code %d: %s", resp.StatusCode, string(respBody))
+		}
+
+		var result geminiBatchResponse
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Gemini response: %w", err)
+		}
+		if len(result.Embeddings) != end-start {
+			return nil, fmt.Errorf("Gemini returned %d embeddings for %d texts", len(result.Embeddings), end-start)
+		}
+		for _, embedding := range result.Embeddings {
+			vectors = append(vectors, embedding.Values)
+		}
+	}
+	return vectors, nil
+}
+
+// HashEmbedder is a deterministic local embedder based on feature hashing of
+// words and character trigrams. It needs no network access, which makes it
+// useful for tests and offline runs; its quality is only lexical.
+type HashEmbedder struct {
+	dimensions int
+}
+
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash/%d", e.dimensions)
+}
+
+func (e *HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		e.add(vector, "w:"+word, 1.0)
+
+		// Trigrams make inflected forms (Zawadzka / Zawadzkiej) land close to each other
+		runes := []rune("^" + word + "$")
+		for j := 0; j+3 <= len(runes); j++ {
+			e.add(vector, "t:"+string(runes[j:j+3]), 0.5)
+		}
+	}
+
+	Normalize(vector)
+	return vector
+}
+
+// add hashes a feature into a bucket; a second hash bit picks the sign to reduce collision bias
+func (e *HashEmbedder) add(vector []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	bucket := int(sum % uint64(e.dimensions))
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[bucket] += weight
+}
+
+// Normalize scales the vector to unit length in place
+func Normalize(vector []float32) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+}
@@ -0,0 +1,65 @@
+package embeddings
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vectorstore"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder, err := New(ProviderHash, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{"Barbara Zawadzka", "Barbarę Zawadzką", "Barbara Zawadzka", "przetarty kabel w sektorze C"}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != len(texts) || len(vectors[0]) != hashDimensions {
+		t.Fatalf("expected %d vectors of %d dimensions, got %d", len(texts), hashDimensions, len(vectors))
+	}
+
+	var norm float64
+	for _, v := range vectors[0] {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-6 {
+		t.Fatalf("expected a unit vector, got norm %g", norm)
+	}
+	if similarity := vectorstore.CosineSimilarity(vectors[0], vectors[2]); math.Abs(similarity-1) > 1e-6 {
+		t.Fatalf("expected the same text to get the same vector, got %g", similarity)
+	}
+	inflected := vectorstore.CosineSimilarity(vectors[0], vectors[1])
+	unrelated := vectorstore.CosineSimilarity(vectors[0], vectors[3])
+	if inflected <= unrelated {
+		t.Fatalf("expected inflected forms (%g) closer than unrelated text (%g)", inflected, unrelated)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("word2vec", ""); err == nil {
+		t.Fatal("expected an unknown provider to be rejected")
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	testkit.NewOpenAI(t)
+	embedder := NewOpenAIEmbedder(testkit.OpenAIKey)
+	texts := []string{"sektor C", "Barbara Zawadzka", "sektor A"}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != len(texts) {
+		t.Fatalf("expected %d vectors, got %d", len(texts), len(vectors))
+	}
+	for i, text := range texts {
+		if similarity := vectorstore.CosineSimilarity(vectors[i], testkit.Embed(text)); math.Abs(similarity-1) > 1e-6 {
+			t.Fatalf("vector %d is not the embedding of %q", i, text)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Checker names used in datasets and on the command line
+const (
+	CheckExact   = "exact"
+	CheckRegex   = "regex"
+	CheckNumeric = "numeric"
+	CheckJSON    = "json"
+	CheckJudge   = "judge"
+)
+
+// Verdict is the score of one output
+type Verdict struct {
+	Pass   bool   `json:"pass"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Checker scores a model output against the expectation of a case. An error
+// means the output could not be scored, not that it is wrong.
+type Checker interface {
+	Check(ctx context.Context, c Case, output string) (Verdict, error)
+}
+
+// CheckerOptions configure the built-in checkers
+type CheckerOptions struct {
+	// Tolerance is the largest difference the numeric checker accepts
+	Tolerance float64
+	// Judge and JudgeModel grade outputs for the judge checker
+	Judge      Completer
+	JudgeModel string
+}
+
+// NewChecker returns the built-in checker with the given name
+func NewChecker(name string, opts CheckerOptions) (Checker, error) {
+	switch name {
+	case CheckExact:
+		return ExactChecker{}, nil
+	case CheckRegex:
+		return RegexChecker{}, nil
+	case CheckNumeric:
+		return NumericChecker{Tolerance: opts.Tolerance}, nil
+	case CheckJSON:
+		return JSONChecker{}, nil
+	case CheckJudge:
+		if opts.Judge == nil || opts.JudgeModel == "" {
+			return nil, fmt.Errorf("the judge checker needs a judge model")
+		}
+		return &JudgeChecker{Complete: opts.Judge, Model: opts.JudgeModel}, nil
+	}
+	return nil, fmt.Errorf("unknown checker '%s', use exact, regex, numeric, json or judge", name)
+}
+
+// ExactChecker passes outputs equal to the expected text, ignoring
+// surrounding whitespace
+type ExactChecker struct{}
+
+func (ExactChecker) Check(_ context.Context, c Case, output string) (Verdict, error) {
+	expected := strings.TrimSpace(c.ExpectedText())
+	if strings.TrimSpace(output) == expected {
+		return Verdict{Pass: true}, nil
+	}
+	return Verdict{Reason: fmt.Sprintf("expected %q", expected)}, nil
+}
+
+// RegexChecker passes outputs matching the expected pattern anywhere
+type RegexChecker struct{}
+
+func (RegexChecker) Check(_ context.Context, c Case, output string) (Verdict, error) {
+	pattern, err := regexp.Compile(c.ExpectedText())
+	if err != nil {
+		return Verdict{}, fmt.Errorf("invalid pattern in case %s: %w", c.Name, err)
+	}
+	if pattern.MatchString(output) {
+		return Verdict{Pass: true}, nil
+	}
+	return Verdict{Reason: fmt.Sprintf("does not match %s", pattern)}, nil
+}
+
+// numberPattern finds numbers with a dot or comma as the decimal separator
+var numberPattern = regexp.MustCompile(`-?\d+(?:[.,]\d+)?`)
+
+// NumericChecker passes outputs whose number is within Tolerance of the
+// expected one. A reply that is not just a number is read by its last
+// number, where models usually state the result.
+type NumericChecker struct {
+	Tolerance float64
+}
+
+func (n NumericChecker) Check(_ context.Context, c Case, output string) (Verdict, error) {
+	expected, err := parseNumber(c.ExpectedText())
+	if err != nil {
+		return Verdict{}, fmt.Errorf("expected value of case %s is not a number: %w", c.Name, err)
+	}
+	got, err := parseNumber(output)
+	if err != nil {
+		numbers := numberPattern.FindAllString(output, -1)
+		if len(numbers) == 0 {
+			return Verdict{Reason: "no number in the output"}, nil
+		}
+		if got, err = parseNumber(numbers[len(numbers)-1]); err != nil {
+			return Verdict{Reason: "no number in the output"}, nil
+		}
+	}
+	if diff := math.Abs(got - expected); diff > n.Tolerance {
+		return Verdict{Reason: fmt.Sprintf("got %g, expected %g", got, expected)}, nil
+	}
+	return Verdict{Pass: true}, nil
+}
+
+func parseNumber(text string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(text), ",", "."), 64)
+}
+
+// JSONChecker passes outputs that decode to the same JSON as the expected
+// value, ignoring formatting and key order. Markdown code fences around the
+// output are removed.
+type JSONChecker struct{}
+
+func (JSONChecker) Check(_ context.Context, c Case, output string) (Verdict, error) {
+	var expected any
+	if err := json.Unmarshal([]byte(c.ExpectedText()), &expected); err != nil {
+		return Verdict{}, fmt.Errorf("expected value of case %s is not JSON: %w", c.Name, err)
+	}
+	var got any
+	if err := json.Unmarshal([]byte(stripFence(output)), &got); err != nil {
+		return Verdict{Reason: "output is not JSON: " + err.Error()}, nil
+	}
+	if !reflect.DeepEqual(expected, got) {
+		return Verdict{Reason: fmt.Sprintf("expected %s", c.ExpectedText())}, nil
+	}
+	return Verdict{Pass: true}, nil
+}
+
+// stripFence removes a ```json ... ``` fence around text
+func stripFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if newline := strings.Index(text, "\n"); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+const judgeSystem = `You grade the output of another model. Compare the output with the expected answer for the given input.
+The output passes when it means the same as the expected answer, even if it is worded differently. It fails when it is wrong, incomplete or adds false information.
+Reply only with JSON: {"pass": true or false, "reason": "one short sentence"}`
+
+// JudgeChecker asks a model whether the output means the same as the
+// expected answer
+type JudgeChecker struct {
+	Complete Completer
+	Model    string
+}
+
+func (j *JudgeChecker) Check(ctx context.Context, c Case, output string) (Verdict, error) {
+	input, err := json.Marshal(c.Input)
+	if err != nil {
+		input = []byte(fmt.Sprint(c.Input))
+	}
+	prompt := fmt.Sprintf("<input>\n%s\n</input>\n<expected>\n%s\n</expected>\n<output>\n%s\n</output>", input, c.ExpectedText(), output)
+
+	completion, err := j.Complete(ctx, Request{Model: j.Model, System: judgeSystem, Prompt: prompt})
+	if err != nil {
+		return Verdict{}, fmt.Errorf("judge failed: %w", err)
+	}
+	var verdict Verdict
+	if err := json.Unmarshal([]byte(stripFence(completion.Text)), &verdict); err != nil {
+		return Verdict{}, fmt.Errorf("judge replied with no verdict: %q", completion.Text)
+	}
+	return verdict, nil
+}
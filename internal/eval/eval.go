@@ -0,0 +1,154 @@
+// Package eval runs a prompt template against a dataset of inputs and
+// expected outputs on several models and scores the replies, so prompt
+// changes can be compared without submitting answers to Centrala.
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dataset is a prompt with the cases it is evaluated on
+type Dataset struct {
+	Name string `yaml:"name" json:"name"`
+	// System is the system message, a template like Prompt
+	System string `yaml:"system" json:"system,omitempty"`
+	// Prompt is a text/template rendered with the input of each case
+	Prompt string `yaml:"prompt" json:"prompt"`
+	// Checker scores the cases that do not name their own
+	Checker string `yaml:"checker" json:"checker,omitempty"`
+	// Tolerance is the largest difference the numeric checker accepts
+	Tolerance float64 `yaml:"tolerance" json:"tolerance,omitempty"`
+	Cases     []Case  `yaml:"cases" json:"cases"`
+}
+
+// Case is one input and the output expected for it
+type Case struct {
+	Name string `yaml:"name" json:"name"`
+	// Input is a map of template variables, or a single value available as {{.input}}
+	Input any `yaml:"input" json:"input"`
+	// Expected is text, a pattern or a number depending on the checker, or
+	// any value for the JSON checker
+	Expected any `yaml:"expected" json:"expected"`
+	// Checker overrides the checker of the dataset
+	Checker string `yaml:"checker" json:"checker,omitempty"`
+}
+
+// Load reads a dataset from YAML, or from JSONL with one case per line.
+// Datasets without a name are named after the file.
+func Load(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	dataset := &Dataset{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(dataset); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".jsonl":
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var c Case
+			decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&c); err != nil {
+				return nil, fmt.Errorf("failed to parse %s line %d: %w", path, line, err)
+			}
+			dataset.Cases = append(dataset.Cases, c)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dataset format '%s', use .yaml or .jsonl", filepath.Ext(path))
+	}
+
+	if dataset.Name == "" {
+		dataset.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i := range dataset.Cases {
+		if dataset.Cases[i].Name == "" {
+			dataset.Cases[i].Name = fmt.Sprintf("case-%d", i+1)
+		}
+	}
+	return dataset, nil
+}
+
+// Validate checks that the dataset can be run
+func (d *Dataset) Validate() error {
+	if strings.TrimSpace(d.Prompt) == "" {
+		return fmt.Errorf("dataset %s has no prompt", d.Name)
+	}
+	if len(d.Cases) == 0 {
+		return fmt.Errorf("dataset %s has no cases", d.Name)
+	}
+	if _, err := d.render(d.Prompt, d.Cases[0]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Messages renders the system message and the prompt for a case
+func (d *Dataset) Messages(c Case) (system, prompt string, err error) {
+	if system, err = d.render(d.System, c); err != nil {
+		return "", "", err
+	}
+	if prompt, err = d.render(d.Prompt, c); err != nil {
+		return "", "", err
+	}
+	return system, prompt, nil
+}
+
+func (d *Dataset) render(text string, c Case) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(d.Name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	vars, ok := c.Input.(map[string]any)
+	if !ok {
+		vars = map[string]any{"input": c.Input}
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", fmt.Errorf("failed to render case %s: %w", c.Name, err)
+	}
+	return out.String(), nil
+}
+
+// ExpectedText returns the expected value as text; values other than
+// strings and numbers are encoded as JSON
+func (c Case) ExpectedText() string {
+	switch expected := c.Expected.(type) {
+	case nil:
+		return ""
+	case string:
+		return expected
+	case int, int64, float64, bool:
+		return fmt.Sprint(expected)
+	}
+	data, err := json.Marshal(c.Expected)
+	if err != nil {
+		return fmt.Sprint(c.Expected)
+	}
+	return string(data)
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/testkit"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCheckers(t *testing.T) {
+	tests := []struct {
+		name     string
+		checker  Checker
+		expected any
+		output   string
+		pass     bool
+	}{
+		{"exact ignores whitespace", ExactChecker{}, "CENZURA", " CENZURA\n", true},
+		{"exact is case sensitive", ExactChecker{}, "CENZURA", "cenzura", false},
+		{"regex matches anywhere", RegexChecker{}, `\b1453\b`, "It was 1453.", true},
+		{"regex mismatch", RegexChecker{}, `^\d+$`, "1453 AD", false},
+		{"numeric within tolerance", NumericChecker{Tolerance: 0.5}, 3.14, "3,4", true},
+		{"numeric outside tolerance", NumericChecker{Tolerance: 0.1}, 3.14, "3.4", false},
+		{"numeric last number of a sentence", NumericChecker{}, 1453, "After 53 days, in 1453", true},
+		{"numeric without a number", NumericChecker{}, 1453, "I do not know", false},
+		{"json ignores key order and fences", JSONChecker{}, map[string]any{"a": 1, "b": []any{"x"}}, "```json\n{\"b\": [\"x\"], \"a\": 1}\n```", true},
+		{"json different value", JSONChecker{}, map[string]any{"a": 1}, `{"a": 2}`, false},
+		{"json not json", JSONChecker{}, map[string]any{"a": 1}, "a is 1", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verdict, err := test.checker.Check(context.Background(), Case{Name: "case", Expected: test.expected}, test.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict.Pass != test.pass {
+				t.Fatalf("expected pass=%v, got %+v", test.pass, verdict)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "years.yaml")
+	os.WriteFile(yamlPath, []byte(`prompt: "Year of {{.event}} in {{.place}}?"
+checker: numeric
+cases:
+  - input: {event: the fall, place: Constantinople}
+    expected: 1453
+`), 0644)
+	jsonlPath := filepath.Join(dir, "cenzura.jsonl")
+	os.WriteFile(jsonlPath, []byte(`{"input": "Jan Kowalski", "expected": "CENZURA"}
+
+{"name": "age", "input": "lat 27", "expected": "lat CENZURA", "checker": "exact"}
+`), 0644)
+
+	dataset, err := Load(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataset.Name != "years" || len(dataset.Cases) != 1 || dataset.Cases[0].Name != "case-1" {
+		t.Fatalf("unexpected dataset %+v", dataset)
+	}
+	if _, prompt, err := dataset.Messages(dataset.Cases[0]); err != nil || prompt != "Year of the fall in Constantinople?" {
+		t.Fatalf("unexpected prompt %q: %v", prompt, err)
+	}
+
+	dataset, err = Load(jsonlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dataset.Cases) != 2 || dataset.Cases[1].Name != "age" || dataset.Cases[1].Checker != CheckExact {
+		t.Fatalf("unexpected cases %+v", dataset.Cases)
+	}
+	dataset.Prompt = "{{.missing}}"
+	if err := dataset.Validate(); err == nil {
+		t.Fatal("expected a prompt with an unknown variable to be invalid")
+	}
+
+	os.WriteFile(yamlPath, []byte("prompt: x\nchecks: exact\n"), 0644)
+	if _, err := Load(yamlPath); err == nil {
+		t.Fatal("expected an unknown field to be rejected")
+	}
+}
+
+func TestRunner(t *testing.T) {
+	testkit.SetKeys(t)
+	fakeOpenAI := testkit.NewOpenAI(t)
+	fakeOpenAI.OnChat(func(req openai.ChatCompletionRequest) string {
+		question := testkit.LastUserMessage(req)
+		switch {
+		case strings.HasPrefix(req.Messages[0].Content, "You grade"):
+			return `{"pass": true, "reason": "same meaning"}`
+		case strings.Contains(question, "Konstantynopola"):
+			return "1453"
+		}
+		return "1000"
+	})
+	gemini := testkit.NewGemini(t)
+	gemini.OnGenerate(func(req testkit.GeminiRequest) string {
+		return "The answer is 1453."
+	})
+
+	dataset := &Dataset{
+		Name:    "captcha",
+		System:  "Answer with a year.",
+		Prompt:  "{{.input}}",
+		Checker: CheckNumeric,
+		Cases: []Case{
+			{Name: "constantinople", Input: "Rok zdobycia Konstantynopola?", Expected: 1453},
+			{Name: "moon", Input: "Rok lądowania na Księżycu?", Expected: 1969},
+			{Name: "judged", Input: "Rok zdobycia Konstantynopola?", Expected: "1453", Checker: CheckJudge},
+		},
+	}
+	runner := &Runner{
+		Complete:   NewCompleter(testkit.OpenAIKey, testkit.GeminiKey),
+		Models:     []string{"gpt-4o-mini", "gemini-2.0-flash-exp"},
+		JudgeModel: "gpt-4o",
+	}
+	report, err := runner.Run(context.Background(), dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Results) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(report.Results))
+	}
+	passed := map[string]int{}
+	for _, summary := range report.Models {
+		passed[summary.Model] = summary.Passed
+		if summary.Total != 3 || summary.Errors != 0 {
+			t.Fatalf("unexpected summary %+v", summary)
+		}
+	}
+	if passed["gpt-4o-mini"] != 2 || passed["gemini-2.0-flash-exp"] != 2 {
+		t.Fatalf("expected two passes per model, got %v", passed)
+	}
+	if requests := gemini.Requests(); len(requests) != 3 || requests[0].System != "Answer with a year." {
+		t.Fatalf("unexpected Gemini requests %+v", requests)
+	}
+
+	var table strings.Builder
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(table.String(), "moon") || !strings.Contains(table.String(), "2/3") {
+		t.Fatalf("unexpected table:\n%s", table.String())
+	}
+	path := filepath.Join(t.TempDir(), "eval", "report.json")
+	if err := report.Save(path); err != nil {
+		t.Fatal(err)
+	}
+}
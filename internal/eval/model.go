@@ -0,0 +1,107 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/dawidjelenkowski/aidevs3go/internal/text"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+// Request is one prompt sent to a model
+type Request struct {
+	Model       string
+	System      string
+	Prompt      string
+	Temperature float32
+}
+
+// Completion is a model reply with its token usage
+type Completion struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Completer sends a request to the model it names
+type Completer func(ctx context.Context, req Request) (*Completion, error)
+
+// NewCompleter sends Gemini models to Gemini and every other model to
+// OpenAI. An empty key makes the calls to that provider fail.
+func NewCompleter(openaiKey, geminiKey string) Completer {
+	var client *openai.Client
+	if openaiKey != "" {
+		client = openai.NewClientWithConfig(ledger.OpenAIConfig(openaiKey))
+	}
+	return func(ctx context.Context, req Request) (*Completion, error) {
+		if text.Family(req.Model) == text.FamilyGemini {
+			if geminiKey == "" {
+				return nil, fmt.Errorf("gemini-api-key is needed for %s", req.Model)
+			}
+			return completeGemini(ctx, geminiKey, req)
+		}
+		if client == nil {
+			return nil, fmt.Errorf("openai-api-key is needed for %s", req.Model)
+		}
+		return completeOpenAI(ctx, client, req)
+	}
+}
+
+func completeOpenAI(ctx context.Context, client *openai.Client, req Request) (*Completion, error) {
+	var messages []openai.ChatCompletionMessage
+	if req.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: req.System})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: req.Prompt})
+
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API error: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("OpenAI returned no choices")
+	}
+	return &Completion{
+		Text:             resp.Choices[0].Message.Content,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
+
+func completeGemini(ctx context.Context, apiKey string, req Request) (*Completion, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGoogleAI,
+		HTTPClient: httpclient.SDK,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	temperature := float64(req.Temperature)
+	config := &genai.GenerateContentConfig{Temperature: &temperature}
+	if req.System != "" {
+		config.SystemInstruction = &genai.Content{Parts: []*genai.Part{{Text: req.System}}}
+	}
+	result, err := client.Models.GenerateContent(ctx, req.Model, genai.Text(req.Prompt), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+		return nil, fmt.Errorf("Gemini returned no candidates")
+	}
+
+	completion := &Completion{Text: result.Candidates[0].Content.Parts[0].Text}
+	if usage := result.UsageMetadata; usage != nil {
+		completion.PromptTokens = int(usage.PromptTokenCount)
+		completion.CompletionTokens = int(usage.CandidatesTokenCount)
+	}
+	return completion, nil
+}
@@ -0,0 +1,264 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultConcurrency is the number of cases evaluated at once
+const defaultConcurrency = 4
+
+// Runner evaluates datasets on a set of models
+type Runner struct {
+	Complete    Completer
+	Models      []string
+	Temperature float32
+	Concurrency int
+	// JudgeModel grades outputs of cases using the judge checker
+	JudgeModel string
+}
+
+// Result is the output of one model for one case
+type Result struct {
+	Case             string        `json:"case"`
+	Model            string        `json:"model"`
+	Checker          string        `json:"checker"`
+	Output           string        `json:"output"`
+	Pass             bool          `json:"pass"`
+	Reason           string        `json:"reason,omitempty"`
+	Error            string        `json:"error,omitempty"`
+	Duration         time.Duration `json:"duration"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+}
+
+// Summary is the score of one model on the whole dataset
+type Summary struct {
+	Model          string        `json:"model"`
+	Passed         int           `json:"passed"`
+	Total          int           `json:"total"`
+	Errors         int           `json:"errors"`
+	PassRate       float64       `json:"pass_rate"`
+	Cost           float64       `json:"cost"`
+	AverageLatency time.Duration `json:"average_latency"`
+}
+
+// Report is the outcome of evaluating a dataset
+type Report struct {
+	Dataset  string        `json:"dataset"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Models   []Summary     `json:"models"`
+	Results  []Result      `json:"results"`
+}
+
+// Run sends every case of the dataset to every model and scores the
+// replies. Failed calls are recorded in their results; only an invalid
+// dataset or a cancelled context stop the run.
+func (r *Runner) Run(ctx context.Context, d *Dataset) (*Report, error) {
+	if len(r.Models) == 0 {
+		return nil, fmt.Errorf("no models to evaluate")
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	checkers, err := r.checkers(d)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	report := &Report{Dataset: d.Name, Started: time.Now()}
+	report.Results = make([]Result, len(d.Cases)*len(r.Models))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range d.Cases {
+		for j, model := range r.Models {
+			result := &report.Results[i*len(r.Models)+j]
+			*result = Result{Case: c.Name, Model: model, Checker: checkerName(d, c)}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				r.evaluate(ctx, d, c, checkers[result.Checker], result)
+			}()
+		}
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Duration = time.Since(report.Started)
+	report.Models = summarize(r.Models, report.Results)
+	return report, nil
+}
+
+// checkers builds each checker the dataset uses once
+func (r *Runner) checkers(d *Dataset) (map[string]Checker, error) {
+	opts := CheckerOptions{Tolerance: d.Tolerance, Judge: r.Complete, JudgeModel: r.JudgeModel}
+	checkers := make(map[string]Checker)
+	for _, c := range d.Cases {
+		name := checkerName(d, c)
+		if _, ok := checkers[name]; ok {
+			continue
+		}
+		checker, err := NewChecker(name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+		checkers[name] = checker
+	}
+	return checkers, nil
+}
+
+// checkerName returns the checker of a case, falling back to the dataset's
+// and then to exact matching
+func checkerName(d *Dataset, c Case) string {
+	switch {
+	case c.Checker != "":
+		return c.Checker
+	case d.Checker != "":
+		return d.Checker
+	}
+	return CheckExact
+}
+
+func (r *Runner) evaluate(ctx context.Context, d *Dataset, c Case, checker Checker, result *Result) {
+	if ctx.Err() != nil {
+		return
+	}
+	system, prompt, err := d.Messages(c)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+
+	start := time.Now()
+	completion, err := r.Complete(ctx, Request{Model: result.Model, System: system, Prompt: prompt, Temperature: r.Temperature})
+	result.Duration = time.Since(start)
+	if err != nil {
+		log.Warn().Err(err).Str("case", c.Name).Str("model", result.Model).Msg("Completion failed")
+		result.Error = err.Error()
+		return
+	}
+	result.Output = completion.Text
+	result.PromptTokens = completion.PromptTokens
+	result.CompletionTokens = completion.CompletionTokens
+
+	verdict, err := checker.Check(ctx, c, completion.Text)
+	if err != nil {
+		log.Warn().Err(err).Str("case", c.Name).Str("model", result.Model).Msg("Check failed")
+		result.Error = err.Error()
+		return
+	}
+	result.Pass = verdict.Pass
+	result.Reason = verdict.Reason
+	log.Debug().Str("case", c.Name).Str("model", result.Model).Bool("pass", verdict.Pass).Msg("Case evaluated")
+}
+
+func summarize(models []string, results []Result) []Summary {
+	summaries := make([]Summary, len(models))
+	index := make(map[string]int, len(models))
+	for i, model := range models {
+		summaries[i].Model = model
+		index[model] = i
+	}
+	latency := make([]time.Duration, len(models))
+	for _, result := range results {
+		i := index[result.Model]
+		summary := &summaries[i]
+		summary.Total++
+		if result.Pass {
+			summary.Passed++
+		}
+		if result.Error != "" {
+			summary.Errors++
+		}
+		summary.Cost += ledger.Cost(result.Model, result.PromptTokens, result.CompletionTokens)
+		latency[i] += result.Duration
+	}
+	for i := range summaries {
+		if total := summaries[i].Total; total > 0 {
+			summaries[i].PassRate = float64(summaries[i].Passed) / float64(total)
+			summaries[i].AverageLatency = latency[i] / time.Duration(total)
+		}
+	}
+	return summaries
+}
+
+// WriteTable prints the pass or fail of each case on each model followed by
+// the score of each model
+func (r *Report) WriteTable(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "CASE")
+	for _, summary := range r.Models {
+		fmt.Fprintf(w, "\t%s", summary.Model)
+	}
+	fmt.Fprintln(w)
+	for i := 0; i < len(r.Results); i += len(r.Models) {
+		fmt.Fprint(w, r.Results[i].Case)
+		for _, result := range r.Results[i : i+len(r.Models)] {
+			fmt.Fprintf(w, "\t%s", result.status())
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tPASSED\tRATE\tERRORS\tAVG LATENCY\tCOST")
+	for _, summary := range r.Models {
+		fmt.Fprintf(w, "%s\t%d/%d\t%.0f%%\t%d\t%s\t$%.4f\n",
+			summary.Model,
+			summary.Passed,
+			summary.Total,
+			summary.PassRate*100,
+			summary.Errors,
+			summary.AverageLatency.Round(time.Millisecond),
+			summary.Cost,
+		)
+	}
+	return w.Flush()
+}
+
+func (r Result) status() string {
+	switch {
+	case r.Error != "":
+		return "ERROR"
+	case r.Pass:
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// Save writes the report as indented JSON, creating its directory
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling report: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
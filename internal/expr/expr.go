@@ -0,0 +1,329 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSyntax         = errors.New("syntax error")
+	ErrDivisionByZero = errors.New("division by zero")
+	ErrOverflow       = errors.New("integer overflow")
+)
+
+// Number is the result of an expression: an integer while every operation
+// stays exact, a float otherwise
+type Number struct {
+	isFloat bool
+	i       int64
+	f       float64
+}
+
+// Int creates an integer number
+func Int(i int64) Number {
+	return Number{i: i}
+}
+
+// Float creates a floating point number
+func Float(f float64) Number {
+	return Number{isFloat: true, f: f}
+}
+
+// IsInt reports whether the number is an integer value
+func (n Number) IsInt() bool {
+	return !n.isFloat
+}
+
+// Int64 returns the number truncated to an integer
+func (n Number) Int64() int64 {
+	if n.isFloat {
+		return int64(n.f)
+	}
+	return n.i
+}
+
+// Float64 returns the number as a float
+func (n Number) Float64() float64 {
+	if n.isFloat {
+		return n.f
+	}
+	return float64(n.i)
+}
+
+// Equal compares by value, so 2 equals 2.0
+func (n Number) Equal(other Number) bool {
+	if n.IsInt() && other.IsInt() {
+		return n.i == other.i
+	}
+	return n.Float64() == other.Float64()
+}
+
+func (n Number) String() string {
+	if n.isFloat {
+		return strconv.FormatFloat(n.f, 'f', -1, 64)
+	}
+	return strconv.FormatInt(n.i, 10)
+}
+
+// MarshalJSON writes integers without a decimal point
+func (n Number) MarshalJSON() ([]byte, error) {
+	if n.isFloat && (math.IsInf(n.f, 0) || math.IsNaN(n.f)) {
+		return nil, fmt.Errorf("cannot encode %v as JSON", n.f)
+	}
+	return []byte(n.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, keeping integers exact
+func (n *Number) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		*n = Int(i)
+		return nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", text)
+	}
+	*n = Float(f)
+	return nil
+}
+
+// Eval parses and evaluates an arithmetic expression with + - * /,
+// parentheses and unary minus. Integer division that is not exact gives a float.
+func Eval(expression string) (Number, error) {
+	p := &parser{input: expression}
+	p.next()
+	result, err := p.expression()
+	if err != nil {
+		return Number{}, err
+	}
+	if p.token.kind != tokenEnd {
+		return Number{}, p.errorf("unexpected %q", p.token.text)
+	}
+	return result, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// parser is a recursive descent parser that evaluates while parsing:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/") unary }
+//	unary      = ("-" | "+") unary | primary
+//	primary    = number | "(" expression ")"
+type parser struct {
+	input string
+	pos   int
+	token token
+	depth int
+}
+
+// maxDepth bounds nesting so hostile input cannot exhaust the stack
+const maxDepth = 1000
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at position %d in %q: %s", ErrSyntax, p.token.pos, p.input, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) next() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.token = token{kind: tokenEnd, pos: start}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		p.token = token{kind: tokenNumber, text: p.input[start:p.pos], pos: start}
+	case c == '+' || c == '-' || c == '*' || c == '/':
+		p.pos++
+		p.token = token{kind: tokenOperator, text: string(c), pos: start}
+	case c == '(':
+		p.pos++
+		p.token = token{kind: tokenOpen, text: "(", pos: start}
+	case c == ')':
+		p.pos++
+		p.token = token{kind: tokenClose, text: ")", pos: start}
+	default:
+		p.pos++
+		p.token = token{kind: tokenOperator, text: string(c), pos: start}
+	}
+}
+
+func (p *parser) expression() (Number, error) {
+	left, err := p.term()
+	if err != nil {
+		return Number{}, err
+	}
+	for p.token.kind == tokenOperator && (p.token.text == "+" || p.token.text == "-") {
+		op := p.token.text
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return Number{}, err
+		}
+		if left, err = apply(op, left, right); err != nil {
+			return Number{}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Number, error) {
+	left, err := p.unary()
+	if err != nil {
+		return Number{}, err
+	}
+	for p.token.kind == tokenOperator && (p.token.text == "*" || p.token.text == "/") {
+		op := p.token.text
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return Number{}, err
+		}
+		if left, err = apply(op, left, right); err != nil {
+			return Number{}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Number, error) {
+	if p.token.kind == tokenOperator && (p.token.text == "-" || p.token.text == "+") {
+		op := p.token.text
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return Number{}, p.errorf("expression nested too deeply")
+		}
+		p.next()
+		value, err := p.unary()
+		if err != nil || op == "+" {
+			return value, err
+		}
+		return apply("-", Int(0), value)
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Number, error) {
+	switch p.token.kind {
+	case tokenNumber:
+		value, err := parseNumber(p.token.text)
+		if err != nil {
+			return Number{}, p.errorf("%v", err)
+		}
+		p.next()
+		return value, nil
+	case tokenOpen:
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return Number{}, p.errorf("expression nested too deeply")
+		}
+		p.next()
+		value, err := p.expression()
+		if err != nil {
+			return Number{}, err
+		}
+		if p.token.kind != tokenClose {
+			return Number{}, p.errorf("missing closing parenthesis")
+		}
+		p.next()
+		return value, nil
+	case tokenEnd:
+		return Number{}, p.errorf("unexpected end of expression")
+	default:
+		return Number{}, p.errorf("unexpected %q", p.token.text)
+	}
+}
+
+func parseNumber(text string) (Number, error) {
+	if !strings.Contains(text, ".") {
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return Number{}, fmt.Errorf("invalid number %q", text)
+		}
+		return Int(i), nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || strings.Count(text, ".") > 1 {
+		return Number{}, fmt.Errorf("invalid number %q", text)
+	}
+	return Float(f), nil
+}
+
+// apply evaluates one binary operation, staying in integers while exact
+func apply(op string, a, b Number) (Number, error) {
+	if a.IsInt() && b.IsInt() {
+		x, y := a.i, b.i
+		switch op {
+		case "+":
+			if (y > 0 && x > math.MaxInt64-y) || (y < 0 && x < math.MinInt64-y) {
+				return Number{}, fmt.Errorf("%w: %d + %d", ErrOverflow, x, y)
+			}
+			return Int(x + y), nil
+		case "-":
+			if (y < 0 && x > math.MaxInt64+y) || (y > 0 && x < math.MinInt64+y) {
+				return Number{}, fmt.Errorf("%w: %d - %d", ErrOverflow, x, y)
+			}
+			return Int(x - y), nil
+		case "*":
+			if x != 0 && y != 0 {
+				product := x * y
+				if product/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+					return Number{}, fmt.Errorf("%w: %d * %d", ErrOverflow, x, y)
+				}
+				return Int(product), nil
+			}
+			return Int(0), nil
+		case "/":
+			if y == 0 {
+				return Number{}, ErrDivisionByZero
+			}
+			if x%y == 0 && !(x == math.MinInt64 && y == -1) {
+				return Int(x / y), nil
+			}
+		}
+	}
+
+	x, y := a.Float64(), b.Float64()
+	switch op {
+	case "+":
+		return Float(x + y), nil
+	case "-":
+		return Float(x - y), nil
+	case "*":
+		return Float(x * y), nil
+	case "/":
+		if y == 0 {
+			return Number{}, ErrDivisionByZero
+		}
+		return Float(x / y), nil
+	}
+	return Number{}, fmt.Errorf("%w: unknown operator %q", ErrSyntax, op)
+}
@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   Number
+	}{
+		{"42", Int(42)},
+		{"2+3*4", Int(14)},
+		{"2*3+4", Int(10)},
+		{"2*3-4/2", Int(4)},
+		{"10-4-3", Int(3)},
+		{"100/10/5", Int(2)},
+		{"(2+3)*4", Int(20)},
+		{"2*(3+4)*5", Int(70)},
+		{"((1))", Int(1)},
+		{" 1 +\t2 ", Int(3)},
+		{"-3", Int(-3)},
+		{"--3", Int(3)},
+		{"+3", Int(3)},
+		{"2*-3", Int(-6)},
+		{"-2*-2", Int(4)},
+		{"-(2+3)", Int(-5)},
+		{"-2+5", Int(3)},
+		{"5--2", Int(7)},
+		{"6/3", Int(2)},
+		{"7/2", Float(3.5)},
+		{"1/3*3", Float(1)},
+		{"1.5+1.5", Float(3)},
+		{".5*4", Float(2)},
+		{"9223372036854775807", Int(9223372036854775807)},
+	}
+	for _, test := range tests {
+		result, err := Eval(test.expression)
+		if err != nil {
+			t.Errorf("Eval(%q) failed: %v", test.expression, err)
+			continue
+		}
+		if !result.Equal(test.expected) || result.IsInt() != test.expected.IsInt() {
+			t.Errorf("Eval(%q) = %v (int %v), expected %v (int %v)", test.expression, result, result.IsInt(), test.expected, test.expected.IsInt())
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        error
+	}{
+		{"1/0", ErrDivisionByZero},
+		{"1/(2-2)", ErrDivisionByZero},
+		{"1.5/0", ErrDivisionByZero},
+		{"0/0", ErrDivisionByZero},
+		{"9223372036854775807+1", ErrOverflow},
+		{"-9223372036854775807-2", ErrOverflow},
+		{"4611686018427387904*2", ErrOverflow},
+		{"", ErrSyntax},
+		{"1+", ErrSyntax},
+		{"*2", ErrSyntax},
+		{"(1+2", ErrSyntax},
+		{"1+2)", ErrSyntax},
+		{"()", ErrSyntax},
+		{"1 2", ErrSyntax},
+		{"1..2", ErrSyntax},
+		{"1.2.3", ErrSyntax},
+		{"2**3", ErrSyntax},
+		{"a+1", ErrSyntax},
+		{"2^3", ErrSyntax},
+		{"99999999999999999999", ErrSyntax},
+		{strings.Repeat("(", maxDepth+1) + "1" + strings.Repeat(")", maxDepth+1), ErrSyntax},
+		{strings.Repeat("-", maxDepth+1) + "1", ErrSyntax},
+	}
+	for _, test := range tests {
+		if result, err := Eval(test.expression); !errors.Is(err, test.err) {
+			name := test.expression
+			if len(name) > 30 {
+				name = name[:30] + "..."
+			}
+			t.Errorf("Eval(%q) = %v, %v, expected %v", name, result, err, test.err)
+		}
+	}
+}
+
+func TestNumberJSON(t *testing.T) {
+	for _, n := range []Number{Int(7), Int(-12), Float(3.5), Float(2)} {
+		data, err := json.Marshal(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Number
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(n) {
+			t.Errorf("expected %v after a round trip through %s, got %v", n, data, decoded)
+		}
+	}
+	if data, _ := json.Marshal(Int(5)); string(data) != "5" {
+		t.Errorf("expected 5, got %s", data)
+	}
+	if _, err := json.Marshal(Float(math.Inf(1))); err == nil {
+		t.Error("expected an error for infinity")
+	}
+}
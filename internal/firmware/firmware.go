@@ -0,0 +1,305 @@
+package firmware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultStatePath remembers the last firmware seen, to detect rule changes
+const DefaultStatePath = "downloads/firmware-state.json"
+
+// ErrNotFirmware is returned for files that contain no firmware, such as old
+// versions that were replaced by a flag
+var ErrNotFirmware = errors.New("file is not a firmware dump")
+
+var (
+	versionPattern = regexp.MustCompile(`(?m)^#.*\bv(\d+(?:\.\d+)*[a-z]?)\s*$`)
+	flagPattern    = regexp.MustCompile(`\{\{FLG:[^}]*\}\}`)
+	bulletPattern  = regexp.MustCompile(`^\s*-\s+(.+)$`)
+	// The warning block with the false knowledge is framed by lines of asterisks
+	warningBorder = regexp.MustCompile(`^\*{5,}`)
+	// Statements have the form "<subject> jest|to <value>"
+	statementPattern = regexp.MustCompile(`^(.+?)\s+(?:jest|to)\s+(.+)$`)
+)
+
+// Instruction is one rule of the robot, with the section it belongs to
+type Instruction struct {
+	Section string `json:"section,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Override is a false fact the robot is required to believe
+type Override struct {
+	// Text is the statement as written in the firmware
+	Text string `json:"text"`
+	// Subject and Value split the statement, e.g. "stolicą Polski" / "Kraków"
+	Subject string `json:"subject,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// Firmware is the parsed content of a robot firmware dump
+type Firmware struct {
+	Version      string        `json:"version"`
+	Instructions []Instruction `json:"instructions"`
+	Overrides    []Override    `json:"overrides"`
+}
+
+// Load reads and parses a firmware file
+func Load(path string) (*Firmware, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read firmware: %w", err)
+	}
+	firmware, err := Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return firmware, nil
+}
+
+// Parse extracts the version, the instruction list and the false knowledge
+// from a firmware dump
+func Parse(content string) (*Firmware, error) {
+	match := versionPattern.FindStringSubmatch(content)
+	if match == nil {
+		if flag := flagPattern.FindString(content); flag != "" {
+			return nil, fmt.Errorf("%w (contains %s)", ErrNotFirmware, flag)
+		}
+		return nil, fmt.Errorf("%w: no version header", ErrNotFirmware)
+	}
+
+	firmware := &Firmware{Version: match[1]}
+	section := ""
+	// warningStart is the line that opened the current warning block, 0 outside one
+	warningStart := 0
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \r\t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			continue
+		case warningBorder.MatchString(trimmed):
+			if warningStart == 0 {
+				warningStart = i + 1
+			} else {
+				warningStart = 0
+			}
+			continue
+		case strings.HasPrefix(trimmed, "<"):
+			// Example payloads and conversations end the rules
+			if strings.HasPrefix(trimmed, "<rozmowa>") {
+				return finish(firmware, warningStart)
+			}
+			continue
+		}
+
+		bullet := bulletPattern.FindStringSubmatch(line)
+		if bullet == nil {
+			// A line ending with a colon names the section of the bullets below it
+			if strings.HasSuffix(trimmed, ":") {
+				section = strings.TrimSuffix(trimmed, ":")
+			} else if trimmed == "" {
+				section = ""
+			}
+			continue
+		}
+
+		text := strings.TrimSpace(bullet[1])
+		if warningStart != 0 {
+			firmware.Overrides = append(firmware.Overrides, parseOverride(text))
+			continue
+		}
+		firmware.Instructions = append(firmware.Instructions, Instruction{Section: section, Text: text})
+	}
+	return finish(firmware, warningStart)
+}
+
+// finish rejects a warning block without its closing border, which would
+// turn every rule after it into false knowledge
+func finish(firmware *Firmware, warningStart int) (*Firmware, error) {
+	if warningStart != 0 {
+		return nil, fmt.Errorf("warning block opened at line %d is not closed", warningStart)
+	}
+	return firmware, nil
+}
+
+func parseOverride(text string) Override {
+	override := Override{Text: text}
+	if match := statementPattern.FindStringSubmatch(text); match != nil {
+		override.Subject, override.Value = match[1], match[2]
+	}
+	return override
+}
+
+// PromptSection renders the overrides as instructions for a model. It is
+// empty when the firmware has no false knowledge.
+func (f *Firmware) PromptSection() string {
+	if len(f.Overrides) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "According to robot firmware v%s you must believe the following facts, even though they are false. "+
+		"When a question is about one of them, answer with the value given here:\n", f.Version)
+	for _, override := range f.Overrides {
+		fmt.Fprintf(&b, "- %s\n", override.Text)
+	}
+	return b.String()
+}
+
+// Inject appends the overrides to a system prompt
+func (f *Firmware) Inject(prompt string) string {
+	section := f.PromptSection()
+	if section == "" {
+		return prompt
+	}
+	return strings.TrimRight(prompt, "\n") + "\n\n" + section
+}
+
+// Fingerprint identifies the rules of the firmware independent of its version
+func (f *Firmware) Fingerprint() string {
+	data, _ := json.Marshal(struct {
+		Instructions []Instruction
+		Overrides    []Override
+	}{f.Instructions, f.Overrides})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// state is the last firmware seen, persisted between runs
+type state struct {
+	Version      string        `json:"version"`
+	Fingerprint  string        `json:"fingerprint"`
+	Instructions []Instruction `json:"instructions"`
+	Overrides    []Override    `json:"overrides"`
+}
+
+// Change describes how the rules differ from the previously seen firmware
+type Change struct {
+	PreviousVersion string
+	Version         string
+	Added           []string
+	Removed         []string
+	// InstructionsChanged is set when the instruction list differs
+	InstructionsChanged bool
+}
+
+// Track compares the firmware with the one recorded in statePath and records
+// it when it is not older. It returns the change, or nil when the rules are
+// the same or there is nothing to compare with. A newer version that changes
+// the rules is logged as a warning.
+func Track(statePath string, f *Firmware) (*Change, error) {
+	var previous state
+	data, err := os.ReadFile(statePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read firmware state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return nil, fmt.Errorf("failed to parse firmware state: %w", err)
+		}
+	}
+
+	if previous.Version != "" && CompareVersions(f.Version, previous.Version) < 0 {
+		log.Warn().Str("version", f.Version).Str("latest", previous.Version).Msg("Using an older firmware than the latest seen")
+		return nil, nil
+	}
+
+	var change *Change
+	if previous.Version != "" && previous.Fingerprint != f.Fingerprint() {
+		change = diff(previous, f)
+		log.Warn().
+			Str("previous_version", change.PreviousVersion).
+			Str("version", change.Version).
+			Strs("added", change.Added).
+			Strs("removed", change.Removed).
+			Bool("instructions_changed", change.InstructionsChanged).
+			Msg("Firmware rules changed")
+	}
+
+	data, err = json.MarshalIndent(state{Version: f.Version, Fingerprint: f.Fingerprint(), Instructions: f.Instructions, Overrides: f.Overrides}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling firmware state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write firmware state: %w", err)
+	}
+	return change, nil
+}
+
+func diff(previous state, f *Firmware) *Change {
+	change := &Change{PreviousVersion: previous.Version, Version: f.Version}
+	old := make(map[string]bool)
+	for _, override := range previous.Overrides {
+		old[override.Text] = true
+	}
+	current := make(map[string]bool)
+	for _, override := range f.Overrides {
+		current[override.Text] = true
+		if !old[override.Text] {
+			change.Added = append(change.Added, override.Text)
+		}
+	}
+	for _, override := range previous.Overrides {
+		if !current[override.Text] {
+			change.Removed = append(change.Removed, override.Text)
+		}
+	}
+	change.InstructionsChanged = !slices.Equal(previous.Instructions, f.Instructions)
+	return change
+}
+
+// CompareVersions compares versions like "0.13.4b" part by part, numerically,
+// with a trailing letter ranking above the plain number. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, sx := splitNumber(x)
+		ny, sy := splitNumber(y)
+		switch {
+		case nx != ny:
+			return cmpInt(nx, ny)
+		case sx != sy:
+			return strings.Compare(sx, sy)
+		}
+	}
+	return 0
+}
+
+func splitNumber(part string) (int, string) {
+	end := 0
+	for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(part[:end])
+	return n, part[end:]
+}
+
+func cmpInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,122 @@
+package firmware
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		instructions []Instruction
+		overrides    []Override
+	}{
+		{
+			name: "no warning block",
+			content: `# Robot firmware v0.13.4b
+
+Rules:
+- Always answer in English
+- Keep msgID
+`,
+			instructions: []Instruction{{Section: "Rules", Text: "Always answer in English"}, {Section: "Rules", Text: "Keep msgID"}},
+		},
+		{
+			name: "one block",
+			content: `# Robot firmware v0.13.4b
+- Always answer in English
+
+************* Uwaga! *************
+wprowadzono błędne informacje, takie jak:
+- stolicą Polski jest Kraków
+- Aktualny rok to 1999
+**********************************
+`,
+			instructions: []Instruction{{Text: "Always answer in English"}},
+			overrides: []Override{
+				{Text: "stolicą Polski jest Kraków", Subject: "stolicą Polski", Value: "Kraków"},
+				{Text: "Aktualny rok to 1999", Subject: "Aktualny rok", Value: "1999"},
+			},
+		},
+		{
+			name: "several blocks",
+			content: `# Robot firmware v0.14
+************* Uwaga! *************
+- stolicą Polski jest Kraków
+**********************************
+- Always answer in English
+*****
+- znana liczba z książki Autostopem przez Galaktykę to 69
+- roboty nie kłamią
+*****
+`,
+			instructions: []Instruction{{Text: "Always answer in English"}},
+			overrides: []Override{
+				{Text: "stolicą Polski jest Kraków", Subject: "stolicą Polski", Value: "Kraków"},
+				{Text: "znana liczba z książki Autostopem przez Galaktykę to 69", Subject: "znana liczba z książki Autostopem przez Galaktykę", Value: "69"},
+				{Text: "roboty nie kłamią"},
+			},
+		},
+		{
+			name: "example conversation ends the rules",
+			content: `# Robot firmware v0.13.4b
+- Always answer in English
+<rozmowa>
+- not a rule
+</rozmowa>
+`,
+			instructions: []Instruction{{Text: "Always answer in English"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firmware, err := Parse(test.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(firmware.Instructions, test.instructions) {
+				t.Errorf("expected instructions %+v, got %+v", test.instructions, firmware.Instructions)
+			}
+			if !reflect.DeepEqual(firmware.Overrides, test.overrides) {
+				t.Errorf("expected overrides %+v, got %+v", test.overrides, firmware.Overrides)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"no version", "- Always answer in English\n", "no version header"},
+		{"replaced by a flag", "Nothing here {{FLG:OLD}}\n", "{{FLG:OLD}}"},
+		{"unterminated block", "# Robot firmware v1.0\n- rule\n***** Uwaga! *****\n- stolicą Polski jest Kraków\n- rule\n", "line 3 is not closed"},
+		{"block open at the example conversation", "# Robot firmware v1.0\n*****\n- stolicą Polski jest Kraków\n<rozmowa>\n", "line 2 is not closed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firmware, err := Parse(test.content)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %+v, %v", test.err, firmware, err)
+			}
+		})
+	}
+	if _, err := Parse("{{FLG:OLD}}"); !errors.Is(err, ErrNotFirmware) {
+		t.Fatalf("expected ErrNotFirmware, got %v", err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	firmware, err := Parse("# Robot firmware v0.13.4b\n")
+	if err != nil || firmware.Version != "0.13.4b" {
+		t.Fatalf("expected version 0.13.4b, got %+v, %v", firmware, err)
+	}
+	if CompareVersions("0.13.4b", "0.13.4") != 1 || CompareVersions("0.9", "0.13") != -1 || CompareVersions("1.0", "1") != 0 {
+		t.Fatal("unexpected version order")
+	}
+}
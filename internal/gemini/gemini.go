@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/genai"
 )
@@ -17,14 +18,13 @@ type GeminiConfig struct {
 }
 
 // Read transcription files and asks Gemini a question
-func AskGemini(config *GeminiConfig) (string, error) {
+func AskGemini(ctx context.Context, config *GeminiConfig) (string, error) {
 	log.Info().Msg("Asking Gemini using genai SDK")
 
-	ctx := context.Background()
-
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  config.GeminiAPIKey,
-		Backend: genai.BackendGoogleAI,
+		APIKey:     config.GeminiAPIKey,
+		Backend:    genai.BackendGoogleAI,
+		HTTPClient: httpclient.SDK,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to create Gemini client: %w", err)
@@ -52,5 +52,8 @@ func AskGemini(config *GeminiConfig) (string, error) {
 	// Log the output.
 	fmt.Println(string(response))
 
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("Gemini returned no content")
+	}
 	return result.Candidates[0].Content.Parts[0].Text, nil
 }
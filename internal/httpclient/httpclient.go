@@ -0,0 +1,81 @@
+// Package httpclient holds the HTTP clients shared by every package. They use
+// one connection pool, time out so a hung server cannot stall a run, and
+// retry transient failures with per-provider rate limits.
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/retry"
+)
+
+const (
+	// DefaultTimeout bounds a whole API request, retries and reading the body
+	// included. Long model replies are the slowest requests made.
+	DefaultTimeout = 5 * time.Minute
+	// ResponseHeaderTimeout bounds the wait for a reply once the request is sent
+	ResponseHeaderTimeout = 3 * time.Minute
+)
+
+var pool = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: ResponseHeaderTimeout,
+	ExpectContinueTimeout: time.Second,
+	IdleConnTimeout:       90 * time.Second,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+}
+
+var transport = retry.NewTransport(pool)
+
+// sdkTransport also retries POST requests, but only when they cannot have
+// reached the model, so a call is never billed or acted on twice
+var sdkTransport = retry.Unprocessed(transport)
+
+// Default is for API calls, each bounded by DefaultTimeout
+var Default = New(DefaultTimeout)
+
+// Transfer is for downloads and uploads of files of unknown size. It has no
+// overall timeout; a stalled server is caught by the transport timeouts and
+// the request context stops it.
+var Transfer = New(0)
+
+// SDK is Default for the OpenAI and Gemini clients. Unlike other POST
+// requests, theirs are retried when the connection fails or the API asks to
+// wait with 429 and Retry-After.
+var SDK = &http.Client{Transport: sdkTransport, Timeout: DefaultTimeout}
+
+// SDKTransfer is Transfer for model calls that upload files, such as audio
+var SDKTransfer = &http.Client{Transport: sdkTransport}
+
+// Transport returns the retrying transport all clients share
+func Transport() http.RoundTripper {
+	return transport
+}
+
+// SDKTransport returns the shared transport that also retries unprocessed POST requests
+func SDKTransport() http.RoundTripper {
+	return sdkTransport
+}
+
+// SetBase replaces the connection pool below the retries until restore is
+// called. It is a seam for tests, which send requests for the real hosts to
+// local fakes this way; it must not be called while requests are in flight.
+func SetBase(base http.RoundTripper) (restore func()) {
+	previous := transport.Base
+	transport.Base = base
+	return func() { transport.Base = previous }
+}
+
+// New returns a client on the shared transport with an overall timeout, 0 for none
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
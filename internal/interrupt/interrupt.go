@@ -0,0 +1,36 @@
+// Package interrupt turns Ctrl-C into context cancellation for commands
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Context returns a context canceled on the first SIGINT or SIGTERM, so that
+// in-flight requests stop and the command can finish its run record. After
+// the first signal the default handling is restored and a second one exits
+// immediately. Call stop to release the signal handler.
+func Context(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Warn().Str("signal", sig.String()).Msg("Interrupted, stopping; interrupt again to exit immediately")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
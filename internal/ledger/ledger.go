@@ -0,0 +1,403 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
+	"github.com/dawidjelenkowski/aidevs3go/internal/retry"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultPath is where runs are recorded unless LEDGER_PATH says otherwise
+const DefaultPath = "runs/ledger.jsonl"
+
+// maxAnswerSize keeps huge answers out of the ledger; larger ones are stored as a hash
+const maxAnswerSize = 1 << 20
+
+// ErrNotFound is returned when no recorded run matches an ID
+var ErrNotFound = errors.New("run not found")
+
+// Path returns the ledger file in use
+func Path() string {
+	if path := os.Getenv("LEDGER_PATH"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// Input is a file the run read, identified by its content hash
+type Input struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// Exchange is one model call with its prompt and response
+type Exchange struct {
+	Model            string        `json:"model"`
+	Prompt           string        `json:"prompt"`
+	Response         string        `json:"response"`
+	PromptTokens     int           `json:"prompt_tokens,omitempty"`
+	CompletionTokens int           `json:"completion_tokens,omitempty"`
+	Cost             float64       `json:"cost_usd,omitempty"`
+	Duration         time.Duration `json:"duration_ns"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// Record is one line of the ledger
+type Record struct {
+	RunID    string    `json:"run_id"`
+	Task     string    `json:"task"`
+	Revision string    `json:"revision,omitempty"`
+	Started  time.Time `json:"started"`
+	// Duration of the whole run
+	Duration  time.Duration `json:"duration_ns"`
+	Inputs    []Input       `json:"inputs,omitempty"`
+	Exchanges []Exchange    `json:"exchanges,omitempty"`
+	// ReplayOf is the run whose answer this run resubmitted
+	ReplayOf string `json:"replay_of,omitempty"`
+	// ReportTask is the Centrala task name the answer was submitted under
+	ReportTask string `json:"report_task,omitempty"`
+	// Answer is the submitted answer as sent, with placeholders such as {{apikey}} unfilled
+	Answer json.RawMessage `json:"answer,omitempty"`
+	// AnswerSHA256 identifies answers too large to store
+	AnswerSHA256 string `json:"answer_sha256,omitempty"`
+	// Code and Message are Centrala's reply; Code is nil when nothing was submitted
+	Code    *int    `json:"code,omitempty"`
+	Message string  `json:"message,omitempty"`
+	Cost    float64 `json:"cost_usd,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	// Retries are the calls, retries and rate limiting per provider
+	Retries map[string]retry.Stats `json:"retries,omitempty"`
+}
+
+// Submitted reports whether the run got a reply from Centrala
+func (r *Record) Submitted() bool {
+	return r.Code != nil
+}
+
+// Run collects the record of the current task run. All methods are safe
+// for concurrent use and do nothing on a nil run, so code shared between
+// tasks can record without checking whether a run was started.
+type Run struct {
+	mu       sync.Mutex
+	path     string
+	record   Record
+	finished bool
+}
+
+var (
+	currentMu sync.Mutex
+	current   *Run
+)
+
+// Begin starts recording a run of the task under the logging run ID. The
+// run is written to the ledger by Finish, or when the task exits through
+// log.Fatal.
+func Begin(task string) *Run {
+	run := &Run{
+		path: Path(),
+		record: Record{
+			RunID:    logging.RunID(),
+			Task:     task,
+			Revision: revision(),
+			Started:  time.Now().UTC(),
+		},
+	}
+	if run.record.RunID == "" {
+		run.record.RunID = logging.NewRunID()
+	}
+
+	currentMu.Lock()
+	current = run
+	currentMu.Unlock()
+
+	logging.OnFatal(func(message string) {
+		run.Finish(errors.New(message))
+	})
+	return run
+}
+
+// Current returns the run started by Begin, or nil
+func Current() *Run {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	return current
+}
+
+// ID returns the run ID
+func (r *Run) ID() string {
+	if r == nil {
+		return ""
+	}
+	return r.record.RunID
+}
+
+// AddInput records a file, or every file below a directory, with its hash.
+// Files already recorded are skipped.
+func (r *Run) AddInput(path string) {
+	if r == nil {
+		return
+	}
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		input, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, known := range r.record.Inputs {
+			if known.Path == input.Path {
+				return nil
+			}
+		}
+		r.record.Inputs = append(r.record.Inputs, input)
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Failed to record input")
+	}
+}
+
+// AddExchange records a model call
+func (r *Run) AddExchange(exchange Exchange) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record.Exchanges = append(r.record.Exchanges, exchange)
+	r.record.Cost += exchange.Cost
+}
+
+// SetReplayOf links the run to the run whose answer it resubmits
+func (r *Run) SetReplayOf(runID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record.ReplayOf = runID
+}
+
+// SetAnswer records the answer submitted for a Centrala task, which must be JSON
+func (r *Run) SetAnswer(task string, answer []byte) {
+	recorder := r.AnswerRecorder(task)
+	recorder.Write(answer)
+	recorder.Commit()
+}
+
+// AnswerRecorder captures an answer as it is streamed to Centrala
+type AnswerRecorder struct {
+	run  *Run
+	task string
+	buf  bytes.Buffer
+	hash hash.Hash
+}
+
+// AnswerRecorder returns a writer for the answer; Commit stores what was written
+func (r *Run) AnswerRecorder(task string) *AnswerRecorder {
+	if r == nil {
+		return nil
+	}
+	return &AnswerRecorder{run: r, task: task, hash: sha256.New()}
+}
+
+func (a *AnswerRecorder) Write(p []byte) (int, error) {
+	if a == nil {
+		return len(p), nil
+	}
+	a.hash.Write(p)
+	if a.buf.Len() <= maxAnswerSize {
+		a.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// Commit records the answer, or only its hash when it is too large or not JSON
+func (a *AnswerRecorder) Commit() {
+	if a == nil {
+		return
+	}
+	a.run.mu.Lock()
+	defer a.run.mu.Unlock()
+	a.run.record.ReportTask = a.task
+	if a.buf.Len() > maxAnswerSize || !json.Valid(a.buf.Bytes()) {
+		a.run.record.Answer, a.run.record.AnswerSHA256 = nil, hex.EncodeToString(a.hash.Sum(nil))
+		return
+	}
+	a.run.record.Answer, a.run.record.AnswerSHA256 = append(json.RawMessage(nil), a.buf.Bytes()...), ""
+}
+
+// SetResponse records Centrala's reply to the answer
+func (r *Run) SetResponse(code int, message string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record.Code, r.record.Message = &code, message
+}
+
+// Finish appends the record to the ledger. Only the first call writes, so
+// it can be deferred and also called on failure.
+func (r *Run) Finish(runErr error) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return nil
+	}
+	r.finished = true
+
+	r.record.Duration = time.Since(r.record.Started)
+	r.record.Retries = retry.Snapshot()
+	if runErr != nil {
+		r.record.Error = runErr.Error()
+	}
+	if err := appendRecord(r.path, &r.record); err != nil {
+		log.Error().Err(err).Str("path", r.path).Msg("Failed to write run to ledger")
+		return err
+	}
+	// Only providers that needed retries or throttling are worth a look
+	troubled := make(map[string]retry.Stats)
+	for provider, stats := range r.record.Retries {
+		if stats.Retries > 0 || stats.GaveUp > 0 || stats.Throttled > 0 {
+			troubled[provider] = stats
+		}
+	}
+	event := log.Info().Str("path", r.path)
+	if len(troubled) > 0 {
+		event = event.Interface("retries", troubled)
+	}
+	event.Msg("Run recorded")
+	return nil
+}
+
+func appendRecord(path string, record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error marshaling run: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return nil
+}
+
+// Load reads every record of the ledger in the order they were written.
+// A missing ledger has no records.
+func Load(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var record Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, fmt.Errorf("invalid ledger line %d: %w", lineNumber, err)
+			}
+			records = append(records, record)
+		}
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ledger: %w", err)
+		}
+	}
+}
+
+// Find returns the run with the given ID, or the only run whose ID starts with it
+func Find(path, id string) (*Record, error) {
+	records, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Record
+	for _, record := range records {
+		if record.RunID == id {
+			return &record, nil
+		}
+		if strings.HasPrefix(record.RunID, id) {
+			matches = append(matches, record)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("run ID %s is ambiguous, it matches %d runs", id, len(matches))
+	}
+}
+
+func hashFile(path string) (Input, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Input{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return Input{}, err
+	}
+	return Input{Path: path, SHA256: hex.EncodeToString(hash.Sum(nil)), Size: size}, nil
+}
+
+// revision is the git commit of the working tree, marked dirty when it has changes
+func revision() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	rev := strings.TrimSpace(string(out))
+	if status, err := exec.CommandContext(ctx, "git", "status", "--porcelain", "--untracked-files=no").Output(); err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		rev += "-dirty"
+	}
+	return rev
+}
@@ -0,0 +1,236 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs", "ledger.jsonl")
+	t.Setenv("LEDGER_PATH", path)
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := Begin("poligon")
+	first.AddInput(input)
+	first.AddInput(input)
+	first.AddExchange(Exchange{Model: "gpt-4o-mini", Prompt: "user: hi", Response: "hello", Cost: 0.25})
+	first.AddExchange(Exchange{Model: "gpt-4o-mini", Prompt: "user: again", Response: "hello", Cost: 0.5})
+	first.SetAnswer("POLIGON", []byte(`["a","b"]`))
+	first.SetResponse(0, "{{FLG:POLIGON}}")
+	if err := first.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+	// Only the first Finish writes
+	if err := first.Finish(fmt.Errorf("late failure")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := Begin("poligon")
+	second.SetReplayOf(first.ID())
+	second.SetAnswer("POLIGON", []byte("not json"))
+	if err := second.Finish(fmt.Errorf("report rejected")); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	got := records[0]
+	if got.RunID != first.ID() || got.Task != "poligon" || got.Error != "" || got.Started.IsZero() {
+		t.Fatalf("unexpected first record %+v", got)
+	}
+	if len(got.Inputs) != 1 || got.Inputs[0].Size != 5 ||
+		got.Inputs[0].SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("unexpected inputs %+v", got.Inputs)
+	}
+	if len(got.Exchanges) != 2 || got.Exchanges[1].Prompt != "user: again" || got.Cost != 0.75 {
+		t.Fatalf("unexpected exchanges %+v, cost %v", got.Exchanges, got.Cost)
+	}
+	if !got.Submitted() || *got.Code != 0 || got.Message != "{{FLG:POLIGON}}" ||
+		got.ReportTask != "POLIGON" || string(got.Answer) != `["a","b"]` || got.AnswerSHA256 != "" {
+		t.Fatalf("unexpected report in %+v", got)
+	}
+
+	got = records[1]
+	if got.ReplayOf != first.ID() || got.Error != "report rejected" || got.Submitted() {
+		t.Fatalf("unexpected second record %+v", got)
+	}
+	// Answers that are not JSON are kept only as a hash
+	if got.Answer != nil || got.AnswerSHA256 == "" {
+		t.Fatalf("expected only the answer hash, got %+v", got)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(lines) != 2 {
+		t.Fatalf("expected one line per run, got %q", data)
+	}
+}
+
+func TestAnswerTooLarge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	t.Setenv("LEDGER_PATH", path)
+	run := Begin("big")
+	answer, _ := json.Marshal(strings.Repeat("x", maxAnswerSize))
+	recorder := run.AnswerRecorder("BIG")
+	recorder.Write(answer[:10])
+	recorder.Write(answer[10:])
+	recorder.Commit()
+	if err := run.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := Find(path, run.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Answer != nil || len(record.AnswerSHA256) != 64 {
+		t.Fatalf("expected only the hash of a large answer, got %d bytes and %q", len(record.Answer), record.AnswerSHA256)
+	}
+}
+
+func TestNilRun(t *testing.T) {
+	var run *Run
+	run.AddInput("missing")
+	run.AddExchange(Exchange{})
+	run.SetAnswer("TASK", []byte("{}"))
+	run.SetResponse(0, "ok")
+	if run.ID() != "" || run.Finish(nil) != nil {
+		t.Fatal("expected a nil run to do nothing")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if records, err := Load(filepath.Join(dir, "missing.jsonl")); err != nil || records != nil {
+		t.Fatalf("expected no records for a missing ledger, got %v, %v", records, err)
+	}
+
+	// Blank lines are skipped and the last line may lack a newline
+	path := write("ok.jsonl", `{"run_id":"20241118T101500-aaaaaa","task":"a"}`+"\n\n"+`{"run_id":"20241118T101500-bbbbbb","task":"b"}`)
+	records, err := Load(path)
+	if err != nil || len(records) != 2 || records[1].Task != "b" {
+		t.Fatalf("unexpected records %+v, %v", records, err)
+	}
+
+	path = write("broken.jsonl", `{"run_id":"a"}`+"\n"+`{"run_id":`+"\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error for line 2, got %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	for _, id := range []string{"20241118T101500-aaaaaa", "20241118T101500-aabbbb", "20241119T090000-cccccc"} {
+		if err := appendRecord(path, &Record{RunID: id, Task: "task"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		id       string
+		expected string
+		err      string
+	}{
+		{"20241118T101500-aaaaaa", "20241118T101500-aaaaaa", ""},
+		{"20241119", "20241119T090000-cccccc", ""},
+		{"20241118T101500-aab", "20241118T101500-aabbbb", ""},
+		{"20241118T101500-aa", "", "ambiguous"},
+		{"2025", "", "not found"},
+	}
+	for _, test := range tests {
+		record, err := Find(path, test.id)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Find(%q): expected error containing %q, got %v", test.id, test.err, err)
+			}
+			continue
+		}
+		if err != nil || record.RunID != test.expected {
+			t.Errorf("Find(%q) = %v, %v, expected %s", test.id, record, err, test.expected)
+		}
+	}
+	if _, err := Find(path, "2025"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// replayingTransport reads the body twice, like a retry or redirect does
+type replayingTransport struct {
+	bodies []string
+}
+
+func (r *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	first, _ := io.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(first))
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request cannot be replayed")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	again, _ := io.ReadAll(body)
+	r.bodies = append(r.bodies, string(again))
+
+	reply := `{"model":"gpt-4o-mini-2024-07-18","choices":[{"message":{"role":"assistant","content":"Warsaw"}}],"usage":{"prompt_tokens":1000000,"completion_tokens":1000000}}`
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(reply)), Header: http.Header{}, Request: req}, nil
+}
+
+func TestTransport(t *testing.T) {
+	t.Setenv("LEDGER_PATH", filepath.Join(t.TempDir(), "ledger.jsonl"))
+	run := Begin("transport")
+	base := &replayingTransport{}
+	client := &http.Client{Transport: &Transport{Base: base}}
+
+	request := `{"model":"gpt-4o-mini","messages":[{"role":"user","content":"Capital of Poland?"}]}`
+	// A body without GetBody, as streamed request bodies have
+	req, err := http.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", io.NopCloser(strings.NewReader(request)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "Warsaw") {
+		t.Fatalf("expected the response to be passed on, got %s", body)
+	}
+	if len(base.bodies) != 2 || base.bodies[0] != request || base.bodies[1] != request {
+		t.Fatalf("expected the body to be replayable, got %q", base.bodies)
+	}
+
+	exchanges := run.record.Exchanges
+	if len(exchanges) != 1 {
+		t.Fatalf("expected one exchange, got %+v", exchanges)
+	}
+	exchange := exchanges[0]
+	if exchange.Model != "gpt-4o-mini-2024-07-18" || exchange.Prompt != "user: Capital of Poland?" || exchange.Response != "Warsaw" || exchange.Cost != 0.75 {
+		t.Fatalf("unexpected exchange %+v", exchange)
+	}
+}
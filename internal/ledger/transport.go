@@ -0,0 +1,154 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// USD per million prompt and completion tokens, matched by model name prefix
+var prices = []struct {
+	prefix             string
+	prompt, completion float64
+}{
+	// Longer prefixes first so gpt-4o-mini is not priced as gpt-4o
+	{"gpt-4o-mini", 0.15, 0.60},
+	{"gpt-4o", 2.50, 10.00},
+	{"gpt-4-turbo", 10.00, 30.00},
+	{"gpt-4", 30.00, 60.00},
+	{"gpt-3.5-turbo", 0.50, 1.50},
+	{"o1-mini", 3.00, 12.00},
+	{"o1", 15.00, 60.00},
+}
+
+// Cost estimates the price of a call in USD; unknown models cost 0
+func Cost(model string, promptTokens, completionTokens int) float64 {
+	for _, price := range prices {
+		if strings.HasPrefix(model, price.prefix) {
+			return (float64(promptTokens)*price.prompt + float64(completionTokens)*price.completion) / 1e6
+		}
+	}
+	return 0
+}
+
+// Transport records every chat completion that passes through it as an
+// exchange of the current run
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// OpenAIConfig is the OpenAI client configuration whose calls are recorded
+func OpenAIConfig(apiKey string) openai.ClientConfig {
+	config := openai.DefaultConfig(apiKey)
+	config.HTTPClient = &http.Client{Transport: &Transport{}, Timeout: httpclient.DefaultTimeout}
+	return config
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return httpclient.SDKTransport()
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	run := Current()
+	if run == nil || req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/chat/completions") || req.Body == nil {
+		return t.base().RoundTrip(req)
+	}
+
+	requestBody, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	// Retries and redirects replay the body through GetBody
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(requestBody)), nil
+	}
+
+	var request chatRequest
+	json.Unmarshal(requestBody, &request)
+	exchange := Exchange{Model: request.Model, Prompt: request.prompt()}
+
+	start := time.Now()
+	resp, err := t.base().RoundTrip(req)
+	if err != nil || request.Stream {
+		exchange.Duration = time.Since(start)
+		if err != nil {
+			exchange.Error = err.Error()
+		}
+		run.AddExchange(exchange)
+		return resp, err
+	}
+
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	exchange.Duration = time.Since(start)
+	if err != nil {
+		exchange.Error = err.Error()
+		run.AddExchange(exchange)
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	var response openai.ChatCompletionResponse
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(responseBody, &response) != nil {
+		exchange.Error = strings.TrimSpace(string(responseBody))
+	} else {
+		if response.Model != "" {
+			exchange.Model = response.Model
+		}
+		if len(response.Choices) > 0 {
+			exchange.Response = response.Choices[0].Message.Content
+		}
+		exchange.PromptTokens = response.Usage.PromptTokens
+		exchange.CompletionTokens = response.Usage.CompletionTokens
+		exchange.Cost = Cost(exchange.Model, exchange.PromptTokens, exchange.CompletionTokens)
+	}
+	run.AddExchange(exchange)
+	return resp, nil
+}
+
+// chatRequest is the part of a chat completion request worth recording
+type chatRequest struct {
+	Model    string `json:"model"`
+	Stream   bool   `json:"stream"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+// prompt joins the messages as "role: content" blocks; images are noted, not stored
+func (r *chatRequest) prompt() string {
+	var blocks []string
+	for _, message := range r.Messages {
+		var text string
+		if json.Unmarshal(message.Content, &text) != nil {
+			var parts []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			}
+			json.Unmarshal(message.Content, &parts)
+			var texts []string
+			for _, part := range parts {
+				if part.Type == "text" {
+					texts = append(texts, part.Text)
+				} else {
+					texts = append(texts, "["+part.Type+"]")
+				}
+			}
+			text = strings.Join(texts, "\n")
+		}
+		blocks = append(blocks, message.Role+": "+text)
+	}
+	return strings.Join(blocks, "\n\n")
+}
@@ -1,39 +1,191 @@
 package logging
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-func Setup(taskType string) {
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Fatal().Err(err).Msg("Can't create logs directory")
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+
+	// DefaultPathTemplate gives one log file per task; use {run} for one per run
+	DefaultPathTemplate = "logs/{task}.log"
+)
+
+// Options configure Setup. The zero value logs JSON at debug level to stdout only.
+type Options struct {
+	Level zerolog.Level
+	// Format of the stdout output: json or console. The file is always JSON.
+	Format string
+	// PathTemplate is the log file path; {task}, {run} and {date} are
+	// replaced. Empty disables the file.
+	PathTemplate string
+	// MaxSizeMB rotates the file once it would grow past this size; 0 never rotates
+	MaxSizeMB int
+	// MaxBackups is how many rotated files are kept
+	MaxBackups int
+	// RunID identifies this run on every entry; empty generates one
+	RunID string
+}
+
+// runID of the current process, set by Setup
+var runID string
+
+var (
+	fatalMu    sync.Mutex
+	fatalFuncs []func(message string)
+)
+
+// OnFatal registers a function to run when a fatal entry is logged, just
+// before the process exits, e.g. to record why a run failed
+func OnFatal(f func(message string)) {
+	fatalMu.Lock()
+	defer fatalMu.Unlock()
+	fatalFuncs = append(fatalFuncs, f)
+}
+
+// fatalHook runs the OnFatal functions
+type fatalHook struct{}
+
+func (fatalHook) Run(_ *zerolog.Event, level zerolog.Level, message string) {
+	if level != zerolog.FatalLevel {
+		return
+	}
+	fatalMu.Lock()
+	funcs := fatalFuncs
+	fatalFuncs = nil
+	fatalMu.Unlock()
+	for _, f := range funcs {
+		f(message)
 	}
+}
 
-	// Open a file for writing logs
-	logFile, err := os.OpenFile(
-		"logs/app.log",
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0664,
-	)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Could not open log file")
+// RunID returns the ID that Setup attached to every log entry
+func RunID() string {
+	return runID
+}
+
+// NewRunID returns a sortable, unique run ID such as 20241118T101500-3f2a9c
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// DefaultOptions reads the options from LOG_LEVEL, LOG_FORMAT, LOG_PATH,
+// LOG_MAX_SIZE_MB, LOG_MAX_BACKUPS and RUN_ID. Invalid values are ignored.
+func DefaultOptions() Options {
+	opts := Options{
+		Level:        zerolog.DebugLevel,
+		Format:       FormatJSON,
+		PathTemplate: DefaultPathTemplate,
+		MaxSizeMB:    10,
+		MaxBackups:   5,
+		RunID:        os.Getenv("RUN_ID"),
+	}
+	if level, err := zerolog.ParseLevel(os.Getenv("LOG_LEVEL")); err == nil && level != zerolog.NoLevel {
+		opts.Level = level
 	}
+	if format := os.Getenv("LOG_FORMAT"); format == FormatJSON || format == FormatConsole {
+		opts.Format = format
+	}
+	if path, ok := os.LookupEnv("LOG_PATH"); ok {
+		opts.PathTemplate = path
+	}
+	if size, err := strconv.Atoi(os.Getenv("LOG_MAX_SIZE_MB")); err == nil && size >= 0 {
+		opts.MaxSizeMB = size
+	}
+	if backups, err := strconv.Atoi(os.Getenv("LOG_MAX_BACKUPS")); err == nil && backups >= 0 {
+		opts.MaxBackups = backups
+	}
+	return opts
+}
 
-	// Configure zerolog
-	multi := zerolog.MultiLevelWriter(os.Stdout, logFile)
+// RegisterFlags adds the -log-* flags to fs, defaulting to DefaultOptions.
+// The returned options are filled in when fs is parsed.
+func RegisterFlags(fs *flag.FlagSet) *Options {
+	opts := DefaultOptions()
+	fs.Func("log-level", "log level: trace, debug, info, warn or error (env LOG_LEVEL, default "+opts.Level.String()+")", func(value string) error {
+		level, err := zerolog.ParseLevel(value)
+		if err != nil || level == zerolog.NoLevel {
+			return fmt.Errorf("unknown log level %q", value)
+		}
+		opts.Level = level
+		return nil
+	})
+	fs.Func("log-format", "stdout log format: json or console (env LOG_FORMAT, default "+opts.Format+")", func(value string) error {
+		if value != FormatJSON && value != FormatConsole {
+			return fmt.Errorf("unknown log format %q", value)
+		}
+		opts.Format = value
+		return nil
+	})
+	fs.StringVar(&opts.PathTemplate, "log-path", opts.PathTemplate, "log file path with {task}, {run} and {date} placeholders, empty for none (env LOG_PATH)")
+	fs.IntVar(&opts.MaxSizeMB, "log-max-size", opts.MaxSizeMB, "rotate the log file at this size in MB, 0 to never rotate (env LOG_MAX_SIZE_MB)")
+	fs.IntVar(&opts.MaxBackups, "log-max-backups", opts.MaxBackups, "number of rotated log files to keep (env LOG_MAX_BACKUPS)")
+	fs.StringVar(&opts.RunID, "run-id", opts.RunID, "run ID attached to every log entry, generated when empty (env RUN_ID)")
+	return &opts
+}
+
+// Setup configures the global zerolog logger for a task. If the log file
+// cannot be opened the logger still writes to stdout and a warning is logged.
+func Setup(taskType string, opts Options) {
+	if opts.RunID == "" {
+		opts.RunID = NewRunID()
+	}
+	runID = opts.RunID
+
+	var stdout io.Writer = os.Stdout
+	if opts.Format == FormatConsole {
+		stdout = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	}
+	writers := []io.Writer{stdout}
+
+	var fileErr error
+	path := ExpandPath(opts.PathTemplate, taskType, opts.RunID)
+	if path != "" {
+		var file io.Writer
+		if file, fileErr = newRotatingFile(path, int64(opts.MaxSizeMB)<<20, opts.MaxBackups); fileErr == nil {
+			writers = append(writers, file)
+		}
+	}
+
+	// Configure zerolog; secrets are masked before reaching any writer
+	multi := NewRedactWriter(zerolog.MultiLevelWriter(writers...))
 
-	// Basic JSON configuration
 	zerolog.TimeFieldFormat = time.RFC3339
-	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	zerolog.SetGlobalLevel(opts.Level)
 
 	log.Logger = zerolog.New(multi).
 		With().
 		Timestamp().
 		Str("app", taskType).
-		Logger()
+		Str("run", opts.RunID).
+		Logger().
+		Hook(fatalHook{})
+
+	if fileErr != nil {
+		log.Warn().Err(fileErr).Str("path", path).Msg("Logging to stdout only")
+	}
+}
+
+// ExpandPath fills in the placeholders of a log path template
+func ExpandPath(template, taskType, runID string) string {
+	return strings.NewReplacer(
+		"{task}", taskType,
+		"{run}", runID,
+		"{date}", time.Now().Format("2006-01-02"),
+	).Replace(template)
 }
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// Redacted replaces every masked value
+const Redacted = "[REDACTED]"
+
+// minSecretLength keeps short values from masking ordinary words
+const minSecretLength = 6
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+var (
+	// Sensitive fields as JSON ("apikey":"...") or as JSON inside a string (\"apikey\":\"...\")
+	fieldPattern        = regexp.MustCompile(`(?i)("(?:apikey|api_key|api-key|x-api-key|authorization|key|password|secret)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	escapedFieldPattern = regexp.MustCompile(`(?i)(\\"(?:apikey|api_key|api-key|x-api-key|authorization|key|password|secret)\\"\s*:\s*)\\"(?:[^"\\]|\\[^"])*\\"`)
+	bearerPattern       = regexp.MustCompile(`(?i)\b(Bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// RegisterSecret masks a value in every log entry written from now on.
+// The secret chain registers everything it resolves.
+func RegisterSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, known := range secrets {
+		if known == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+	// Longer secrets first, so one that contains another is masked whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// Redact masks registered secrets, sensitive fields and bearer tokens in a log entry
+func Redact(entry []byte) []byte {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		entry = bytes.ReplaceAll(entry, []byte(secret), []byte(Redacted))
+		// The value as it appears inside a JSON string, if escaping changes it
+		if escaped, err := json.Marshal(secret); err == nil {
+			if inner := escaped[1 : len(escaped)-1]; !bytes.Equal(inner, []byte(secret)) {
+				entry = bytes.ReplaceAll(entry, inner, []byte(Redacted))
+			}
+		}
+	}
+	secretsMu.RUnlock()
+
+	entry = fieldPattern.ReplaceAll(entry, []byte(`$1"`+Redacted+`"`))
+	entry = escapedFieldPattern.ReplaceAll(entry, []byte(`$1\"`+Redacted+`\"`))
+	entry = bearerPattern.ReplaceAll(entry, []byte(`${1}`+Redacted))
+	return entry
+}
+
+// redactWriter masks each entry before passing it on to the real writers
+type redactWriter struct {
+	next zerolog.LevelWriter
+}
+
+// NewRedactWriter wraps the writers so no entry reaches them unredacted
+func NewRedactWriter(next io.Writer) zerolog.LevelWriter {
+	levelWriter, ok := next.(zerolog.LevelWriter)
+	if !ok {
+		levelWriter = zerolog.MultiLevelWriter(next)
+	}
+	return &redactWriter{next: levelWriter}
+}
+
+// Write reports the original length, since callers only check that the whole entry was taken
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := w.next.Write(Redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *redactWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if _, err := w.next.WriteLevel(level, Redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readLogs returns the content of the log file and its backups, missing files as ""
+func readLogs(t *testing.T, path string, backups int) []string {
+	t.Helper()
+	contents := make([]string, backups+1)
+	for i := range contents {
+		name := path
+		if i > 0 {
+			name = backupPath(path, i)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		contents[i] = string(data)
+	}
+	return contents
+}
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		writes     []string
+		// expected content of the file, then of .1, .2, ...
+		expected []string
+	}{
+		{"under the limit", 10, 2, []string{"aaaa", "bbbb"}, []string{"aaaabbbb", "", ""}},
+		{"exactly at the limit", 8, 2, []string{"aaaa", "bbbb"}, []string{"aaaabbbb", "", ""}},
+		{"past the limit", 8, 2, []string{"aaaa", "bbbb", "c"}, []string{"c", "aaaabbbb", ""}},
+		{"backups shift", 4, 2, []string{"aaaa", "bbbb", "cccc"}, []string{"cccc", "bbbb", "aaaa"}},
+		{"oldest backup dropped", 4, 2, []string{"aaaa", "bbbb", "cccc", "dddd"}, []string{"dddd", "cccc", "bbbb"}},
+		{"entry larger than the limit", 4, 1, []string{"aaaaaaaa", "bb"}, []string{"bb", "aaaaaaaa"}},
+		{"no backups", 4, 0, []string{"aaaa", "bbbb"}, []string{"bbbb"}},
+		{"never rotates", 0, 1, []string{"aaaa", "bbbb", "cccc"}, []string{"aaaabbbbcccc", ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "app.log")
+			file, err := newRotatingFile(path, test.maxSize, test.maxBackups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { file.file.Close() }()
+			for _, entry := range test.writes {
+				if n, err := file.Write([]byte(entry)); err != nil || n != len(entry) {
+					t.Fatalf("write of %q returned %d, %v", entry, n, err)
+				}
+			}
+			got := readLogs(t, path, test.maxBackups)
+			if strings.Join(got, "|") != strings.Join(test.expected, "|") {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+			if _, err := os.Stat(backupPath(path, test.maxBackups+1)); !os.IsNotExist(err) {
+				t.Fatalf("expected at most %d backups", test.maxBackups)
+			}
+		})
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("aaaaaa"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	// The existing content counts towards the limit
+	file, err := newRotatingFile(path, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { file.file.Close() }()
+	if _, err := file.Write([]byte("bbb")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLogs(t, path, 1); got[0] != "bbb" || got[1] != "aaaaaa" {
+		t.Fatalf("expected the existing file to be rotated, got %q", got)
+	}
+}
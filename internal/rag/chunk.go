@@ -0,0 +1,46 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/text"
+)
+
+// Chunk is a fragment of a source document with its position in the original text
+type Chunk struct {
+	ID        string
+	Source    string // document ID the chunk comes from
+	Index     int    // position of the chunk within the document
+	Start     int    // byte offset in the document text
+	End       int
+	Timestamp string // first time marker found in the chunk, e.g. "00:11"
+	Text      string
+}
+
+// Matches time markers used in reports and transcripts: "00:11", "[01:02:03]"
+var timestampPattern = regexp.MustCompile(`\b\d{1,2}:\d{2}(?::\d{2})?\b`)
+
+// ChunkText splits a document into token-bounded chunks, preferring paragraph
+// and sentence boundaries. Consecutive chunks share about opts.Overlap tokens.
+func ChunkText(source, content string, opts text.SplitOptions) []Chunk {
+	var chunks []Chunk
+	for _, segment := range text.Split(content, opts) {
+		chunkText := strings.TrimSpace(segment.Text)
+		if chunkText == "" {
+			continue
+		}
+		index := len(chunks)
+		chunks = append(chunks, Chunk{
+			ID:        fmt.Sprintf("%s#%d", source, index),
+			Source:    source,
+			Index:     index,
+			Start:     segment.Start,
+			End:       segment.End,
+			Timestamp: timestampPattern.FindString(chunkText),
+			Text:      chunkText,
+		})
+	}
+	return chunks
+}
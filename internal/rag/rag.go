@@ -0,0 +1,288 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/documents"
+	"github.com/dawidjelenkowski/aidevs3go/internal/embeddings"
+	"github.com/dawidjelenkowski/aidevs3go/internal/text"
+	"github.com/dawidjelenkowski/aidevs3go/internal/vectorstore"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Pipeline chunks and indexes documents, retrieves the chunks relevant to a
+// question and answers it with citations of the chunks that supported the answer
+type Pipeline struct {
+	Embedder embeddings.Embedder
+	Store    *vectorstore.Store
+	Client   *openai.Client
+	Model    string
+	// Generate answers with another provider instead of Client. It gets the
+	// system prompt and the question and must reply with the JSON answer.
+	Generate GenerateFunc
+
+	// Chunk sizes are in tokens of Model
+	ChunkSize    int
+	ChunkOverlap int
+	TopK         int
+	// MaxContextTokens caps the retrieved text put into the prompt; 0 uses half of the model's window
+	MaxContextTokens int
+	// System is prepended to the answering instructions, e.g. to describe the domain
+	System string
+}
+
+// GenerateFunc returns a model reply to the question under the system prompt
+type GenerateFunc func(ctx context.Context, system, question string) (string, error)
+
+// Citation points to the chunk that supported an answer
+type Citation struct {
+	Source    string `json:"source"`
+	Chunk     int    `json:"chunk"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Quote     string `json:"quote"`
+}
+
+// Answer is the model answer with the citations it relied on
+type Answer struct {
+	Text      string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	// Retrieved holds every chunk that was put into the prompt
+	Retrieved []vectorstore.Result `json:"-"`
+}
+
+// Index chunks the documents, embeds the chunks and stores them. Chunks of a
+// document that was indexed before are replaced.
+func (p *Pipeline) Index(ctx context.Context, docs []*documents.Document) error {
+	if err := p.Store.CheckModel(p.Embedder.Model()); err != nil {
+		return err
+	}
+
+	var chunks []Chunk
+	for _, doc := range docs {
+		chunks = append(chunks, ChunkText(doc.Name, doc.Text, text.SplitOptions{
+			Model:     p.model(),
+			ChunkSize: p.ChunkSize,
+			Overlap:   p.ChunkOverlap,
+		})...)
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("no text to index")
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	vectors, err := p.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks: %w", err)
+	}
+
+	for _, doc := range docs {
+		p.Store.DeleteDocument(doc.Name)
+	}
+	for i, chunk := range chunks {
+		record := vectorstore.Record{
+			ID:         chunk.ID,
+			DocumentID: chunk.Source,
+			Text:       chunk.Text,
+			Vector:     vectors[i],
+			Metadata: map[string]string{
+				"source":    chunk.Source,
+				"chunk":     strconv.Itoa(chunk.Index),
+				"start":     strconv.Itoa(chunk.Start),
+				"end":       strconv.Itoa(chunk.End),
+				"timestamp": chunk.Timestamp,
+			},
+		}
+		if err := p.Store.Upsert(record); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Int("documents", len(docs)).Int("chunks", len(chunks)).Msg("Documents indexed")
+	return nil
+}
+
+// model returns the answering model, also used to count tokens
+func (p *Pipeline) model() string {
+	if p.Model == "" {
+		return openai.GPT4o
+	}
+	return p.Model
+}
+
+// Retrieve returns the chunks most relevant to the question
+func (p *Pipeline) Retrieve(ctx context.Context, question string, filter vectorstore.Filter) ([]vectorstore.Result, error) {
+	vectors, err := p.Embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	topK := p.TopK
+	if topK <= 0 {
+		topK = 5
+	}
+	results := p.Store.Search(vectors[0], topK, filter)
+	for _, result := range results {
+		log.Debug().Str("chunk", result.ID).Float64("score", result.Score).Msg("Retrieved chunk")
+	}
+	return results, nil
+}
+
+// modelAnswer is the structured output requested from the model
+type modelAnswer struct {
+	Answer  string   `json:"answer"`
+	Sources []string `json:"sources"`
+}
+
+// Ask retrieves context for the question and answers it with citations
+func (p *Pipeline) Ask(ctx context.Context, question string) (*Answer, error) {
+	results, err := p.Retrieve(ctx, question, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no indexed chunks to answer from")
+	}
+
+	// Keep the best chunks that fit the context budget
+	budget := p.MaxContextTokens
+	if budget <= 0 {
+		budget = text.ContextWindow(p.model()) / 2
+	}
+	used := 0
+	for i, result := range results {
+		used += text.CountTokens(p.model(), result.Text)
+		if used > budget && i > 0 {
+			log.Warn().Int("budget", budget).Int("kept", i).Int("retrieved", len(results)).Msg("Retrieved chunks exceed the context budget")
+			results = results[:i]
+			break
+		}
+	}
+
+	// Label every chunk so the model can cite it
+	var sources strings.Builder
+	byLabel := make(map[string]vectorstore.Result)
+	for i, result := range results {
+		label := fmt.Sprintf("S%d", i+1)
+		byLabel[label] = result
+		fmt.Fprintf(&sources, "<source id=\"%s\" file=\"%s\" chunk=\"%s\">\n%s\n</source>\n",
+			label, result.Metadata["source"], result.Metadata["chunk"], result.Text)
+	}
+
+	schema := &jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"answer":  {Type: jsonschema.String},
+			"sources": {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String}, Description: "ids of the sources that support the answer"},
+		},
+		Required:             []string{"answer", "sources"},
+		AdditionalProperties: false,
+	}
+
+	system := strings.TrimSpace(p.System + "\n\n" +
+		"Answer the question using the sources below. Base your reasoning on the sources and cite " +
+		"the ids of every source you relied on. You may add well-known general facts (e.g. addresses " +
+		"of public institutions) when the sources identify the entity but omit the detail. " +
+		"If the sources do not identify the answer, say so instead of guessing.\n\n" + sources.String())
+
+	var content string
+	if p.Generate != nil {
+		// Other providers get the output format as instructions
+		system += "\n\nReply only with JSON: {\"answer\": \"...\", \"sources\": [\"ids of the sources that support the answer\"]}"
+		content, err = p.Generate(ctx, system, question)
+	} else {
+		content, err = p.complete(ctx, system, question, schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed modelAnswer
+	if err := schema.Unmarshal(stripFence(content), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse answer: %w", err)
+	}
+
+	answer := &Answer{Text: parsed.Answer, Retrieved: results}
+	for _, label := range parsed.Sources {
+		result, ok := byLabel[strings.TrimSpace(label)]
+		if !ok {
+			log.Warn().Str("source", label).Msg("Model cited an unknown source")
+			continue
+		}
+		answer.Citations = append(answer.Citations, citationFor(result))
+	}
+
+	log.Info().Str("question", question).Str("answer", answer.Text).Int("citations", len(answer.Citations)).Msg("Question answered")
+	return answer, nil
+}
+
+// complete asks the OpenAI model for an answer in the cited answer schema
+func (p *Pipeline) complete(ctx context.Context, system, question string, schema *jsonschema.Definition) (string, error) {
+	resp, err := p.Client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: p.model(),
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: system},
+			{Role: openai.ChatMessageRoleUser, Content: question},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "cited_answer",
+				Schema: schema,
+				Strict: true,
+			},
+		},
+		Temperature: 0.0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("OpenAI API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// stripFence removes a ```json ... ``` fence models put around JSON replies
+func stripFence(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "```") {
+		return reply
+	}
+	reply = strings.TrimPrefix(reply, "```")
+	if newline := strings.Index(reply, "\n"); newline >= 0 {
+		reply = reply[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(reply), "```"))
+}
+
+// citationFor builds a citation from the metadata stored with a chunk
+func citationFor(result vectorstore.Result) Citation {
+	chunk, _ := strconv.Atoi(result.Metadata["chunk"])
+	start, _ := strconv.Atoi(result.Metadata["start"])
+	end, _ := strconv.Atoi(result.Metadata["end"])
+
+	quote := strings.Join(strings.Fields(result.Text), " ")
+	if runes := []rune(quote); len(runes) > 200 {
+		quote = string(runes[:200]) + "..."
+	}
+
+	return Citation{
+		Source:    result.Metadata["source"],
+		Chunk:     chunk,
+		Start:     start,
+		End:       end,
+		Timestamp: result.Metadata["timestamp"],
+		Quote:     quote,
+	}
+}
@@ -0,0 +1,159 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/text"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// llmChunkTokens keeps each request small enough for reliable extraction
+const llmChunkTokens = 2000
+
+// streetPrefix matches a street prefix the model may include in a street entity
+var streetPrefix = regexp.MustCompile(`^(?:[Uu]l\.|[Uu]lic[aąyę]|[Aa]l\.|[Aa]lej[ai]|[Oo]s\.|[Pp]l\.)\s*`)
+
+// LLMDetector asks a model to list sensitive fragments verbatim. The model
+// never rewrites the text, so the output is still built from the original.
+type LLMDetector struct {
+	Client *openai.Client
+	Model  string
+}
+
+type llmEntity struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type llmResult struct {
+	Entities []llmEntity `json:"entities"`
+}
+
+func (d *LLMDetector) Name() string {
+	return "llm"
+}
+
+func (d *LLMDetector) model() string {
+	if d.Model == "" {
+		return openai.GPT4oMini
+	}
+	return d.Model
+}
+
+func (d *LLMDetector) Detect(ctx context.Context, content string) ([]Entity, error) {
+	var entities []Entity
+	for _, segment := range text.Split(content, text.SplitOptions{Model: d.model(), ChunkSize: llmChunkTokens}) {
+		found, err := d.detectSegment(ctx, segment.Text)
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range found {
+			entity.Start += segment.Start
+			entity.End += segment.Start
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+func (d *LLMDetector) detectSegment(ctx context.Context, segment string) ([]Entity, error) {
+	schema := &jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"entities": {
+				Type: jsonschema.Array,
+				Items: &jsonschema.Definition{
+					Type: jsonschema.Object,
+					Properties: map[string]jsonschema.Definition{
+						"text": {Type: jsonschema.String, Description: "the fragment copied exactly from the text"},
+						"type": {Type: jsonschema.String, Enum: []string{TypeName, TypeStreet, TypeCity, TypeAge}},
+					},
+					Required:             []string{"text", "type"},
+					AdditionalProperties: false,
+				},
+			},
+		},
+		Required:             []string{"entities"},
+		AdditionalProperties: false,
+	}
+
+	system := "List every fragment of the text that identifies a person: first and last names, " +
+		"street names with the house number (without the prefix such as \"ul.\"), cities, and ages " +
+		"(the number only). Copy each fragment exactly as it appears, including its grammatical case. " +
+		"Do not list anything else."
+
+	resp, err := d.Client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: d.model(),
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: system},
+			{Role: openai.ChatMessageRoleUser, Content: segment},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "sensitive_fragments",
+				Schema: schema,
+				Strict: true,
+			},
+		},
+		Temperature: 0.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("OpenAI returned no choices")
+	}
+	var parsed llmResult
+	if err := schema.Unmarshal(resp.Choices[0].Message.Content, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse entities: %w", err)
+	}
+
+	// Map the fragments back to every place they occur
+	var entities []Entity
+	for _, found := range parsed.Entities {
+		fragment := strings.TrimSpace(found.Text)
+		if found.Type == TypeStreet {
+			fragment = streetPrefix.ReplaceAllString(fragment, "")
+		}
+		if fragment == "" {
+			continue
+		}
+		if !strings.Contains(segment, fragment) {
+			log.Warn().Str("fragment", fragment).Msg("Model returned a fragment that is not in the text")
+			continue
+		}
+		for offset := 0; ; {
+			idx := strings.Index(segment[offset:], fragment)
+			if idx < 0 {
+				break
+			}
+			start, end := offset+idx, offset+idx+len(fragment)
+			if isWordBoundary(segment, start, end) {
+				entities = append(entities, Entity{Start: start, End: end, Type: found.Type, Source: d.Name()})
+			}
+			offset = end
+		}
+	}
+	return entities, nil
+}
+
+// isWordBoundary reports whether text[start:end] is not part of a longer word or number
+func isWordBoundary(text string, start, end int) bool {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWord(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWord(after) {
+		return false
+	}
+	return true
+}
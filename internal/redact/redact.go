@@ -0,0 +1,162 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultPlaceholder is the token Centrala expects in censored text
+const DefaultPlaceholder = "CENZURA"
+
+// Entity types
+const (
+	TypeName   = "name"
+	TypeStreet = "street"
+	TypeCity   = "city"
+	TypeAge    = "age"
+)
+
+// MergeMode decides when neighbouring entities become a single placeholder
+type MergeMode int
+
+const (
+	// MergeSameType joins entities of the same type separated only by whitespace,
+	// e.g. first and last name -> one placeholder
+	MergeSameType MergeMode = iota
+	// MergeAny joins any entities separated only by whitespace
+	MergeAny
+	// MergeNone keeps one placeholder per detected entity
+	MergeNone
+)
+
+// Entity is a span of sensitive text, as byte offsets into the original
+type Entity struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Source string `json:"source"` // which detector found it
+}
+
+// Detector finds sensitive entities in text
+type Detector interface {
+	Name() string
+	Detect(ctx context.Context, text string) ([]Entity, error)
+}
+
+// Redactor combines detectors and replaces the entities they find
+type Redactor struct {
+	Placeholder string
+	Merge       MergeMode
+	Detectors   []Detector
+}
+
+// Result is the redacted text with the entities that were replaced
+type Result struct {
+	Text     string
+	Entities []Entity
+}
+
+// New creates a redactor with the default placeholder and merge mode
+func New(detectors ...Detector) *Redactor {
+	return &Redactor{
+		Placeholder: DefaultPlaceholder,
+		Merge:       MergeSameType,
+		Detectors:   detectors,
+	}
+}
+
+// Redact runs every detector, resolves overlaps and merges, and replaces the
+// entities. All characters outside the entities are preserved exactly.
+func (r *Redactor) Redact(ctx context.Context, text string) (*Result, error) {
+	var found []Entity
+	for _, detector := range r.Detectors {
+		entities, err := detector.Detect(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s detector failed: %w", detector.Name(), err)
+		}
+		log.Debug().Str("detector", detector.Name()).Int("entities", len(entities)).Msg("Entities detected")
+		found = append(found, entities...)
+	}
+
+	entities := merge(text, resolveOverlaps(found), r.Merge)
+	for _, entity := range entities {
+		log.Debug().Str("type", entity.Type).Str("source", entity.Source).Int("start", entity.Start).Int("end", entity.End).Msg("Redacting entity")
+	}
+
+	placeholder := r.Placeholder
+	if placeholder == "" {
+		placeholder = DefaultPlaceholder
+	}
+	return &Result{Text: Apply(text, entities, placeholder), Entities: entities}, nil
+}
+
+// Apply replaces non-overlapping, sorted entities with the placeholder
+func Apply(text string, entities []Entity, placeholder string) string {
+	var out strings.Builder
+	pos := 0
+	for _, entity := range entities {
+		out.WriteString(text[pos:entity.Start])
+		out.WriteString(placeholder)
+		pos = entity.End
+	}
+	out.WriteString(text[pos:])
+	return out.String()
+}
+
+// resolveOverlaps sorts entities and unions the ones that overlap. The type
+// of the longest entity in a group wins.
+func resolveOverlaps(entities []Entity) []Entity {
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].Start != entities[j].Start {
+			return entities[i].Start < entities[j].Start
+		}
+		return entities[i].End > entities[j].End
+	})
+
+	var result []Entity
+	for _, entity := range entities {
+		if entity.End <= entity.Start {
+			continue
+		}
+		if n := len(result); n > 0 && entity.Start < result[n-1].End {
+			last := &result[n-1]
+			if entity.End-entity.Start > last.End-last.Start {
+				last.Type = entity.Type
+			}
+			if entity.End > last.End {
+				last.End = entity.End
+			}
+			if !strings.Contains(last.Source, entity.Source) {
+				last.Source += "+" + entity.Source
+			}
+			continue
+		}
+		result = append(result, entity)
+	}
+	return result
+}
+
+// merge joins neighbouring entities separated only by whitespace, depending on the mode
+func merge(text string, entities []Entity, mode MergeMode) []Entity {
+	var result []Entity
+	for _, entity := range entities {
+		if n := len(result); n > 0 && mode != MergeNone {
+			last := &result[n-1]
+			gap := text[last.End:entity.Start]
+			if strings.TrimSpace(gap) == "" && (mode == MergeAny || last.Type == entity.Type) {
+				last.End = entity.End
+				continue
+			}
+		}
+		result = append(result, entity)
+	}
+	for i := range result {
+		result[i].Text = text[result[i].Start:result[i].End]
+	}
+	return result
+}
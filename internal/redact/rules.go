@@ -0,0 +1,276 @@
+package redact
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// Street prefix followed by the street name and an optional house number. Only
+	// the name and number are redacted, the prefix ("ul.") stays in the text.
+	streetPattern = regexp.MustCompile(`(?:\b[Uu]l\.|\b[Uu]lic[aąyę]|\b[Aa]l\.|\b[Aa]lej[ai]|\b[Aa]lei|\b[Oo]s\.|\b[Oo]siedl[eu]|\b[Pp]l\.|\b[Pp]lac[u]?)\s+` +
+		`((?:\p{Lu}[\p{L}-]*[ \t]+)*\p{Lu}[\p{L}-]*(?:[ \t]+\d+[A-Za-z]?(?:\s*/\s*\d+[A-Za-z]?)?)?)`)
+
+	// Age expressions: "34 lata", "56 lat", "w wieku 34", "lat 34". Only the number is redacted.
+	agePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b(\d{1,3})(?:-|\s+)(?:lat|lata|latek|latka|letni|letnia|roku życia)\b`),
+		regexp.MustCompile(`\b[Ww]iek(?:u)?:?\s+(\d{1,3})\b`),
+		regexp.MustCompile(`\blat\s+(\d{1,3})\b`),
+	}
+
+	// Capitalized words, the candidates for names and cities
+	capitalizedWord = regexp.MustCompile(`\p{Lu}[\p{Ll}]+(?:-\p{Lu}[\p{Ll}]+)?`)
+
+	// A lowercase word followed by a capitalized one; a surname if the first is an honorific
+	honorificPattern = regexp.MustCompile(`(\p{L}+)\s+(\p{Lu}\p{Ll}+)`)
+	honorific        = map[string]bool{"pan": true, "pani": true, "pana": true, "panu": true, "panem": true, "panią": true}
+)
+
+// Common Polish first names and cities; inflected forms are matched by stem and case ending
+var (
+	firstNames = []string{
+		"Adam", "Adrian", "Agata", "Agnieszka", "Aleksander", "Aleksandra", "Alicja", "Andrzej", "Anna", "Antoni",
+		"Arkadiusz", "Artur", "Barbara", "Bartosz", "Beata", "Bogdan", "Bożena", "Damian", "Daniel", "Danuta",
+		"Dariusz", "Dawid", "Dominik", "Dorota", "Edward", "Elżbieta", "Emilia", "Ewa", "Ewelina", "Filip",
+		"Grażyna", "Grzegorz", "Halina", "Henryk", "Hubert", "Irena", "Iwona", "Jacek", "Jadwiga", "Jakub",
+		"Jan", "Janina", "Janusz", "Jarosław", "Jerzy", "Joanna", "Jolanta", "Józef", "Julia", "Justyna",
+		"Kamil", "Kamila", "Karol", "Karolina", "Katarzyna", "Kazimierz", "Krystyna", "Krzysztof", "Leszek", "Łukasz",
+		"Maciej", "Magdalena", "Małgorzata", "Marcin", "Marek", "Maria", "Marian", "Mariusz", "Marta", "Mateusz",
+		"Michał", "Mirosław", "Monika", "Natalia", "Patryk", "Paulina", "Paweł", "Piotr", "Przemysław", "Rafał",
+		"Renata", "Robert", "Roman", "Ryszard", "Sebastian", "Stanisław", "Stefan", "Sylwia", "Szymon", "Tadeusz",
+		"Teresa", "Tomasz", "Urszula", "Wiesław", "Wiktoria", "Witold", "Władysław", "Wojciech", "Zbigniew", "Zdzisław",
+		"Zofia", "Zuzanna",
+	}
+	cities = []string{
+		"Warszawa", "Kraków", "Łódź", "Wrocław", "Poznań", "Gdańsk", "Szczecin", "Bydgoszcz", "Lublin", "Białystok",
+		"Katowice", "Gdynia", "Częstochowa", "Radom", "Toruń", "Sosnowiec", "Rzeszów", "Kielce", "Gliwice", "Olsztyn",
+		"Zabrze", "Bielsko-Biała", "Bytom", "Zielona Góra", "Rybnik", "Ruda Śląska", "Opole", "Tychy", "Gorzów Wielkopolski", "Elbląg",
+		"Płock", "Dąbrowa Górnicza", "Wałbrzych", "Włocławek", "Tarnów", "Chorzów", "Koszalin", "Kalisz", "Legnica", "Grudziądz",
+		"Słupsk", "Jaworzno", "Jastrzębie-Zdrój", "Nowy Sącz", "Jelenia Góra", "Siedlce", "Mysłowice", "Konin", "Piła", "Piotrków Trybunalski",
+		"Inowrocław", "Lubin", "Ostrów Wielkopolski", "Suwałki", "Gniezno", "Głogów", "Siemianowice Śląskie", "Pabianice", "Leszno", "Zamość",
+		"Łomża", "Żory", "Pruszków", "Ełk", "Tomaszów Mazowiecki", "Chełm", "Mielec", "Przemyśl", "Stalowa Wola", "Tczew",
+		"Sopot", "Zakopane", "Gniew", "Malbork", "Puławy",
+		// Irregular declensions the stem match misses
+		"Białegostoku", "Białymstoku",
+	}
+)
+
+// foldDiacritics maps Polish letters to ASCII so that vowel alternations in
+// inflection (Łódź -> Łodzi, Kraków -> Krakowie) keep a common stem
+var foldDiacritics = strings.NewReplacer(
+	"ą", "a", "ć", "c", "ę", "e", "ł", "l", "ń", "n", "ó", "o", "ś", "s", "ź", "z", "ż", "z",
+)
+
+func fold(word string) string {
+	return foldDiacritics.Replace(strings.ToLower(word))
+}
+
+// caseEndings are the Polish case endings, folded, that inflect names and
+// cities from their stem: Piotr-a, Ann-ie, Warszaw-ie, Zielon-ej Gór-ze
+var caseEndings = map[string]bool{
+	"a": true, "u": true, "e": true, "y": true, "i": true, "o": true,
+	"em": true, "om": true, "ie": true, "iu": true, "ia": true, "ze": true,
+	"zie": true, "iem": true, "owi": true, "owie": true, "ow": true, "ach": true,
+	"ami": true, "ej": true, "iej": true, "ego": true, "ym": true, "im": true,
+}
+
+// stems returns the folded stems a base form is inflected from: the base
+// itself, the base without its final vowel (Anna -> Ann-y) or without a
+// fleeting e (Marek -> Mark-a, Paweł -> Pawł-a)
+func stems(base string) []string {
+	r := []rune(fold(base))
+	result := []string{string(r)}
+	n := len(r)
+	if n > 2 && isVowel(r[n-1]) {
+		result = append(result, string(r[:n-1]))
+	} else if n > 3 && r[n-2] == 'e' && !isVowel(r[n-1]) {
+		result = append(result, string(r[:n-2])+string(r[n-1]))
+	}
+	return result
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiouy", r)
+}
+
+// inflectionOf reports whether candidate is a stem of base followed by a case
+// ending, e.g. Piotra/Piotrem of Piotr, Łodzi of Łódź, Warszawie of
+// Warszawa. Unrelated words sharing a prefix (Marzec, Dania) do not match.
+func inflectionOf(candidate, base string) bool {
+	c := fold(candidate)
+	for _, stem := range stems(base) {
+		if ending, ok := strings.CutPrefix(c, stem); ok && caseEndings[ending] {
+			return true
+		}
+		// t softens to c before -ie: Marta -> Marcie, Robert -> Robercie
+		if soft, ok := strings.CutSuffix(stem, "t"); ok && c == soft+"cie" {
+			return true
+		}
+	}
+	return false
+}
+
+// dictionary matches single words against base forms with inflection
+type dictionary struct {
+	words map[string][]string // first folded letter -> base forms
+}
+
+func newDictionary(words []string) *dictionary {
+	d := &dictionary{words: make(map[string][]string)}
+	for _, word := range words {
+		key := firstLetter(word)
+		d.words[key] = append(d.words[key], word)
+	}
+	return d
+}
+
+func firstLetter(word string) string {
+	r, _ := utf8.DecodeRuneInString(fold(word))
+	return string(r)
+}
+
+// contains reports whether candidate is a base form or an inflection of one;
+// exact is true for the base form itself
+func (d *dictionary) contains(candidate string) (found, exact bool) {
+	for _, base := range d.words[firstLetter(candidate)] {
+		if strings.EqualFold(candidate, base) {
+			return true, true
+		}
+		if inflectionOf(candidate, base) {
+			found = true
+		}
+	}
+	return found, false
+}
+
+// RuleDetector finds names, streets, cities and ages with patterns and dictionaries
+type RuleDetector struct {
+	firstNames *dictionary
+	cities     *dictionary
+	// multi-word cities ("Zielona Góra"), split into words
+	cityPhrases [][]string
+}
+
+// NewRuleDetector creates a detector with the built-in Polish dictionaries.
+// Extra first names and cities extend them.
+func NewRuleDetector(extraFirstNames, extraCities []string) *RuleDetector {
+	d := &RuleDetector{
+		firstNames: newDictionary(append(append([]string{}, firstNames...), extraFirstNames...)),
+	}
+	var singleWord []string
+	for _, city := range append(append([]string{}, cities...), extraCities...) {
+		if words := strings.Fields(city); len(words) > 1 {
+			d.cityPhrases = append(d.cityPhrases, words)
+			continue
+		}
+		singleWord = append(singleWord, city)
+	}
+	d.cities = newDictionary(singleWord)
+	return d
+}
+
+func (d *RuleDetector) Name() string {
+	return "rules"
+}
+
+func (d *RuleDetector) Detect(_ context.Context, text string) ([]Entity, error) {
+	var entities []Entity
+	add := func(start, end int, entityType string) {
+		entities = append(entities, Entity{Start: start, End: end, Type: entityType, Source: d.Name()})
+	}
+
+	// Streets: the name and number form one entity
+	for _, match := range streetPattern.FindAllStringSubmatchIndex(text, -1) {
+		add(match[2], match[3], TypeStreet)
+	}
+
+	// Ages: only the number
+	for _, pattern := range agePatterns {
+		for _, match := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			add(match[2], match[3], TypeAge)
+		}
+	}
+
+	// Names and cities from capitalized words
+	words := capitalizedWord.FindAllStringIndex(text, -1)
+	for i, word := range words {
+		value := text[word[0]:word[1]]
+		var next []int
+		if i+1 < len(words) && isSpaceOnly(text[word[1]:words[i+1][0]]) {
+			next = words[i+1]
+		}
+		// Any capitalized word starts a sentence, so there only trust exact
+		// dictionary forms or a word followed by another capitalized one
+		loose := atSentenceStart(text, word[0]) && next == nil
+
+		if end, ok := d.matchCityPhrase(text, words[i:]); ok {
+			add(word[0], end, TypeCity)
+			continue
+		}
+		if found, exact := d.firstNames.contains(value); found && (exact || !loose) {
+			add(word[0], word[1], TypeName)
+			// A capitalized word right after a first name is the surname
+			if next != nil {
+				if isCity, _ := d.cities.contains(text[next[0]:next[1]]); !isCity {
+					add(next[0], next[1], TypeName)
+				}
+			}
+			continue
+		}
+		if found, exact := d.cities.contains(value); found && (exact || !loose) {
+			add(word[0], word[1], TypeCity)
+		}
+	}
+
+	// Surnames after honorifics: "pan Kowalski", "pani Nowak"
+	for _, match := range honorificPattern.FindAllStringSubmatchIndex(text, -1) {
+		if honorific[strings.ToLower(text[match[2]:match[3]])] {
+			add(match[4], match[5], TypeName)
+		}
+	}
+
+	return entities, nil
+}
+
+// matchCityPhrase checks whether the capitalized words starting at words[0]
+// form a multi-word city in any case ("Zielona Góra", "w Zielonej Górze")
+// and returns the end offset of the phrase
+func (d *RuleDetector) matchCityPhrase(text string, words [][]int) (int, bool) {
+	for _, phrase := range d.cityPhrases {
+		if len(words) < len(phrase) {
+			continue
+		}
+		matched := true
+		for k, part := range phrase {
+			if k > 0 && !isSpaceOnly(text[words[k-1][1]:words[k][0]]) {
+				matched = false
+				break
+			}
+			if !strings.EqualFold(text[words[k][0]:words[k][1]], part) && !inflectionOf(text[words[k][0]:words[k][1]], part) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return words[len(phrase)-1][1], true
+		}
+	}
+	return 0, false
+}
+
+func isSpaceOnly(s string) bool {
+	return s != "" && strings.TrimSpace(s) == ""
+}
+
+// atSentenceStart reports whether pos begins the text or follows the end of a sentence
+func atSentenceStart(text string, pos int) bool {
+	before := strings.TrimRightFunc(text[:pos], unicode.IsSpace)
+	if before == "" {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(before)
+	return last == '.' || last == '!' || last == '?' || last == ':'
+}
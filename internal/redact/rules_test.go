@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestInflectionOf(t *testing.T) {
+	tests := []struct {
+		candidate, base string
+		expected        bool
+	}{
+		{"Piotra", "Piotr", true},
+		{"Piotrem", "Piotr", true},
+		{"Piotrze", "Piotr", true},
+		{"Anny", "Anna", true},
+		{"Annę", "Anna", true},
+		{"Marka", "Marek", true},
+		{"Markiem", "Marek", true},
+		{"Pawła", "Paweł", true},
+		{"Marcie", "Marta", true},
+		{"Robercie", "Robert", true},
+		{"Dawidzie", "Dawid", true},
+		{"Łodzi", "Łódź", true},
+		{"Krakowie", "Kraków", true},
+		{"Warszawie", "Warszawa", true},
+		{"Poznaniu", "Poznań", true},
+		{"Katowicach", "Katowice", true},
+		{"Zielonej", "Zielona", true},
+		{"Górze", "Góra", true},
+		{"Marzec", "Marek", false},
+		{"Marcu", "Marta", false},
+		{"Dania", "Daniel", false},
+		{"Lublinie", "Lubin", false},
+		{"Janusz", "Jan", false},
+		{"Adamski", "Adam", false},
+		{"Ewangelia", "Ewa", false},
+	}
+	for _, test := range tests {
+		if got := inflectionOf(test.candidate, test.base); got != test.expected {
+			t.Errorf("inflectionOf(%q, %q) = %v, expected %v", test.candidate, test.base, got, test.expected)
+		}
+	}
+}
+
+func TestRuleDetector(t *testing.T) {
+	detector := NewRuleDetector([]string{"Ignacy"}, []string{"Grudziądz Górny"})
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"first and last name", "Dane osoby: Jan Kowalski.", []string{"name:Jan", "name:Kowalski"}},
+		{"inflected name", "Rozmawiałem z Piotrem Nowakiem.", []string{"name:Piotrem", "name:Nowakiem"}},
+		{"honorific", "Widziałem pana Zielińskiego wczoraj.", []string{"name:Zielińskiego"}},
+		{"extra first name", "Zgłosił się Ignacy Krasicki.", []string{"name:Ignacy", "name:Krasicki"}},
+		{"street with number", "Mieszka przy ul. Długiej 5/12 od lat.", []string{"street:Długiej 5/12"}},
+		{"street without number", "Biuro jest na ulicy Piotrkowskiej.", []string{"street:Piotrkowskiej"}},
+		{"city", "Przebywa w Krakowie.", []string{"city:Krakowie"}},
+		{"multi-word city", "Urodził się w Zielonej Górze.", []string{"city:Zielonej Górze"}},
+		{"extra city", "Wyjechał do Grudziądz Górny.", []string{"city:Grudziądz Górny"}},
+		{"ages", "Ma 34 lata, brat w wieku 40, siostra lat 27.", []string{"age:34", "age:40", "age:27"}},
+		{"month is not a name", "Spotkanie w Marcu Zimnym nie odbyło się.", nil},
+		{"country is not a name", "Wrócił z Danii Północnej wczoraj.", nil},
+		{"similar city is not inflected", "Mieszka w Lubinie, nie w Lublinie.", []string{"city:Lubinie", "city:Lublinie"}},
+		{"brand at sentence start", "Marka samochodu nieznana.", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entities, err := detector.Detect(context.Background(), test.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Slice(entities, func(i, j int) bool { return entities[i].Start < entities[j].Start })
+			var got []string
+			for _, entity := range entities {
+				got = append(got, fmt.Sprintf("%s:%s", entity.Type, test.text[entity.Start:entity.End]))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
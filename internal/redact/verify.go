@@ -0,0 +1,195 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxDiffCells bounds the word alignment table used to report edits
+const maxDiffCells = 20_000_000
+
+// Edit is a change other than replacing a span with the placeholder. Offsets
+// are bytes in the original and the redacted text.
+type Edit struct {
+	OriginalStart int    `json:"original_start"`
+	OriginalEnd   int    `json:"original_end"`
+	RedactedStart int    `json:"redacted_start"`
+	RedactedEnd   int    `json:"redacted_end"`
+	Original      string `json:"original"`
+	Redacted      string `json:"redacted"`
+}
+
+func (e Edit) String() string {
+	return fmt.Sprintf("at %d: %q -> %q", e.OriginalStart, e.Original, e.Redacted)
+}
+
+// Verification is the result of comparing original and redacted text
+type Verification struct {
+	// Valid is true when the redacted text differs only by spans replaced with the placeholder
+	Valid bool
+	// Replaced are the original spans that became placeholders
+	Replaced []Entity
+	// Edits are all other changes; empty when Valid
+	Edits []Edit
+}
+
+// Error describes the edits, or returns nil for a valid redaction
+func (v *Verification) Error() error {
+	if v.Valid {
+		return nil
+	}
+	descriptions := make([]string, len(v.Edits))
+	for i, edit := range v.Edits {
+		descriptions[i] = edit.String()
+	}
+	return fmt.Errorf("redacted text has %d edits besides placeholders: %s", len(v.Edits), strings.Join(descriptions, "; "))
+}
+
+// Verify checks that redacted was produced from original only by replacing
+// contiguous, non-empty spans with the placeholder
+func Verify(original, redacted, placeholder string) *Verification {
+	if placeholder == "" {
+		placeholder = DefaultPlaceholder
+	}
+	if replaced, ok := matchPlaceholders(original, redacted, placeholder); ok {
+		return &Verification{Valid: true, Replaced: replaced}
+	}
+	return &Verification{Edits: diffEdits(original, redacted, placeholder)}
+}
+
+// matchPlaceholders treats the redacted text as a pattern where every
+// placeholder stands for one non-empty span of the original. Literal pieces
+// are matched leftmost-first, which finds a match whenever one exists.
+func matchPlaceholders(original, redacted, placeholder string) ([]Entity, bool) {
+	pieces := strings.Split(redacted, placeholder)
+	first, last := pieces[0], pieces[len(pieces)-1]
+	if len(pieces) == 1 {
+		return nil, original == redacted
+	}
+	if !strings.HasPrefix(original, first) || len(original)-len(last) < len(first) || !strings.HasSuffix(original, last) {
+		return nil, false
+	}
+
+	var replaced []Entity
+	pos, end := len(first), len(original)-len(last)
+	for _, piece := range pieces[1 : len(pieces)-1] {
+		// The span before this piece must not be empty
+		if pos+1 > end {
+			return nil, false
+		}
+		idx := strings.Index(original[pos+1:end], piece)
+		if idx < 0 {
+			return nil, false
+		}
+		start := pos + 1 + idx
+		replaced = append(replaced, Entity{Start: pos, End: start, Text: original[pos:start], Source: "verify"})
+		pos = start + len(piece)
+	}
+	if pos >= end {
+		return nil, false
+	}
+	replaced = append(replaced, Entity{Start: pos, End: end, Text: original[pos:end], Source: "verify"})
+	return replaced, true
+}
+
+// wordPattern finds words, the anchors used to align the texts
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// diffEdits aligns the words of both texts and reports every region between
+// aligned words that is not explained by placeholder replacements
+func diffEdits(original, redacted, placeholder string) []Edit {
+	prefix, suffix := commonEnds(original, redacted)
+	a := wordPattern.FindAllStringIndex(original[prefix:len(original)-suffix], -1)
+	b := wordPattern.FindAllStringIndex(redacted[prefix:len(redacted)-suffix], -1)
+	for _, words := range [][][]int{a, b} {
+		for _, word := range words {
+			word[0] += prefix
+			word[1] += prefix
+		}
+	}
+
+	if len(a)*len(b) > maxDiffCells {
+		return []Edit{newEdit(original, redacted, prefix, len(original)-suffix, prefix, len(redacted)-suffix)}
+	}
+
+	// Longest common subsequence of words, filled from the end
+	same := func(i, j int) bool { return original[a[i][0]:a[i][1]] == redacted[b[j][0]:b[j][1]] }
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if same(i, j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []Edit
+	oPos, rPos := prefix, prefix
+	// region checks the text between two aligned words
+	region := func(oEnd, rEnd int) {
+		if _, ok := matchPlaceholders(original[oPos:oEnd], redacted[rPos:rEnd], placeholder); !ok {
+			p, s := commonEnds(original[oPos:oEnd], redacted[rPos:rEnd])
+			edits = append(edits, newEdit(original, redacted, oPos+p, oEnd-s, rPos+p, rEnd-s))
+		}
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case same(i, j):
+			region(a[i][0], b[j][0])
+			oPos, rPos = a[i][1], b[j][1]
+			i++
+			j++
+		case lcs[i][j+1] >= lcs[i+1][j]:
+			j++
+		default:
+			i++
+		}
+	}
+	region(len(original)-suffix, len(redacted)-suffix)
+	return edits
+}
+
+// commonEnds returns the lengths of the common prefix and suffix of a and b,
+// shortened so that neither cuts through a word
+func commonEnds(a, b string) (prefix, suffix int) {
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for prefix > 0 && isWordByte(a, prefix-1) && (isWordByte(a, prefix) || isWordByte(b, prefix)) {
+		prefix--
+	}
+	for suffix > 0 && isWordByte(a, len(a)-suffix) &&
+		(isWordByte(a, len(a)-suffix-1) || isWordByte(b, len(b)-suffix-1)) {
+		suffix--
+	}
+	return prefix, suffix
+}
+
+func newEdit(original, redacted string, oStart, oEnd, rStart, rEnd int) Edit {
+	return Edit{
+		OriginalStart: oStart,
+		OriginalEnd:   oEnd,
+		RedactedStart: rStart,
+		RedactedEnd:   rEnd,
+		Original:      original[oStart:oEnd],
+		Redacted:      redacted[rStart:rEnd],
+	}
+}
+
+// isWordByte reports whether text[i] is part of a letter or digit (any byte of a multi-byte rune counts)
+func isWordByte(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	c := text[i]
+	return c >= 0x80 || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
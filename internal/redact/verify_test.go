@@ -0,0 +1,116 @@
+package redact
+
+import (
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		redacted string
+		replaced []string
+		edits    [][2]string
+	}{
+		{
+			name:     "placeholders only",
+			original: "Jan Kowalski mieszka w Krakowie przy ul. Długiej 5.",
+			redacted: "CENZURA mieszka w CENZURA przy ul. CENZURA.",
+			replaced: []string{"Jan Kowalski", "Krakowie", "Długiej 5"},
+		},
+		{
+			name:     "placeholders next to each other",
+			original: "Dane: Jan Kowalski, lat 45.",
+			redacted: "Dane: CENZURA CENZURA, lat CENZURA.",
+			replaced: []string{"Jan", "Kowalski", "45"},
+		},
+		{
+			name:     "unchanged text",
+			original: "Nic do ukrycia.",
+			redacted: "Nic do ukrycia.",
+		},
+		{
+			name:     "changed punctuation",
+			original: "Jan Kowalski mieszka w Krakowie.",
+			redacted: "CENZURA mieszka w CENZURA!",
+			edits:    [][2]string{{"Krakowie.", "CENZURA!"}},
+		},
+		{
+			// A placeholder may stand for punctuation next to the word it hides
+			name:     "punctuation inside a replaced span",
+			original: "Osoba: Jan, lat 45.",
+			redacted: "Osoba: CENZURA lat CENZURA.",
+			replaced: []string{"Jan,", "45"},
+		},
+		{
+			name:     "collapsed whitespace",
+			original: "Jan Kowalski mieszka  w Krakowie.",
+			redacted: "CENZURA mieszka w CENZURA.",
+			edits:    [][2]string{{" ", ""}},
+		},
+		{
+			name:     "newline replaced with a space",
+			original: "Jan Kowalski.\nMieszka w Krakowie.",
+			redacted: "CENZURA. Mieszka w CENZURA.",
+			edits:    [][2]string{{"Jan Kowalski.\n", "CENZURA. "}},
+		},
+		{
+			name:     "added word",
+			original: "Jan Kowalski mieszka w Krakowie.",
+			redacted: "CENZURA mieszka teraz w CENZURA.",
+			edits:    [][2]string{{"", "teraz "}},
+		},
+		{
+			name:     "dropped word",
+			original: "Jan Kowalski mieszka obecnie w Krakowie.",
+			redacted: "CENZURA mieszka w CENZURA.",
+			edits:    [][2]string{{"obecnie ", ""}},
+		},
+		{
+			name:     "placeholder for nothing",
+			original: "Jan mieszka w Krakowie.",
+			redacted: "CENZURA mieszka w CENZURA CENZURA.",
+			edits:    [][2]string{{"Krakowie", "CENZURA CENZURA"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := Verify(test.original, test.redacted, "")
+			if v.Valid != (len(test.edits) == 0) {
+				t.Fatalf("expected valid %v, got %+v", len(test.edits) == 0, v)
+			}
+			if v.Valid != (v.Error() == nil) {
+				t.Fatalf("error %v does not match valid %v", v.Error(), v.Valid)
+			}
+			if len(v.Replaced) != len(test.replaced) {
+				t.Fatalf("expected replaced %q, got %+v", test.replaced, v.Replaced)
+			}
+			for i, entity := range v.Replaced {
+				if entity.Text != test.replaced[i] || test.original[entity.Start:entity.End] != entity.Text {
+					t.Errorf("expected replaced %q, got %+v", test.replaced[i], entity)
+				}
+			}
+			if len(v.Edits) != len(test.edits) {
+				t.Fatalf("expected edits %q, got %v", test.edits, v.Edits)
+			}
+			for i, edit := range v.Edits {
+				if edit.Original != test.edits[i][0] || edit.Redacted != test.edits[i][1] {
+					t.Errorf("expected edit %q -> %q, got %v", test.edits[i][0], test.edits[i][1], edit)
+				}
+				if test.original[edit.OriginalStart:edit.OriginalEnd] != edit.Original ||
+					test.redacted[edit.RedactedStart:edit.RedactedEnd] != edit.Redacted {
+					t.Errorf("edit offsets do not match its text: %+v", edit)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyPlaceholder(t *testing.T) {
+	if v := Verify("Jan Kowalski", "[X]", "[X]"); !v.Valid || v.Replaced[0].Text != "Jan Kowalski" {
+		t.Fatalf("expected a valid redaction with a custom placeholder, got %+v", v)
+	}
+	if v := Verify("Jan Kowalski", "[X]", ""); v.Valid {
+		t.Fatalf("expected the default placeholder to reject [X], got %+v", v)
+	}
+}
@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Providers that get their own limiter and stats
+const (
+	ProviderOpenAI   = "openai"
+	ProviderGemini   = "gemini"
+	ProviderCentrala = "centrala"
+	ProviderXYZ      = "xyz"
+)
+
+// Limit is a token bucket: Rate requests per second on average, up to Burst at once
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// DefaultLimits keep well below the free-tier limits of each provider.
+// Providers missing here are not throttled.
+var DefaultLimits = map[string]Limit{
+	ProviderOpenAI:   {Rate: 8, Burst: 8},
+	ProviderGemini:   {Rate: 2, Burst: 4},
+	ProviderCentrala: {Rate: 5, Burst: 5},
+	ProviderXYZ:      {Rate: 5, Burst: 5},
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*rate.Limiter)
+)
+
+// Limiter returns the limiter shared by every goroutine calling the
+// provider, or nil when the provider is not throttled
+func Limiter(provider string) *rate.Limiter {
+	if provider == "" {
+		return nil
+	}
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if limiter, ok := limiters[provider]; ok {
+		return limiter
+	}
+	limit, ok := DefaultLimits[provider]
+	if !ok {
+		return nil
+	}
+	limiter := rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+	limiters[provider] = limiter
+	return limiter
+}
+
+// SetLimit changes the limit of a provider, also for limiters already in use
+func SetLimit(provider string, limit Limit) {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if limiter, ok := limiters[provider]; ok {
+		limiter.SetLimit(rate.Limit(limit.Rate))
+		limiter.SetBurst(limit.Burst)
+		return
+	}
+	limiters[provider] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+}
+
+// ProviderForHost names the provider serving a host, or the host itself
+func ProviderForHost(host string) string {
+	switch {
+	case host == "api.openai.com":
+		return ProviderOpenAI
+	case strings.HasSuffix(host, "generativelanguage.googleapis.com"):
+		return ProviderGemini
+	case host == "centrala.ag3nts.org":
+		return ProviderCentrala
+	case host == "xyz.ag3nts.org":
+		return ProviderXYZ
+	}
+	return host
+}
+
+// wait takes a token, recording how long the caller was throttled
+func wait(ctx context.Context, limiter *rate.Limiter, stats *counters) error {
+	if limiter == nil {
+		return ctx.Err()
+	}
+	start := time.Now()
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+	stats.throttle(time.Since(start))
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package retry retries transient failures of HTTP and SDK calls with
+// exponential backoff and jitter, honours Retry-After and throttles requests
+// with a token bucket per provider.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Policy controls how often and how long a call is retried
+type Policy struct {
+	// MaxAttempts counts the first call, so 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for every further one
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+	// MaxRetryAfter is the longest Retry-After wait that is honoured; a
+	// server asking for more is not retried
+	MaxRetryAfter time.Duration
+}
+
+// DefaultPolicy is used when no policy is given
+var DefaultPolicy = Policy{
+	MaxAttempts:   4,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      30 * time.Second,
+	MaxRetryAfter: 2 * time.Minute,
+}
+
+// Error marks an error as retryable or permanent, overriding Classify
+type Error struct {
+	Err       error
+	Retryable bool
+	// RetryAfter is the wait the server asked for, 0 when it did not say
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps an error that must not be retried
+func Permanent(err error) error {
+	return &Error{Err: err}
+}
+
+// RetryableStatus reports whether an HTTP status is worth retrying: timeouts,
+// rate limits and server errors that are usually transient
+func RetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Classify reports whether err is transient and how long the server asked to
+// wait. Cancellation is permanent; network failures, timeouts and the
+// retryable statuses reported by the OpenAI, Gemini and gRPC clients are transient.
+func Classify(err error) (retryable bool, retryAfter time.Duration) {
+	if err == nil {
+		return false, 0
+	}
+
+	var marked *Error
+	if errors.As(err, &marked) {
+		return marked.Retryable, marked.RetryAfter
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+
+	var openaiAPIErr *openai.APIError
+	if errors.As(err, &openaiAPIErr) {
+		return RetryableStatus(openaiAPIErr.HTTPStatusCode), 0
+	}
+	var openaiRequestErr *openai.RequestError
+	if errors.As(err, &openaiRequestErr) {
+		return RetryableStatus(openaiRequestErr.HTTPStatusCode), 0
+	}
+	var geminiClientErr genai.ClientError
+	if errors.As(err, &geminiClientErr) {
+		return RetryableStatus(geminiClientErr.Code), 0
+	}
+	var geminiServerErr genai.ServerError
+	if errors.As(err, &geminiServerErr) {
+		return RetryableStatus(geminiServerErr.Code), 0
+	}
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+			return true, 0
+		}
+		return false, 0
+	}
+
+	// A deadline of the caller's context is checked by Do; here it can only be
+	// the timeout of a single attempt
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true, 0
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, 0
+	}
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true, 0
+	}
+	return false, 0
+}
+
+// Do calls fn until it succeeds, fails permanently, the attempts run out or
+// ctx is done. Calls are throttled by the provider's limiter and counted in
+// its stats; provider may be empty for no limit.
+func Do(ctx context.Context, provider string, policy *Policy, fn func(ctx context.Context) error) error {
+	if policy == nil {
+		policy = &DefaultPolicy
+	}
+	limiter := Limiter(provider)
+	stats := statsFor(provider)
+
+	for attempt := 1; ; attempt++ {
+		if err := wait(ctx, limiter, stats); err != nil {
+			return err
+		}
+		stats.call()
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		retryable, retryAfter := Classify(err)
+		if !retryable {
+			return err
+		}
+		delay, ok := policy.delay(attempt, retryAfter)
+		if !ok && attempt == 1 {
+			// Retries were disabled or the server asked for too long a wait
+			return err
+		}
+		if !ok {
+			stats.giveUp()
+			log.Error().Err(err).Str("provider", provider).Int("attempts", attempt).Msg("Giving up after retries")
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		stats.retry()
+		log.Warn().
+			Err(err).
+			Str("provider", provider).
+			Int("attempt", attempt).
+			Int("max_attempts", policy.MaxAttempts).
+			Dur("delay", delay).
+			Msg("Retrying call")
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// delay returns the wait before the next attempt, or false when no attempt is left
+func (p *Policy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	if retryAfter > 0 {
+		if retryAfter > p.MaxRetryAfter {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff > p.MaxDelay || backoff <= 0 {
+		backoff = p.MaxDelay
+	}
+	// Equal jitter: at least half the backoff, so parallel callers spread out
+	// without retrying immediately
+	half := backoff / 2
+	return half + rand.N(half+1), true
+}
+
+// RetryAfter parses the Retry-After header, in seconds or as an HTTP date,
+// and OpenAI's retry-after-ms. It returns 0 when the response does not say.
+func RetryAfter(header http.Header) time.Duration {
+	if ms := header.Get("Retry-After-Ms"); ms != "" {
+		if n, err := strconv.ParseFloat(ms, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Millisecond))
+		}
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
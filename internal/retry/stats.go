@@ -0,0 +1,81 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats counts the calls made to one provider
+type Stats struct {
+	Calls   int `json:"calls"`
+	Retries int `json:"retries,omitempty"`
+	// GaveUp counts calls that still failed after the last attempt
+	GaveUp int `json:"gave_up,omitempty"`
+	// Throttled is the total time callers waited for the rate limiter
+	Throttled time.Duration `json:"throttled_ns,omitempty"`
+}
+
+type counters struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+var (
+	statsMu sync.Mutex
+	stats   = make(map[string]*counters)
+)
+
+func statsFor(provider string) *counters {
+	if provider == "" {
+		provider = "other"
+	}
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	c, ok := stats[provider]
+	if !ok {
+		c = &counters{}
+		stats[provider] = c
+	}
+	return c
+}
+
+func (c *counters) call() {
+	c.mu.Lock()
+	c.stats.Calls++
+	c.mu.Unlock()
+}
+
+func (c *counters) retry() {
+	c.mu.Lock()
+	c.stats.Retries++
+	c.mu.Unlock()
+}
+
+func (c *counters) giveUp() {
+	c.mu.Lock()
+	c.stats.GaveUp++
+	c.mu.Unlock()
+}
+
+func (c *counters) throttle(d time.Duration) {
+	// Taking a free token is not throttling
+	if d < time.Millisecond {
+		return
+	}
+	c.mu.Lock()
+	c.stats.Throttled += d
+	c.mu.Unlock()
+}
+
+// Snapshot returns the stats of every provider called so far
+func Snapshot() map[string]Stats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	snapshot := make(map[string]Stats, len(stats))
+	for provider, c := range stats {
+		c.mu.Lock()
+		snapshot[provider] = c.stats
+		c.mu.Unlock()
+	}
+	return snapshot
+}
@@ -0,0 +1,178 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// maxDrain bounds how much of a failed response is read so its connection can be reused
+const maxDrain = 64 << 10
+
+// Transport retries requests that fail with a network error or a retryable
+// status, throttled by the limiter of the provider serving the host. When
+// every attempt fails with a status, the last response is returned as is so
+// callers report it the way they always did. Only requests that are safe to
+// repeat are retried: idempotent methods, and others marked with WithRetries.
+// Requests marked with WithUnprocessedRetries are retried only when the
+// server cannot have acted on them. Requests whose body cannot be replayed
+// (no GetBody) are sent once.
+type Transport struct {
+	Base http.RoundTripper
+	// Policy defaults to DefaultPolicy
+	Policy *Policy
+}
+
+// NewTransport wraps base with retries
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := t.Policy
+	if policy == nil {
+		policy = &DefaultPolicy
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	mode := retryModeOf(req)
+	if !replayable || mode == noRetries {
+		once := *policy
+		once.MaxAttempts = 1
+		policy = &once
+	}
+
+	var last *http.Response
+	attempt := 0
+	err := Do(req.Context(), ProviderForHost(req.URL.Hostname()), policy, func(ctx context.Context) error {
+		attempt++
+		if last != nil {
+			discard(last)
+			last = nil
+		}
+
+		try := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return Permanent(fmt.Errorf("failed to replay request body: %w", err))
+			}
+			try = req.Clone(ctx)
+			try.Body = body
+		}
+
+		resp, err := t.base().RoundTrip(try)
+		if err != nil {
+			if mode == unprocessedRetries && !notConnected(err) {
+				return Permanent(err)
+			}
+			return err
+		}
+		last = resp
+		if RetryableStatus(resp.StatusCode) && (mode == allRetries || rejectedUnprocessed(resp)) {
+			return &Error{
+				Err:        fmt.Errorf("%s %s: %s", req.Method, req.URL.Host, resp.Status),
+				Retryable:  true,
+				RetryAfter: RetryAfter(resp.Header),
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		if last == nil || req.Context().Err() != nil {
+			if last != nil {
+				discard(last)
+			}
+			return nil, err
+		}
+	}
+	return last, nil
+}
+
+// retryMode is how a request may be retried
+type retryMode int
+
+const (
+	noRetries retryMode = iota
+	// unprocessedRetries retries only failures that show the server did not act on the request
+	unprocessedRetries
+	// allRetries retries every transient failure
+	allRetries
+)
+
+type retriesKey struct{}
+
+// WithRetries marks the requests made with ctx as safe to repeat although
+// their method is not idempotent, such as stateless API calls
+func WithRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retriesKey{}, allRetries)
+}
+
+// WithUnprocessedRetries marks the requests made with ctx to be retried only
+// when the server cannot have acted on them: the connection could not be
+// made, or the server refused with 429 and a Retry-After. A timeout or a
+// server error after the request was sent may mean it was processed, so the
+// result is returned as is.
+func WithUnprocessedRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retriesKey{}, unprocessedRetries)
+}
+
+// retryModeOf reports how req may be retried
+func retryModeOf(req *http.Request) retryMode {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return allRetries
+	}
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return allRetries
+	}
+	mode, _ := req.Context().Value(retriesKey{}).(retryMode)
+	return mode
+}
+
+// notConnected reports whether err happened while connecting, before any
+// part of the request was sent
+func notConnected(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// rejectedUnprocessed reports whether the server refused the request without
+// processing it and said when to try again
+func rejectedUnprocessed(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	return resp.Header.Get("Retry-After") != "" || resp.Header.Get("Retry-After-Ms") != ""
+}
+
+// Unprocessed wraps a transport so every request through it is marked with
+// WithUnprocessedRetries. It is for SDK clients, whose POST calls are sent
+// again only when they cannot have reached the model.
+func Unprocessed(base http.RoundTripper) http.RoundTripper {
+	return unprocessedTransport{base: base}
+}
+
+type unprocessedTransport struct {
+	base http.RoundTripper
+}
+
+func (t unprocessedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(WithUnprocessedRetries(req.Context())))
+}
+
+// discard drains and closes a response that will not be returned
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+	resp.Body.Close()
+}
@@ -0,0 +1,137 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransportRetriesOnlyRepeatableRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{
+		Policy: &Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}}
+
+	tests := []struct {
+		name   string
+		method string
+		ctx    context.Context
+		calls  int32
+	}{
+		{"GET", http.MethodGet, context.Background(), 3},
+		{"POST", http.MethodPost, context.Background(), 1},
+		{"POST with retries", http.MethodPost, WithRetries(context.Background()), 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls.Store(0)
+			req, err := http.NewRequestWithContext(test.ctx, test.method, server.URL, bytes.NewBufferString("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != test.calls {
+				t.Fatalf("expected %d calls ending with 503, got %d calls and %s", test.calls, calls.Load(), resp.Status)
+			}
+		})
+	}
+}
+
+func TestTransportRetriesUnprocessedRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After-Ms", "1")
+		}
+		switch r.URL.Path {
+		case "/limited", "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{
+		Policy: &Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxRetryAfter: time.Second},
+	}}
+
+	tests := []struct {
+		path   string
+		status int
+		calls  int32
+	}{
+		// The server may have acted on the request before failing
+		{"/failing", http.StatusServiceUnavailable, 1},
+		{"/busy", http.StatusTooManyRequests, 1},
+		// A rate limit with Retry-After means it was refused unprocessed
+		{"/limited", http.StatusTooManyRequests, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			calls.Store(0)
+			req, err := http.NewRequestWithContext(WithUnprocessedRetries(context.Background()), http.MethodPost, server.URL+test.path, bytes.NewBufferString("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status || calls.Load() != test.calls {
+				t.Fatalf("expected %d calls ending with %d, got %d calls and %s", test.calls, test.status, calls.Load(), resp.Status)
+			}
+		})
+	}
+}
+
+// countingTransport counts the requests it passes on
+type countingTransport struct {
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransportRetriesFailedConnections(t *testing.T) {
+	// A closed listener refuses connections, so nothing is ever sent
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+
+	base := &countingTransport{}
+	client := &http.Client{Transport: &Transport{
+		Base:   base,
+		Policy: &Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}}
+	req, err := http.NewRequestWithContext(WithUnprocessedRetries(context.Background()), http.MethodPost, url, bytes.NewBufferString("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected an error from a closed port")
+	}
+	if base.calls != 3 {
+		t.Fatalf("expected the refused connection to be retried, got %d calls", base.calls)
+	}
+}
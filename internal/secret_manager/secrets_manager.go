@@ -2,12 +2,12 @@ package secret_manager
 
 import (
 	"context"
-	"log"
 	"os"
 	"strings"
 
 	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
 	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
 )
 
 // transformKey converts "OPENAI_API_KEY" to "openai-api-key"
@@ -47,43 +47,45 @@ func readEnvSecrets() map[string]string {
 func Run() {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		log.Fatal().Err(err).Msg("Error loading .env file")
 	}
 
 	// Get project ID from env
 	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
 	if projectID == "" {
-		log.Fatal("GOOGLE_CLOUD_PROJECT not set in .env")
+		log.Fatal().Msg("GOOGLE_CLOUD_PROJECT not set in .env")
 	}
 
 	// The GOOGLE_APPLICATION_CREDENTIALS env var is automatically used by the Google client
 	// as long as it's set in the environment
 
 	// Create secret manager
-	sm, err := utils.NewSecretManager(projectID)
+	ctx := context.Background()
+	sm, err := utils.NewSecretManager(ctx, projectID)
 	if err != nil {
-		log.Fatalf("Failed to create secret manager: %v", err)
+		log.Fatal().Err(err).Msg("Failed to create secret manager")
 	}
+	defer sm.Close()
 
 	// Read secrets from .env file
 	secretKeys := readEnvSecrets()
 
 	// Log found keys
-	log.Printf("Found %d environment variables to process", len(secretKeys))
+	log.Info().Int("count", len(secretKeys)).Msg("Found environment variables to process")
 
 	// Store all secrets
 	for secretID, envKey := range secretKeys {
 		value := os.Getenv(envKey)
 		if value == "" {
-			log.Printf("Warning: %s not set in .env", envKey)
+			log.Warn().Str("envKey", envKey).Msg("Not set in .env")
 			continue
 		}
 
-		err = sm.CreateSecret(context.Background(), secretID, value)
+		err = sm.CreateSecret(ctx, secretID, value)
 		if err != nil {
-			log.Printf("Failed to create secret for %s: %v", secretID, err)
+			log.Error().Err(err).Str("secretID", secretID).Msg("Failed to create secret")
 		} else {
-			log.Printf("Successfully stored %s", secretID)
+			log.Info().Str("secretID", secretID).Msg("Successfully stored secret")
 		}
 	}
 
@@ -91,11 +93,11 @@ func Run() {
 	keysToFetch := []string{"openai-api-key", "gemini-api-key"}
 
 	for _, keyID := range keysToFetch {
-		value, err := sm.GetSecret(context.Background(), keyID)
+		value, err := sm.GetSecret(ctx, keyID)
 		if err != nil {
-			log.Printf("Failed to fetch %s: %v", keyID, err)
+			log.Error().Err(err).Str("keyID", keyID).Msg("Failed to fetch secret")
 			continue
 		}
-		log.Printf("Successfully retrieved %s: %s", keyID, value[:10]+"...") // Only show first 10 chars for security
+		log.Info().Str("keyID", keyID).Int("length", len(value)).Msg("Successfully retrieved secret")
 	}
 }
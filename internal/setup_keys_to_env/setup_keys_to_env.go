@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -55,7 +56,7 @@ func main() {
 	// Only fetch keys if needed
 	if len(keysToFetch) > 0 {
 		// Get API keys
-		keys, err := utils.GetAPIKeys(keysToFetch...)
+		keys, err := utils.GetAPIKeys(context.Background(), keysToFetch...)
 		if err != nil {
 			fmt.Printf("Error getting API keys: %v\n", err)
 			return
@@ -0,0 +1,180 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Centrala fakes centrala.ag3nts.org: /data/<apikey>/<file> serves the files
+// given to File and /report checks answers against the expectations given to
+// Expect, replying with the flag when they match
+type Centrala struct {
+	URL string
+
+	mu      sync.Mutex
+	apiKey  string
+	files   map[string][]byte
+	tasks   map[string]expectation
+	reports []Report
+}
+
+// Report is an answer received by /report
+type Report struct {
+	Task   string
+	APIKey string
+	Answer json.RawMessage
+	// Code is the code Centrala replied with, 0 when the answer was accepted
+	Code int
+}
+
+type expectation struct {
+	check func(answer json.RawMessage) error
+	flag  string
+}
+
+// Codes Centrala replies with when it rejects a report
+const (
+	CodeWrongAnswer = -340
+	CodeUnknownTask = -404
+	CodeBadAPIKey   = -100
+)
+
+// NewCentrala starts a fake Centrala accepting AIDevsKey
+func NewCentrala(t testing.TB) *Centrala {
+	c := &Centrala{
+		apiKey: AIDevsKey,
+		files:  make(map[string][]byte),
+		tasks:  make(map[string]expectation),
+	}
+	c.URL = serve(t, "centrala.ag3nts.org", http.HandlerFunc(c.handle)).URL
+	return c
+}
+
+// SetAPIKey changes the key /data and /report accept
+func (c *Centrala) SetAPIKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiKey = key
+}
+
+// File serves content as /data/<apikey>/<name>
+func (c *Centrala) File(name string, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[name] = content
+}
+
+// Expect accepts an answer to task that is equal to answer as JSON, replying
+// with flag
+func (c *Centrala) Expect(task string, answer any, flag string) {
+	expected, err := json.Marshal(answer)
+	if err != nil {
+		panic(fmt.Sprintf("testkit: expected answer for %s is not JSON: %v", task, err))
+	}
+	c.ExpectFunc(task, func(got json.RawMessage) error {
+		if !jsonEqual(expected, got) {
+			return fmt.Errorf("expected %s, got %s", expected, got)
+		}
+		return nil
+	}, flag)
+}
+
+// ExpectFunc accepts an answer to task that check returns no error for,
+// replying with flag. The error is sent back as the rejection message.
+func (c *Centrala) ExpectFunc(task string, check func(answer json.RawMessage) error, flag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tasks[task] = expectation{check: check, flag: flag}
+}
+
+// Reports returns the answers received so far, accepted or not
+func (c *Centrala) Reports() []Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Report(nil), c.reports...)
+}
+
+func (c *Centrala) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/data/"):
+		c.handleData(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/report":
+		c.handleReport(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (c *Centrala) handleData(w http.ResponseWriter, r *http.Request) {
+	key, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/data/"), "/")
+	c.mu.Lock()
+	apiKey := c.apiKey
+	content, found := c.files[path.Clean(name)]
+	c.mu.Unlock()
+
+	if key != apiKey {
+		http.Error(w, "invalid API key", http.StatusForbidden)
+		return
+	}
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(content)
+}
+
+func (c *Centrala) handleReport(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Task   string          `json:"task"`
+		APIKey string          `json:"apikey"`
+		Answer json.RawMessage `json:"answer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, reply{Code: -1, Message: "invalid JSON: " + err.Error()})
+		return
+	}
+
+	c.mu.Lock()
+	apiKey := c.apiKey
+	expected, known := c.tasks[payload.Task]
+	c.mu.Unlock()
+
+	status, response := http.StatusOK, reply{}
+	switch {
+	case payload.APIKey != apiKey:
+		status, response = http.StatusForbidden, reply{Code: CodeBadAPIKey, Message: "Invalid API key"}
+	case !known:
+		status, response = http.StatusNotFound, reply{Code: CodeUnknownTask, Message: "Unknown task " + payload.Task}
+	default:
+		if err := expected.check(payload.Answer); err != nil {
+			status, response = http.StatusBadRequest, reply{Code: CodeWrongAnswer, Message: "Wrong answer: " + err.Error()}
+		} else {
+			response = reply{Message: expected.flag}
+		}
+	}
+
+	c.mu.Lock()
+	c.reports = append(c.reports, Report{Task: payload.Task, APIKey: payload.APIKey, Answer: payload.Answer, Code: response.Code})
+	c.mu.Unlock()
+	writeJSON(w, status, response)
+}
+
+type reply struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonEqual compares two JSON documents ignoring formatting and key order
+func jsonEqual(a, b []byte) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,139 @@
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Gemini fakes generateContent of generativelanguage.googleapis.com, answered
+// by the function given to OnGenerate
+type Gemini struct {
+	URL string
+
+	mu       sync.Mutex
+	generate func(req GeminiRequest) string
+	requests []GeminiRequest
+}
+
+// GeminiRequest is a generateContent call, flattened
+type GeminiRequest struct {
+	Model  string
+	System string
+	// Text joins the text parts of all contents
+	Text  string
+	Blobs []Blob
+}
+
+// Blob is inline data sent with a request, such as audio
+type Blob struct {
+	MIMEType string
+	Data     []byte
+}
+
+type geminiPart struct {
+	Text       string `json:"text,omitempty"`
+	InlineData *struct {
+		MIMEType string `json:"mimeType"`
+		Data     []byte `json:"data"`
+	} `json:"inlineData,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// NewGemini starts a fake Gemini API accepting GeminiKey. Without a handler
+// generateContent replies with an invalid argument error.
+func NewGemini(t testing.TB) *Gemini {
+	g := &Gemini{}
+	// Not a ServeMux: the SDK asks for //v1beta/..., which a mux would redirect
+	g.URL = serve(t, "generativelanguage.googleapis.com", http.HandlerFunc(g.handle)).URL
+	return g
+}
+
+// OnGenerate answers generateContent with the text reply returns
+func (g *Gemini) OnGenerate(reply func(req GeminiRequest) string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.generate = reply
+}
+
+// Requests returns the generateContent calls received so far
+func (g *Gemini) Requests() []GeminiRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]GeminiRequest(nil), g.requests...)
+}
+
+func (g *Gemini) handle(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimLeft(r.URL.Path, "/")
+	model, found := strings.CutSuffix(path, ":generateContent")
+	if r.Method != http.MethodPost || !found {
+		geminiError(w, http.StatusNotFound, "NOT_FOUND", "testkit: unsupported call "+r.URL.Path)
+		return
+	}
+	model = model[strings.LastIndex(model, "/")+1:]
+	key := r.Header.Get("X-Goog-Api-Key")
+	if key == "" {
+		key = r.URL.Query().Get("key")
+	}
+	if key != GeminiKey {
+		geminiError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "API key not valid")
+		return
+	}
+
+	var body struct {
+		Contents          []geminiContent `json:"contents"`
+		SystemInstruction *geminiContent  `json:"systemInstruction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		geminiError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	req := GeminiRequest{Model: model}
+	var texts []string
+	for _, content := range body.Contents {
+		for _, part := range content.Parts {
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+			if part.InlineData != nil {
+				req.Blobs = append(req.Blobs, Blob{MIMEType: part.InlineData.MIMEType, Data: part.InlineData.Data})
+			}
+		}
+	}
+	req.Text = strings.Join(texts, "\n")
+	if body.SystemInstruction != nil {
+		var system []string
+		for _, part := range body.SystemInstruction.Parts {
+			system = append(system, part.Text)
+		}
+		req.System = strings.Join(system, "\n")
+	}
+
+	g.mu.Lock()
+	g.requests = append(g.requests, req)
+	reply := g.generate
+	g.mu.Unlock()
+	if reply == nil {
+		geminiError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "testkit: no generateContent handler")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"candidates": []map[string]any{{
+			"content":      geminiContent{Role: "model", Parts: []geminiPart{{Text: reply(req)}}},
+			"finishReason": "STOP",
+		}},
+	})
+}
+
+func geminiError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"code": status, "message": message, "status": code},
+	})
+}
@@ -0,0 +1,222 @@
+package testkit
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// embeddingSize is the length of the vectors the fake embeddings endpoint returns
+const embeddingSize = 64
+
+// OpenAI fakes api.openai.com: chat completions answered by the function
+// given to OnChat, audio transcriptions by OnTranscribe, and embeddings that
+// put texts sharing words close together
+type OpenAI struct {
+	URL string
+
+	mu         sync.Mutex
+	chat       func(req openai.ChatCompletionRequest) string
+	transcribe func(name string, audio []byte) string
+	chats      []openai.ChatCompletionRequest
+}
+
+// NewOpenAI starts a fake OpenAI API accepting OpenAIKey. Endpoints without a
+// handler reply with an invalid request error.
+func NewOpenAI(t testing.TB) *OpenAI {
+	o := &OpenAI{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", o.authorized(o.handleChat))
+	mux.HandleFunc("POST /v1/audio/transcriptions", o.authorized(o.handleTranscription))
+	mux.HandleFunc("POST /v1/embeddings", o.authorized(handleEmbeddings))
+	o.URL = serve(t, "api.openai.com", mux).URL
+	return o
+}
+
+// OnChat answers chat completions with the text reply returns
+func (o *OpenAI) OnChat(reply func(req openai.ChatCompletionRequest) string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.chat = reply
+}
+
+// OnTranscribe answers audio transcriptions with the text transcript returns
+func (o *OpenAI) OnTranscribe(transcript func(name string, audio []byte) string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.transcribe = transcript
+}
+
+// Chats returns the chat completion requests received so far
+func (o *OpenAI) Chats() []openai.ChatCompletionRequest {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), o.chats...)
+}
+
+// LastUserMessage returns the text of the last user message of a request
+func LastUserMessage(req openai.ChatCompletionRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		message := req.Messages[i]
+		if message.Role != openai.ChatMessageRoleUser {
+			continue
+		}
+		if message.Content != "" {
+			return message.Content
+		}
+		var parts []string
+		for _, part := range message.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeText {
+				parts = append(parts, part.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+func (o *OpenAI) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+OpenAIKey {
+			openAIError(w, http.StatusUnauthorized, "invalid_api_key", "Incorrect API key provided")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (o *OpenAI) handleChat(w http.ResponseWriter, r *http.Request) {
+	// The JSON schema of a response format cannot be decoded into the
+	// client's type, only the format type is kept
+	var body struct {
+		openai.ChatCompletionRequest
+		ResponseFormat *struct {
+			Type openai.ChatCompletionResponseFormatType `json:"type"`
+		} `json:"response_format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	req := body.ChatCompletionRequest
+	if body.ResponseFormat != nil {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: body.ResponseFormat.Type}
+	}
+	o.mu.Lock()
+	o.chats = append(o.chats, req)
+	reply := o.chat
+	o.mu.Unlock()
+	if reply == nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "testkit: no chat handler")
+		return
+	}
+
+	content := reply(req)
+	promptTokens, completionTokens := 0, len(strings.Fields(content))
+	for _, message := range req.Messages {
+		promptTokens += len(strings.Fields(message.Content))
+	}
+	writeJSON(w, http.StatusOK, openai.ChatCompletionResponse{
+		ID:     "chatcmpl-test",
+		Object: "chat.completion",
+		Model:  req.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+			FinishReason: openai.FinishReasonStop,
+		}},
+		Usage: openai.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	})
+}
+
+func (o *OpenAI) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	o.mu.Lock()
+	transcript := o.transcribe
+	o.mu.Unlock()
+	if transcript == nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "testkit: no transcription handler")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"text": transcript(header.Filename, audio)})
+}
+
+func handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input any    `json:"input"`
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	var inputs []string
+	switch input := req.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []any:
+		for _, item := range input {
+			text, _ := item.(string)
+			inputs = append(inputs, text)
+		}
+	}
+
+	resp := openai.EmbeddingResponse{Object: "list", Model: openai.EmbeddingModel(req.Model)}
+	for i, input := range inputs {
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: Embed(input)})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Embed is the vector the fake returns for text: a normalized bag of its
+// lower-cased words hashed into embeddingSize buckets
+func Embed(text string) []float32 {
+	vector := make([]float32, embeddingSize)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		vector[hash.Sum32()%embeddingSize]++
+	}
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		vector[0] = 1
+		return vector
+	}
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / math.Sqrt(norm))
+	}
+	return vector
+}
+
+func openAIError(w http.ResponseWriter, status int, kind, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"message": message, "type": kind},
+	})
+}
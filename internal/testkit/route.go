@@ -0,0 +1,86 @@
+package testkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+)
+
+// router sends requests for the hosts of running fakes to their servers. It
+// replaces the connection pool of the shared HTTP clients while any fake is
+// running, below the retry transport, so limits and stats still see the
+// original host. Fakes may also be called at their own address; requests for
+// any other host fail the test that installed the routes, so no test reaches
+// a real service.
+type router struct {
+	mu      sync.Mutex
+	routes  map[string]*url.URL
+	t       testing.TB
+	restore func()
+}
+
+var routes = &router{routes: make(map[string]*url.URL)}
+
+// route sends every request for host, e.g. "api.openai.com", to target
+// until the returned function is called; t fails on requests for hosts
+// without a route
+func route(t testing.TB, host string, target *url.URL) (undo func()) {
+	routes.mu.Lock()
+	defer routes.mu.Unlock()
+	if len(routes.routes) == 0 {
+		routes.restore = httpclient.SetBase(routes)
+	}
+	routes.t = t
+	routes.routes[host] = target
+	return func() {
+		routes.mu.Lock()
+		defer routes.mu.Unlock()
+		if routes.routes[host] != target {
+			return
+		}
+		delete(routes.routes, host)
+		if len(routes.routes) == 0 {
+			routes.restore()
+		}
+	}
+}
+
+func (r *router) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	target, ok := r.routes[req.URL.Host]
+	fake := r.isFake(req.URL.Host)
+	t := r.t
+	r.mu.Unlock()
+	if fake {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	if !ok {
+		// Errorf, not Fatalf: the request may run outside the test goroutine
+		t.Errorf("request to %s %s has no fake to answer it", req.Method, req.URL.Redacted())
+		return nil, fmt.Errorf("testkit: no route for host %s", req.URL.Host)
+	}
+
+	routed := req.Clone(req.Context())
+	routed.URL.Scheme, routed.URL.Host = target.Scheme, target.Host
+	routed.Host = ""
+	resp, err := http.DefaultTransport.RoundTrip(routed)
+	if resp != nil {
+		// Callers resolve links and cookies against the address they asked for
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// isFake reports whether host is the address of a running fake; r.mu is held
+func (r *router) isFake(host string) bool {
+	for _, target := range r.routes {
+		if target.Host == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Package testkit fakes the services the tasks talk to, so a command can run
+// end to end in go test. Each fake is an httptest server that the shared HTTP
+// clients reach under the real host name (see httpclient.SetBase), so code
+// under test keeps its production URLs. Routes are global: tests using the
+// fakes must not run in parallel.
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+)
+
+// Keys the fakes accept, returned by the secret chain after SetKeys
+const (
+	AIDevsKey = "test-aidevs-key"
+	OpenAIKey = "test-openai-key"
+	GeminiKey = "test-gemini-key"
+)
+
+// SetKeys makes the secret chain return the test keys until the test ends,
+// so neither the environment nor Secret Manager is asked
+func SetKeys(t testing.TB) {
+	t.Helper()
+	t.Cleanup(utils.OverrideSecrets(map[string]string{
+		"aidevs-api-key": AIDevsKey,
+		"openai-api-key": OpenAIKey,
+		"gemini-api-key": GeminiKey,
+	}))
+}
+
+// Workdir runs the rest of the test in an empty temporary directory, where
+// the commands create downloads/, runs/ and their caches
+func Workdir(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	return dir
+}
+
+// serve starts handler and routes requests for host to it until the test ends
+func serve(t testing.TB, host string, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	undo := route(t, host, target)
+	t.Cleanup(func() {
+		undo()
+		server.Close()
+	})
+	return server
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
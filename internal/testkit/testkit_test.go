@@ -0,0 +1,82 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+)
+
+func TestCentralaReport(t *testing.T) {
+	fake := NewCentrala(t)
+	fake.Expect("TASK", map[string]any{"b": 2, "a": []int{1}}, "{{FLG:TEST}}")
+	client := centrala.NewClient(AIDevsKey)
+
+	tests := []struct {
+		name   string
+		task   string
+		answer any
+		code   int
+	}{
+		{"accepted in any key order", "TASK", map[string]any{"a": []int{1}, "b": 2}, 0},
+		{"wrong answer", "TASK", map[string]any{"a": []int{2}, "b": 2}, CodeWrongAnswer},
+		{"unknown task", "OTHER", "anything", CodeUnknownTask},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := client.Report(context.Background(), test.task, test.answer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != test.code {
+				t.Fatalf("expected code %d, got %d: %s", test.code, resp.Code, resp.Message)
+			}
+			if test.code == 0 && resp.Message != "{{FLG:TEST}}" {
+				t.Fatalf("expected the flag, got %q", resp.Message)
+			}
+		})
+	}
+
+	resp, err := centrala.NewClient("wrong-key").Report(context.Background(), "TASK", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != CodeBadAPIKey {
+		t.Fatalf("expected a wrong key to be refused, got %d", resp.Code)
+	}
+	if reports := fake.Reports(); len(reports) != 4 {
+		t.Fatalf("expected 4 recorded reports, got %d", len(reports))
+	}
+}
+
+// errorRecorder keeps the errors reported through Errorf instead of failing
+type errorRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *errorRecorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestUnroutedHost(t *testing.T) {
+	recorder := &errorRecorder{TB: t}
+	target, err := url.Parse("http://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	undo := route(recorder, "centrala.ag3nts.org", target)
+	defer undo()
+
+	_, err = httpclient.Default.Get("https://api.example.com/data")
+	if err == nil || !strings.Contains(err.Error(), "no route for host api.example.com") {
+		t.Fatalf("expected the request to be refused, got %v", err)
+	}
+	if len(recorder.errors) != 1 || !strings.Contains(recorder.errors[0], "api.example.com") {
+		t.Fatalf("expected the test to fail, got %q", recorder.errors)
+	}
+}
@@ -0,0 +1,219 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/verify"
+)
+
+// XYZ fakes xyz.ag3nts.org: the robot panel login page with its anti-bot
+// question at / and the robot verification dialogue at /verify
+type XYZ struct {
+	URL string
+
+	mu       sync.Mutex
+	username string
+	password string
+	question string
+	answer   int
+	panel    string
+	files    map[string][]byte
+	logins   int
+	robot    []Exchange
+	flag     string
+	msgID    int
+	step     int
+	dialogue []verify.Message
+}
+
+// Exchange is one question of the robot and what an accepted answer contains
+type Exchange struct {
+	Question string
+	// Answer must appear in the reply, case-insensitively
+	Answer string
+}
+
+const sessionCookie = "xyz-session"
+
+// NewXYZ starts a fake robot panel for user "tester" with password "secret".
+// Without SetRobot the dialogue ends with "OK" after READY.
+func NewXYZ(t testing.TB) *XYZ {
+	x := &XYZ{
+		username: "tester",
+		password: "secret",
+		question: "Rok zdobycia Konstantynopola?",
+		answer:   1453,
+		panel:    `<html><body><h1>Robot panel</h1></body></html>`,
+		files:    make(map[string][]byte),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/verify", x.handleVerify)
+	mux.HandleFunc("/panel", x.handlePanel)
+	mux.HandleFunc("/files/{name}", x.handleFile)
+	mux.HandleFunc("/", x.handleLogin)
+	x.URL = serve(t, "xyz.ag3nts.org", mux).URL
+	return x
+}
+
+// Credentials returns the login and password the panel accepts
+func (x *XYZ) Credentials() (username, password string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.username, x.password
+}
+
+// SetCaptcha changes the anti-bot question and its answer
+func (x *XYZ) SetCaptcha(question string, answer int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.question, x.answer = question, answer
+}
+
+// SetPanel changes the HTML shown after a successful login
+func (x *XYZ) SetPanel(page string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.panel = page
+}
+
+// File serves content at /files/<name> to logged-in sessions
+func (x *XYZ) File(name string, content []byte) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.files[name] = content
+}
+
+// Logins returns how many times the login form was submitted
+func (x *XYZ) Logins() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.logins
+}
+
+// SetRobot sets the questions the robot asks after READY and the flag it
+// replies with once all of them were answered
+func (x *XYZ) SetRobot(flag string, exchanges ...Exchange) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.flag, x.robot = flag, exchanges
+}
+
+// Dialogue returns the messages received by /verify
+func (x *XYZ) Dialogue() []verify.Message {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return append([]verify.Message(nil), x.dialogue...)
+}
+
+func (x *XYZ) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	message := ""
+	if r.Method == http.MethodPost {
+		x.logins++
+		switch {
+		case r.PostFormValue("username") != x.username || r.PostFormValue("password") != x.password:
+			message = "Wrong username or password"
+		case r.PostFormValue("answer") != strconv.Itoa(x.answer):
+			message = "Wrong answer"
+		default:
+			http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "logged-in", Path: "/"})
+			http.Redirect(w, r, "/panel", http.StatusFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<html><body>
+<p id="human-question">Question:<br />%s</p>
+<p class="message">%s</p>
+<form method="post" action="/">
+<input type="text" name="username" />
+<input type="password" name="password" />
+<input type="text" name="answer" />
+<button type="submit">Login</button>
+</form>
+</body></html>`, html.EscapeString(x.question), html.EscapeString(message))
+}
+
+func (x *XYZ) handlePanel(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(sessionCookie); err != nil {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, x.panel)
+}
+
+func (x *XYZ) handleFile(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(sessionCookie); err != nil {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	x.mu.Lock()
+	content, ok := x.files[r.PathValue("name")]
+	x.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(content)
+}
+
+// handleVerify plays the robot: READY starts a dialogue under a new msgID,
+// every right answer gets the next question and the last one the flag
+func (x *XYZ) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var message verify.Message
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeJSON(w, http.StatusBadRequest, verify.Message{Text: "ERROR: invalid JSON"})
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.dialogue = append(x.dialogue, message)
+
+	if message.Text == verify.ReadyText {
+		x.msgID++
+		x.step = 0
+		if len(x.robot) == 0 {
+			writeJSON(w, http.StatusOK, verify.Message{MsgID: x.msgID, Text: "OK"})
+			return
+		}
+		writeJSON(w, http.StatusOK, verify.Message{MsgID: x.msgID, Text: x.robot[0].Question})
+		return
+	}
+
+	if message.MsgID != x.msgID || x.step >= len(x.robot) {
+		writeJSON(w, http.StatusOK, verify.Message{MsgID: message.MsgID, Text: "ERROR: unknown conversation"})
+		return
+	}
+	if !strings.Contains(strings.ToLower(message.Text), strings.ToLower(x.robot[x.step].Answer)) {
+		writeJSON(w, http.StatusOK, verify.Message{MsgID: x.msgID, Text: "ALARM! Wrong answer, you are not a robot"})
+		return
+	}
+	x.step++
+	if x.step == len(x.robot) {
+		writeJSON(w, http.StatusOK, verify.Message{MsgID: x.msgID, Text: x.flag})
+		return
+	}
+	writeJSON(w, http.StatusOK, verify.Message{MsgID: x.msgID, Text: x.robot[x.step].Question})
+}
@@ -0,0 +1,153 @@
+package text
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Per-message overhead of the chat format (role, separators), as documented by OpenAI
+const (
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+// SummarizeFunc condenses dropped messages into a short summary
+type SummarizeFunc func(ctx context.Context, dropped []openai.ChatCompletionMessage) (string, error)
+
+// ContextManager keeps a message history within a model's context window
+type ContextManager struct {
+	Model string
+	// Window overrides the model's context size; 0 uses ContextWindow(Model)
+	Window int
+	// ReserveOutput is kept free for the model's reply
+	ReserveOutput int
+	// Summarize, if set, replaces dropped messages with a summary instead of discarding them
+	Summarize SummarizeFunc
+}
+
+// FitReport describes what Fit had to do to make the messages fit
+type FitReport struct {
+	Limit      int
+	Before     int // tokens before fitting
+	After      int // tokens after fitting
+	Dropped    []openai.ChatCompletionMessage
+	Summarized bool
+	// Truncated is the number of tokens cut from the last message when it did not fit on its own
+	Truncated int
+}
+
+// Changed reports whether the messages were modified
+func (r *FitReport) Changed() bool {
+	return len(r.Dropped) > 0 || r.Truncated > 0
+}
+
+// Limit returns the number of prompt tokens available
+func (m *ContextManager) Limit() int {
+	window := m.Window
+	if window <= 0 {
+		window = ContextWindow(m.Model)
+	}
+	return window - m.ReserveOutput
+}
+
+// CountMessages counts the prompt tokens of a chat history
+func (m *ContextManager) CountMessages(messages []openai.ChatCompletionMessage) int {
+	tokenizer := ForModel(m.Model)
+	total := tokensPerReply
+	for _, message := range messages {
+		total += tokensPerMessage + tokenizer.Count(message.Role) + tokenizer.Count(message.Content)
+		for _, part := range message.MultiContent {
+			total += tokenizer.Count(part.Text)
+		}
+	}
+	return total
+}
+
+// Fit returns messages that fit in the context window. System messages and the
+// last message are always kept; the oldest other messages are dropped (or
+// summarized) first. If the kept messages still don't fit, the last message is
+// truncated. The report lists everything that was removed.
+func (m *ContextManager) Fit(ctx context.Context, messages []openai.ChatCompletionMessage) ([]openai.ChatCompletionMessage, *FitReport, error) {
+	limit := m.Limit()
+	report := &FitReport{Limit: limit, Before: m.CountMessages(messages)}
+	if report.Before <= limit || len(messages) == 0 {
+		report.After = report.Before
+		return messages, report, nil
+	}
+
+	last := len(messages) - 1
+	kept := make([]bool, len(messages))
+	for i := range messages {
+		kept[i] = true
+	}
+	build := func(extra []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+		var result []openai.ChatCompletionMessage
+		for i, message := range messages {
+			if !kept[i] {
+				continue
+			}
+			result = append(result, message)
+			// The summary goes right after the leading system messages
+			if extra != nil && message.Role == openai.ChatMessageRoleSystem && (i+1 > last || messages[i+1].Role != openai.ChatMessageRoleSystem) {
+				result = append(result, extra...)
+				extra = nil
+			}
+		}
+		if extra != nil {
+			result = append(extra, result...)
+		}
+		return result
+	}
+
+	// Drop the oldest droppable messages until the rest fits
+	for i := 0; i < last && m.CountMessages(build(nil)) > limit; i++ {
+		if messages[i].Role == openai.ChatMessageRoleSystem {
+			continue
+		}
+		kept[i] = false
+		report.Dropped = append(report.Dropped, messages[i])
+	}
+
+	result := build(nil)
+	if len(report.Dropped) > 0 && m.Summarize != nil {
+		summary, err := m.Summarize(ctx, report.Dropped)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to summarize dropped messages: %w", err)
+		}
+		withSummary := build([]openai.ChatCompletionMessage{{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "Summary of the earlier conversation: " + summary,
+		}})
+		if m.CountMessages(withSummary) <= limit {
+			result = withSummary
+			report.Summarized = true
+		}
+	}
+
+	// Truncate the last message if it is too large on its own
+	if over := m.CountMessages(result) - limit; over > 0 {
+		lastMessage := &result[len(result)-1]
+		available := ForModel(m.Model).Count(lastMessage.Content) - over
+		if available <= 0 {
+			return nil, nil, fmt.Errorf("system messages alone exceed the %d token limit", limit)
+		}
+		segments := Split(lastMessage.Content, SplitOptions{Model: m.Model, ChunkSize: available})
+		report.Truncated = ForModel(m.Model).Count(lastMessage.Content) - segments[0].Tokens
+		lastMessage.Content = segments[0].Text
+	}
+
+	report.After = m.CountMessages(result)
+	log.Warn().
+		Str("model", m.Model).
+		Int("limit", limit).
+		Int("before", report.Before).
+		Int("after", report.After).
+		Int("dropped", len(report.Dropped)).
+		Bool("summarized", report.Summarized).
+		Int("truncated_tokens", report.Truncated).
+		Msg("Prompt trimmed to fit the context window")
+	return result, report, nil
+}
@@ -0,0 +1,96 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func message(role, content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: role, Content: content}
+}
+
+func history() []openai.ChatCompletionMessage {
+	return []openai.ChatCompletionMessage{
+		message(openai.ChatMessageRoleSystem, "You are a robot."),
+		message(openai.ChatMessageRoleUser, strings.Repeat("a", 400)),
+		message(openai.ChatMessageRoleAssistant, strings.Repeat("b", 400)),
+		message(openai.ChatMessageRoleUser, "What is the capital of Poland?"),
+	}
+}
+
+func TestFit(t *testing.T) {
+	messages := history()
+	tests := []struct {
+		name      string
+		window    int
+		summarize SummarizeFunc
+		check     func(t *testing.T, fitted []openai.ChatCompletionMessage, report *FitReport)
+	}{
+		{"fits as is", 1000, nil, func(t *testing.T, fitted []openai.ChatCompletionMessage, report *FitReport) {
+			if report.Changed() || len(fitted) != len(messages) {
+				t.Fatalf("expected no change, got %+v", report)
+			}
+		}},
+		{"drops the oldest messages", 150, nil, func(t *testing.T, fitted []openai.ChatCompletionMessage, report *FitReport) {
+			if len(report.Dropped) != 1 || report.Dropped[0].Content != messages[1].Content {
+				t.Fatalf("expected the first user message to be dropped, got %+v", report.Dropped)
+			}
+			if len(fitted) != 3 || fitted[0].Role != openai.ChatMessageRoleSystem || fitted[2].Content != messages[3].Content {
+				t.Fatalf("expected the system and last messages to be kept, got %+v", fitted)
+			}
+		}},
+		{"summarizes dropped messages", 80, func(_ context.Context, dropped []openai.ChatCompletionMessage) (string, error) {
+			return "user and assistant talked", nil
+		}, func(t *testing.T, fitted []openai.ChatCompletionMessage, report *FitReport) {
+			if !report.Summarized || len(report.Dropped) != 2 {
+				t.Fatalf("expected two messages to be summarized, got %+v", report)
+			}
+			if len(fitted) != 3 || !strings.Contains(fitted[1].Content, "user and assistant talked") || fitted[1].Role != openai.ChatMessageRoleSystem {
+				t.Fatalf("expected the summary after the system message, got %+v", fitted)
+			}
+		}},
+		{"truncates the last message", 20, nil, func(t *testing.T, fitted []openai.ChatCompletionMessage, report *FitReport) {
+			if report.Truncated == 0 || len(fitted) != 2 {
+				t.Fatalf("expected the last message to be truncated, got %+v", report)
+			}
+			if !strings.HasPrefix(messages[3].Content, fitted[1].Content) || fitted[1].Content == messages[3].Content {
+				t.Fatalf("expected a prefix of the last message, got %q", fitted[1].Content)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := &ContextManager{Model: "test-model", Window: test.window, Summarize: test.summarize}
+			fitted, report, err := manager.Fit(context.Background(), history())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.After > manager.Limit() {
+				t.Fatalf("%d tokens left over the %d limit", report.After, manager.Limit())
+			}
+			if report.After != manager.CountMessages(fitted) {
+				t.Fatalf("report counts %d tokens, messages have %d", report.After, manager.CountMessages(fitted))
+			}
+			test.check(t, fitted, report)
+		})
+	}
+}
+
+func TestFitErrors(t *testing.T) {
+	manager := &ContextManager{Model: "test-model", Window: 10}
+	if _, _, err := manager.Fit(context.Background(), history()); err == nil {
+		t.Fatal("expected an error when the system message alone is over the limit")
+	}
+
+	failed := errors.New("summarizer down")
+	manager = &ContextManager{Model: "test-model", Window: 150, Summarize: func(context.Context, []openai.ChatCompletionMessage) (string, error) {
+		return "", failed
+	}}
+	if _, _, err := manager.Fit(context.Background(), history()); !errors.Is(err, failed) {
+		t.Fatalf("expected the summarizer error, got %v", err)
+	}
+}
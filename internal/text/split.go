@@ -0,0 +1,147 @@
+package text
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// SplitOptions controls Split. Sizes are in tokens of the given model.
+type SplitOptions struct {
+	Model     string
+	ChunkSize int
+	Overlap   int
+}
+
+// Segment is a piece of the original text; Text is exactly text[Start:End]
+type Segment struct {
+	Text   string
+	Start  int // byte offsets in the original text
+	End    int
+	Tokens int
+}
+
+var (
+	paragraphEnd = regexp.MustCompile(`\n\s*\n`)
+	// sentenceEnd matches the end of a sentence followed by whitespace
+	sentenceEnd = regexp.MustCompile(`[.!?…]+["')\]]*\s+`)
+)
+
+// span is a piece of text with its byte offsets
+type span struct {
+	start, end int
+}
+
+// splitSpans splits text on the separator pattern, keeping the separator with the preceding piece
+func splitSpans(text string, start, end int, separator *regexp.Regexp) []span {
+	var spans []span
+	pos := start
+	for _, match := range separator.FindAllStringIndex(text[start:end], -1) {
+		cut := start + match[1]
+		if cut > pos {
+			spans = append(spans, span{pos, cut})
+		}
+		pos = cut
+	}
+	if pos < end {
+		spans = append(spans, span{pos, end})
+	}
+	return spans
+}
+
+// Split divides text into segments of at most ChunkSize tokens, preferring
+// paragraph, then sentence, then word boundaries. Consecutive segments share
+// up to Overlap tokens. With no overlap the segments cover the text exactly,
+// so joining their Text restores the original.
+func Split(text string, opts SplitOptions) []Segment {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 500
+	}
+	if opts.Overlap < 0 || opts.Overlap >= opts.ChunkSize {
+		opts.Overlap = 0
+	}
+	tokenizer := ForModel(opts.Model)
+	count := func(s span) int { return tokenizer.Count(text[s.start:s.end]) }
+
+	// Break the text into units that fit on their own
+	var units []span
+	for _, paragraph := range splitSpans(text, 0, len(text), paragraphEnd) {
+		if count(paragraph) <= opts.ChunkSize {
+			units = append(units, paragraph)
+			continue
+		}
+		for _, sentence := range splitSpans(text, paragraph.start, paragraph.end, sentenceEnd) {
+			if count(sentence) <= opts.ChunkSize {
+				units = append(units, sentence)
+				continue
+			}
+			units = append(units, splitWords(text, sentence, opts.ChunkSize, tokenizer)...)
+		}
+	}
+
+	// Greedily pack units into segments, starting each new segment with the tail of the previous one
+	var segments []Segment
+	for i := 0; i < len(units); {
+		start := units[i].start
+		j := i + 1
+		for j < len(units) && count(span{start, units[j].end}) <= opts.ChunkSize {
+			j++
+		}
+		end := units[j-1].end
+
+		segments = append(segments, Segment{
+			Text:   text[start:end],
+			Start:  start,
+			End:    end,
+			Tokens: count(span{start, end}),
+		})
+
+		if j >= len(units) {
+			break
+		}
+		// Step back over units that fit in the overlap, but always make progress
+		next := j
+		for next-1 > i && count(span{units[next-1].start, end}) <= opts.Overlap {
+			next--
+		}
+		i = next
+	}
+	return segments
+}
+
+// splitWords cuts an over-long sentence at whitespace, or at character
+// boundaries when a single word is larger than the limit
+func splitWords(text string, s span, limit int, tokenizer Tokenizer) []span {
+	var spans []span
+	start := s.start
+	for start < s.end {
+		// Binary search the longest prefix that fits
+		lo, hi := start+1, s.end
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if tokenizer.Count(text[start:mid]) <= limit {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		cut := lo
+
+		// Prefer the last whitespace before the cut
+		if cut < s.end {
+			for k := cut; k > start+1; k-- {
+				if text[k-1] == ' ' || text[k-1] == '\n' || text[k-1] == '\t' {
+					cut = k
+					break
+				}
+			}
+		}
+		// Never cut a multi-byte character in half
+		for cut < s.end && cut > start+1 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+
+		spans = append(spans, span{start, cut})
+		start = cut
+	}
+	return spans
+}
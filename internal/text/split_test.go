@@ -0,0 +1,66 @@
+package text
+
+import (
+	"strings"
+	"testing"
+)
+
+var splitTexts = map[string]string{
+	"paragraphs":        "Jan Kowalski mieszka w Krakowie.\n\nPrzy ulicy Długiej 5 pracuje jako programista. Ma 27 lat.\n\nTo wszystko, co wiemy.",
+	"one long sentence": strings.Repeat("słowo ", 200),
+	"one long word":     strings.Repeat("ą", 300) + " koniec.",
+	"sentences":         strings.Repeat("Podejrzany nazywa się Wojciech Górski. Przebywa w Lublinie! Czy ma 30 lat? ", 20),
+	"whitespace":        "\n\n  Tekst z odstępami.  \n\n\n",
+}
+
+func TestSplitWithoutOverlapRebuildsText(t *testing.T) {
+	for name, text := range splitTexts {
+		for _, size := range []int{5, 20, 100, 10000} {
+			segments := Split(text, SplitOptions{Model: "test-model", ChunkSize: size})
+			var rebuilt strings.Builder
+			end := 0
+			for _, segment := range segments {
+				if segment.Start != end || segment.Text != text[segment.Start:segment.End] {
+					t.Fatalf("%s/%d: segment %+v does not continue at %d", name, size, segment, end)
+				}
+				if segment.Tokens > size {
+					t.Fatalf("%s/%d: segment of %d tokens", name, size, segment.Tokens)
+				}
+				rebuilt.WriteString(segment.Text)
+				end = segment.End
+			}
+			if rebuilt.String() != text {
+				t.Fatalf("%s/%d: joined segments differ from the input", name, size)
+			}
+		}
+	}
+}
+
+func TestSplitWithOverlap(t *testing.T) {
+	text := splitTexts["sentences"]
+	segments := Split(text, SplitOptions{Model: "test-model", ChunkSize: 40, Overlap: 15})
+	if len(segments) < 2 {
+		t.Fatalf("expected several segments, got %d", len(segments))
+	}
+	if segments[0].Start != 0 || segments[len(segments)-1].End != len(text) {
+		t.Fatal("expected the segments to cover the whole text")
+	}
+	for i := 1; i < len(segments); i++ {
+		previous, segment := segments[i-1], segments[i]
+		if segment.Start <= previous.Start || segment.Start > previous.End {
+			t.Fatalf("segment %d starts at %d after %d-%d", i, segment.Start, previous.Start, previous.End)
+		}
+		if segment.Start == previous.End {
+			t.Fatalf("segment %d does not overlap the previous one", i)
+		}
+		if segment.Tokens > 40 {
+			t.Fatalf("segment %d has %d tokens", i, segment.Tokens)
+		}
+	}
+}
+
+func TestSplitEmpty(t *testing.T) {
+	if segments := Split("", SplitOptions{Model: "test-model"}); len(segments) != 0 {
+		t.Fatalf("expected no segments, got %+v", segments)
+	}
+}
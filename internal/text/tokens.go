@@ -0,0 +1,183 @@
+package text
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Model families with different tokenizers
+const (
+	FamilyOpenAI = "openai"
+	FamilyGemini = "gemini"
+	FamilyOther  = "other"
+)
+
+// Tokenizer counts tokens the way a model family does
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// contextWindows lists known model context sizes in tokens, matched by prefix (longest first wins)
+var contextWindows = map[string]int{
+	"gpt-4o":           128000,
+	"gpt-4o-mini":      128000,
+	"gpt-4-turbo":      128000,
+	"gpt-4":            8192,
+	"gpt-3.5-turbo":    16385,
+	"o1":               200000,
+	"gemini-1.5-pro":   2097152,
+	"gemini-1.5-flash": 1048576,
+	"gemini-2.0-flash": 1048576,
+	"gemini":           1048576,
+}
+
+const defaultContextWindow = 8192
+
+// Family returns the tokenizer family of a model name
+func Family(model string) string {
+	model = strings.ToLower(model)
+	switch {
+	case strings.HasPrefix(model, "gpt-"), strings.HasPrefix(model, "o1"), strings.HasPrefix(model, "text-embedding"):
+		return FamilyOpenAI
+	case strings.HasPrefix(model, "gemini"):
+		return FamilyGemini
+	}
+	return FamilyOther
+}
+
+// ContextWindow returns the context size of a model in tokens
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+	best, window := "", defaultContextWindow
+	for prefix, size := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best, window = prefix, size
+		}
+	}
+	return window
+}
+
+// CountTokens counts the tokens of text for the given model
+func CountTokens(model, text string) int {
+	return ForModel(model).Count(text)
+}
+
+// ForModel returns the tokenizer for a model. OpenAI models use tiktoken; other
+// families, and OpenAI when the encoding cannot be loaded, use an estimate.
+func ForModel(model string) Tokenizer {
+	if Family(model) != FamilyOpenAI {
+		return estimator{}
+	}
+
+	encoding := tiktoken.MODEL_CL100K_BASE
+	if strings.HasPrefix(model, "gpt-4o") || strings.HasPrefix(model, "o1") {
+		encoding = tiktoken.MODEL_O200K_BASE
+	}
+	if tk := loadEncoding(encoding); tk != nil {
+		return tiktokenCounter{tk}
+	}
+	return estimator{}
+}
+
+var encodings sync.Map // encoding name -> *tiktoken.Tiktoken (nil if it failed to load)
+
+// defaultBPEDir holds the tiktoken BPE files, e.g. o200k_base.tiktoken,
+// unless TIKTOKEN_BPE_DIR points elsewhere
+const defaultBPEDir = "data/tiktoken"
+
+var setLoader sync.Once
+
+// loadEncoding loads a tiktoken encoding once from the local BPE directory.
+// Nothing is downloaded: without the file token counts are estimated.
+func loadEncoding(name string) *tiktoken.Tiktoken {
+	if cached, ok := encodings.Load(name); ok {
+		tk, _ := cached.(*tiktoken.Tiktoken)
+		return tk
+	}
+	setLoader.Do(func() {
+		dir := os.Getenv("TIKTOKEN_BPE_DIR")
+		if dir == "" {
+			dir = defaultBPEDir
+		}
+		tiktoken.SetBpeLoader(localLoader{dir: dir})
+	})
+	tk, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		log.Warn().Err(err).Str("encoding", name).Msg("Tokenizer not available, using estimates")
+		encodings.Store(name, (*tiktoken.Tiktoken)(nil))
+		return nil
+	}
+	encodings.Store(name, tk)
+	return tk
+}
+
+// localLoader reads BPE files from a directory instead of downloading them
+type localLoader struct {
+	dir string
+}
+
+// LoadTiktokenBpe reads the file named like the last element of the
+// encoding's download URL, in the same format: base64 token and rank per line
+func (l localLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	content, err := os.ReadFile(filepath.Join(l.dir, path.Base(url)))
+	if err != nil {
+		return nil, err
+	}
+	ranks := make(map[string]int)
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid BPE line %q", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BPE token %q: %w", token, err)
+		}
+		value, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BPE rank %q: %w", rank, err)
+		}
+		ranks[string(decoded)] = value
+	}
+	return ranks, nil
+}
+
+type tiktokenCounter struct {
+	tk *tiktoken.Tiktoken
+}
+
+func (t tiktokenCounter) Count(text string) int {
+	return len(t.tk.Encode(text, nil, nil))
+}
+
+// estimator approximates token counts without a vocabulary. ASCII text averages
+// about 4 characters per token; Polish diacritics and other non-ASCII characters
+// split words into more tokens, so they are counted more heavily. It errs on the
+// high side so that fitted prompts stay within the window.
+type estimator struct{}
+
+func (estimator) Count(text string) int {
+	var ascii, other int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return int(math.Ceil(float64(ascii)/4 + float64(other)/1.5))
+}
@@ -0,0 +1,49 @@
+package text
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalLoader(t *testing.T) {
+	dir := t.TempDir()
+	// "a" -> 0, "b" -> 1, " c" -> 2
+	os.WriteFile(filepath.Join(dir, "tiny.tiktoken"), []byte("YQ== 0\nYg== 1\nIGM= 2\n"), 0644)
+
+	ranks, err := localLoader{dir: dir}.LoadTiktokenBpe("https://openaipublic.blob.core.windows.net/encodings/tiny.tiktoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != 3 || ranks["a"] != 0 || ranks["b"] != 1 || ranks[" c"] != 2 {
+		t.Fatalf("unexpected ranks %v", ranks)
+	}
+	if _, err := (localLoader{dir: dir}).LoadTiktokenBpe("https://example.com/missing.tiktoken"); err == nil {
+		t.Fatal("expected a missing file to fail instead of being downloaded")
+	}
+
+	os.WriteFile(filepath.Join(dir, "broken.tiktoken"), []byte("YQ==\n"), 0644)
+	if _, err := (localLoader{dir: dir}).LoadTiktokenBpe("broken.tiktoken"); err == nil {
+		t.Fatal("expected a line without a rank to be rejected")
+	}
+}
+
+func TestEstimator(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"żółć", 3},
+	}
+	for _, test := range tests {
+		if got := (estimator{}).Count(test.text); got != test.expected {
+			t.Errorf("%q: expected %d tokens, got %d", test.text, test.expected, got)
+		}
+	}
+	if _, ok := ForModel("gemini-2.0-flash").(estimator); !ok {
+		t.Error("expected Gemini models to be estimated")
+	}
+}
@@ -12,13 +12,23 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/retry"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/genai"
 )
 
+// TranscriptFileName returns the name of the saved transcript of an audio
+// file, e.g. adam.m4a -> adam.txt
+func TranscriptFileName(audioPath string) string {
+	name := filepath.Base(audioPath)
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".txt"
+}
+
 // TranscribeAudioFiles handles the transcription of audio files in the input directory
-// and saves the transcriptions to the output directory.
-func TranscribeAudioFiles(APIKey, inputDir, outputDir, model string) error {
+// and saves the transcriptions to the output directory. Transient API failures are
+// retried by the HTTP client; files that still fail are logged and skipped. Canceling ctx stops the transcription in progress and returns its error.
+func TranscribeAudioFiles(ctx context.Context, APIKey, inputDir, outputDir, model string) error {
 	log.Info().Str("inputDir", inputDir).Str("outputDir", outputDir).Msg("Starting audio transcription")
 
 	files, err := os.ReadDir(inputDir)
@@ -28,10 +38,12 @@ func TranscribeAudioFiles(APIKey, inputDir, outputDir, model string) error {
 
 	for _, file := range files {
 		if !file.IsDir() && (filepath.Ext(file.Name()) == ".mp3" || filepath.Ext(file.Name()) == ".wav" || filepath.Ext(file.Name()) == ".m4a") {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			inputFilePath := filepath.Join(inputDir, file.Name())
-			baseFileName := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
-			outputFileName := baseFileName + ".txt"
-			outputFilePath := filepath.Join(outputDir, outputFileName)
+			outputFilePath := filepath.Join(outputDir, TranscriptFileName(file.Name()))
 
 			// Check if the transcription file already exists
 			if _, err := os.Stat(outputFilePath); err == nil {
@@ -41,28 +53,26 @@ func TranscribeAudioFiles(APIKey, inputDir, outputDir, model string) error {
 
 			log.Info().Str("inputFilePath", inputFilePath).Msg("Transcribing audio file")
 
-			if model == "whisper" {
-				transcript, err := WhisperTranscribeAudio(APIKey, inputFilePath)
-				if err != nil {
-					log.Error().Err(err).Str("inputFilePath", inputFilePath).Msg("Failed to transcribe audio file")
-					continue
-				}
-				err = os.WriteFile(outputFilePath, []byte(transcript), 0644)
-				if err != nil {
-					log.Error().Err(err).Str("outputFilePath", outputFilePath).Msg("Failed to save transcription")
-					continue
-				}
-			} else if model == "gemini" {
-				transcript, err := AudioGemini(APIKey, inputFilePath)
-				if err != nil {
-					log.Error().Err(err).Str("inputFilePath", inputFilePath).Msg("Failed to transcribe audio file")
-					continue
-				}
-				err = os.WriteFile(outputFilePath, []byte(transcript), 0644)
-				if err != nil {
-					log.Error().Err(err).Str("outputFilePath", outputFilePath).Msg("Failed to save transcription")
-					continue
-				}
+			var transcript string
+			switch model {
+			case "whisper":
+				transcript, err = WhisperTranscribeAudio(ctx, APIKey, inputFilePath)
+			case "gemini":
+				transcript, err = AudioGemini(ctx, APIKey, inputFilePath)
+			default:
+				return fmt.Errorf("unknown transcription model '%s'", model)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				log.Error().Err(err).Str("inputFilePath", inputFilePath).Msg("Failed to transcribe audio file")
+				continue
+			}
+			err = os.WriteFile(outputFilePath, []byte(transcript), 0644)
+			if err != nil {
+				log.Error().Err(err).Str("outputFilePath", outputFilePath).Msg("Failed to save transcription")
+				continue
 			}
 			log.Info().Str("inputFilePath", inputFilePath).Str("outputFilePath", outputFilePath).Msg("Transcription saved")
 		}
@@ -70,27 +80,22 @@ func TranscribeAudioFiles(APIKey, inputDir, outputDir, model string) error {
 	return nil
 }
 
-func AudioGemini(geminiKey, audioFilePath string) (string, error) {
+// AudioGemini transcribes an audio file with Gemini
+func AudioGemini(ctx context.Context, geminiKey, audioFilePath string) (string, error) {
 	log.Debug().Str("audioFilePath", audioFilePath).Msg("Calling Gemini API")
 
-	ctx := context.Background()
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  geminiKey,
-		Backend: genai.BackendGoogleAI,
+		APIKey:     geminiKey,
+		Backend:    genai.BackendGoogleAI,
+		HTTPClient: httpclient.SDKTransfer,
 	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create Gemini client")
+		return "", fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 
-	audioFile, err := os.Open(audioFilePath)
+	data, err := os.ReadFile(audioFilePath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to open audio file")
-	}
-	defer audioFile.Close()
-
-	data, err := io.ReadAll(audioFile)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to read audio file")
+		return "", fmt.Errorf("failed to read audio file '%s': %w", audioFilePath, err)
 	}
 
 	parts := []*genai.Part{
@@ -102,14 +107,17 @@ func AudioGemini(geminiKey, audioFilePath string) (string, error) {
 	// Call the GenerateContent method.
 	result, err := client.Models.GenerateContent(ctx, "gemini-2.0-flash-exp", contents, nil)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to generate content")
+		return "", fmt.Errorf("failed to generate content: %w", err)
+	}
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("gemini returned no transcript for '%s'", audioFilePath)
 	}
 
 	return result.Candidates[0].Content.Parts[0].Text, nil
 }
 
 // transcribeAudio calls the OpenAI Whisper API to transcribe the audio file.
-func WhisperTranscribeAudio(openAIKey, audioFilePath string) (string, error) {
+func WhisperTranscribeAudio(ctx context.Context, openAIKey, audioFilePath string) (string, error) {
 	log.Debug().Str("filePath", audioFilePath).Msg("Calling OpenAI Whisper API")
 
 	// Open the audio file
@@ -145,8 +153,8 @@ func WhisperTranscribeAudio(openAIKey, audioFilePath string) (string, error) {
 		return "", fmt.Errorf("failed to close writer: %w", err)
 	}
 
-	// Create the HTTP request
-	req, err := http.NewRequest("POST", url, body)
+	// Create the HTTP request; like SDK calls, it is retried only when it cannot have reached the API
+	req, err := http.NewRequestWithContext(retry.WithUnprocessedRetries(ctx), http.MethodPost, url, body)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -154,8 +162,7 @@ func WhisperTranscribeAudio(openAIKey, audioFilePath string) (string, error) {
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", openAIKey))
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpclient.Transfer.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request to OpenAI API: %w", err)
 	}
@@ -1,9 +1,8 @@
 package utils
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +13,10 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/dawidjelenkowski/aidevs3go/internal/archive"
+	"github.com/dawidjelenkowski/aidevs3go/internal/centrala"
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/logging"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 )
@@ -24,11 +27,8 @@ type SecretManager struct {
 	client    *secretmanager.Client
 }
 
-// NewSecretManager creates a new SecretManager instance
-func NewSecretManager(projectID string) (*SecretManager, error) {
-	// Create a background context for the client
-	ctx := context.Background()
-
+// NewSecretManager creates a new SecretManager instance; Close releases it
+func NewSecretManager(ctx context.Context, projectID string) (*SecretManager, error) {
 	// Attempt to create a new Secret Manager client
 	client, err := secretmanager.NewClient(ctx)
 	if err != nil {
@@ -42,6 +42,11 @@ func NewSecretManager(projectID string) (*SecretManager, error) {
 	}, nil
 }
 
+// Close closes the connection to Secret Manager
+func (sm *SecretManager) Close() error {
+	return sm.client.Close()
+}
+
 // CreateSecret creates a new secret in Secret Manager
 func (sm *SecretManager) CreateSecret(ctx context.Context, secretID, secretValue string) error {
 	// Create the secret request
@@ -96,8 +101,8 @@ func (sm *SecretManager) GetSecret(ctx context.Context, secretID string) (string
 	return string(result.Payload.Data), nil
 }
 
-// GetAPIKey retrieves an API key from Secret Manager by its name
-func GetAPIKey(keyName string) (string, error) {
+// resolveProjectID returns the GCP project ID from .env or the gcloud CLI
+func resolveProjectID(ctx context.Context) (string, error) {
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
 		log.Warn().Err(err).Msg("Error loading .env file")
@@ -111,38 +116,113 @@ func GetAPIKey(keyName string) (string, error) {
 
 	// If not found in env, try to get it from gcloud CLI
 	if projectID == "" {
-		out, err := exec.Command("gcloud", "config", "get-value", "project").Output()
+		out, err := exec.CommandContext(ctx, "gcloud", "config", "get-value", "project").Output()
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to get project ID from gcloud CLI")
 		}
 		projectID = strings.TrimSpace(string(out))
 		if projectID == "" {
-			log.Fatal().Msg("Project ID not found in environment or gcloud config")
+			return "", fmt.Errorf("project ID not found in environment or gcloud config")
 		}
 	}
+	return projectID, nil
+}
+
+// envKeyName converts "zip-password" to "ZIP_PASSWORD", the reverse of the
+// naming used when secrets are uploaded from .env
+func envKeyName(keyName string) string {
+	return strings.ToUpper(strings.ReplaceAll(keyName, "-", "_"))
+}
+
+// LookupAPIKey resolves an optional secret through the secret chain: environment
+// variables (and .env) first, then Secret Manager. Unlike GetAPIKey it never exits
+// and reports false when the secret is not available anywhere.
+func LookupAPIKey(ctx context.Context, keyName string) (string, bool) {
+	if secretOverrides != nil {
+		value, ok := secretOverrides[keyName]
+		return value, ok
+	}
+	if err := godotenv.Load(); err != nil {
+		log.Debug().Err(err).Msg("Error loading .env file")
+	}
+	if value := os.Getenv(envKeyName(keyName)); value != "" {
+		logging.RegisterSecret(value)
+		log.Info().Str("keyName", keyName).Msg("Using API key from environment")
+		return value, true
+	}
+
+	projectID, err := resolveProjectID(ctx)
+	if err != nil {
+		log.Warn().Err(err).Str("keyName", keyName).Msg("Secret Manager unavailable")
+		return "", false
+	}
+	sm, err := NewSecretManager(ctx, projectID)
+	if err != nil {
+		log.Warn().Err(err).Str("keyName", keyName).Msg("Secret Manager unavailable")
+		return "", false
+	}
+	defer sm.Close()
+	value, err := sm.GetSecret(ctx, keyName)
+	if err != nil {
+		log.Warn().Err(err).Str("keyName", keyName).Msg("Secret not found")
+		return "", false
+	}
+	logging.RegisterSecret(value)
+	log.Info().Str("keyName", keyName).Msg("Successfully retrieved API key")
+	return value, true
+}
+
+// secretOverrides replace the whole secret chain when set, see OverrideSecrets
+var secretOverrides map[string]string
+
+// OverrideSecrets makes GetAPIKey and LookupAPIKey answer only from secrets
+// until restore is called. It is a seam for tests, which must never reach
+// Secret Manager; it must not be called while secrets are being resolved.
+func OverrideSecrets(secrets map[string]string) (restore func()) {
+	previous := secretOverrides
+	secretOverrides = secrets
+	return func() { secretOverrides = previous }
+}
+
+// GetAPIKey retrieves an API key from Secret Manager by its name
+func GetAPIKey(ctx context.Context, keyName string) (string, error) {
+	if secretOverrides != nil {
+		value, ok := secretOverrides[keyName]
+		if !ok {
+			return "", fmt.Errorf("secret '%s' is not overridden", keyName)
+		}
+		return value, nil
+	}
+
+	projectID, err := resolveProjectID(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Project ID not found in environment or gcloud config")
+	}
 
 	// Initialize Secret Manager with the fetched project ID
-	sm, err := NewSecretManager(projectID)
+	sm, err := NewSecretManager(ctx, projectID)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create secret manager")
 	}
+	defer sm.Close()
 
 	// Get API key from Secret Manager
-	apiKey, err := sm.GetSecret(context.Background(), keyName)
+	apiKey, err := sm.GetSecret(ctx, keyName)
 	if err != nil {
 		log.Fatal().Err(err).Str("keyName", keyName).Msg("Failed to get API key from Secret Manager")
 	}
+	logging.RegisterSecret(apiKey)
 	log.Info().Str("keyName", keyName).Msg("Successfully retrieved API key")
 	return apiKey, nil
 }
 
 // GetAPIKeys fetches multiple API keys at once
-func GetAPIKeys(keyNames ...string) (map[string]string, error) {
+func GetAPIKeys(ctx context.Context, keyNames ...string) (map[string]string, error) {
 	keys := make(map[string]string)
 	var errors []string
 
 	for _, keyName := range keyNames {
-		key, err := GetAPIKey(keyName)
+		key, err := GetAPIKey(ctx, keyName)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", keyName, err))
 			continue
@@ -158,7 +238,8 @@ func GetAPIKeys(keyNames ...string) (map[string]string, error) {
 }
 
 // DownloadFiles downloads files from a constructed URL based on the provided API key and filenames.
-func DownloadFiles(apiKey string, downloadPath string, fileNames []string) error {
+// Canceling ctx stops the download in progress and leaves no partial file behind.
+func DownloadFiles(ctx context.Context, apiKey string, downloadPath string, fileNames []string) error {
 	// Create downloads directory if it doesn't exist
 	if err := os.MkdirAll(downloadPath, 0755); err != nil {
 		return fmt.Errorf("failed to create downloads directory: %v", err)
@@ -174,102 +255,108 @@ func DownloadFiles(apiKey string, downloadPath string, fileNames []string) error
 		}
 
 		// Construct URL with API key
-		url := fmt.Sprintf("https://centrala.ag3nts.org/data/%s/%s", apiKey, fileName)
+		url := fmt.Sprintf("%s/data/%s/%s", centrala.DefaultBaseURL, apiKey, fileName)
 		log.Debug().Str("url", url).Str("fileName", fileName).Msg("Downloading file")
 
-		// Download the file
-		resp, err := http.Get(url)
-		if err != nil {
-			return fmt.Errorf("failed to download file %s: %v", fileName, err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("failed to download file %s, status code: %d", fileName, resp.StatusCode)
+		if err := DownloadFile(ctx, httpclient.Transfer, url, filePath); err != nil {
+			return fmt.Errorf("failed to download file %s: %w", fileName, err)
 		}
+		log.Info().Str("fileName", fileName).Msg("File downloaded successfully")
+	}
 
-		// Create the file
-		file, err := os.Create(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %v", fileName, err)
+	// Unpack any downloaded zip packages next to them
+	for _, fileName := range fileNames {
+		filePath := filepath.Join(downloadPath, fileName)
+		if !archive.IsZip(filePath) {
+			continue
 		}
-		defer file.Close()
-
-		// Copy the response body to the file
-		_, err = io.Copy(file, resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to write file %s: %v", fileName, err)
+		if err := ExtractArchive(ctx, filePath); err != nil {
+			return err
 		}
-
-		log.Info().Str("fileName", fileName).Msg("File downloaded successfully")
 	}
 
 	return nil
 }
 
-// Function to read the contents of a file
-func ReadFile(filePath string) (string, error) {
-	data, err := os.ReadFile(filePath)
+// DownloadFile writes the body of url to a .part file that is renamed to
+// filePath once complete, so an interrupted download is not mistaken for a
+// finished one on the next run
+func DownloadFile(ctx context.Context, client *http.Client, url, filePath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("error reading the file: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
-	return string(data), nil // Return the contents as a string
-}
-
-// Function to send the answer to the API
-func SendAnswer(content string, task string) error {
-	url := "https://centrala.ag3nts.org/report"
-
-	aidevsKey, err := GetAPIKey("aidevs-api-key")
+	resp, err := client.Do(req)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to get AIDevs API key")
+		return err
 	}
-	// Create the payload
-	payload := map[string]string{
-		"task":   task,
-		"apikey": aidevsKey,
-		"answer": content,
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
 	}
 
-	// Convert payload to JSON
-	jsonData, err := json.Marshal(payload)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	partPath := filePath + ".part"
+	file, err := os.Create(partPath)
 	if err != nil {
-		return fmt.Errorf("error marshaling payload: %w", err)
+		return fmt.Errorf("failed to create file: %w", err)
 	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(partPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return os.Rename(partPath, filePath)
+}
 
-	log.Debug().
-		Str("url", url).
-		Str("task", payload["task"]).
-		Int("content_length", len(content)).
-		// RawJSON("payload", jsonData).
-		Msg("Sending answer to API")
-
-	// Create the request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// ExtractArchive unpacks a zip file into a directory named after it, e.g.
+// downloads/pack.zip -> downloads/pack/. The password for encrypted entries
+// is looked up as "zip-password" (ZIP_PASSWORD) only when it is needed.
+func ExtractArchive(ctx context.Context, zipPath string) error {
+	destDir := strings.TrimSuffix(zipPath, filepath.Ext(zipPath))
+
+	_, err := archive.Extract(zipPath, destDir, archive.Options{})
+	if errors.Is(err, archive.ErrPasswordRequired) {
+		password, ok := LookupAPIKey(ctx, "zip-password")
+		if !ok {
+			return fmt.Errorf("archive %s is password protected and zip-password is not set", zipPath)
+		}
+		_, err = archive.Extract(zipPath, destDir, archive.Options{Password: password})
+	}
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return fmt.Errorf("failed to extract archive %s: %w", zipPath, err)
 	}
+	return nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to read the contents of a file
+func ReadFile(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		return "", fmt.Errorf("error reading the file: %w", err)
 	}
-	defer resp.Body.Close()
+	return string(data), nil // Return the contents as a string
+}
 
-	// Read and print the response
-	body, err := io.ReadAll(resp.Body)
+// SendAnswer submits a text answer to Centrala, logging its reply.
+//
+// Deprecated: use centrala.Client.Report, which returns the reply.
+func SendAnswer(ctx context.Context, content string, task string) error {
+	aidevsKey, err := GetAPIKey(ctx, "aidevs-api-key")
 	if err != nil {
-		return fmt.Errorf("error reading response: %w", err)
+		log.Fatal().Err(err).Msg("Failed to get AIDevs API key")
 	}
 
-	log.Info().
-		RawJSON("response", body).
-		Int("response_length", len(body)).
-		Msg("Successfully processed API response")
-
+	client := centrala.NewClient(aidevsKey)
+	if _, err := client.Report(ctx, task, content); err != nil {
+		return err
+	}
 	return nil
 }
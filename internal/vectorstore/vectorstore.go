@@ -0,0 +1,239 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Record is a single indexed text with its embedding
+type Record struct {
+	ID         string            `json:"id"`
+	DocumentID string            `json:"document_id"`
+	Text       string            `json:"text"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Vector     []float32         `json:"vector"`
+}
+
+// Result is a search hit with its cosine similarity to the query
+type Result struct {
+	Record
+	Score float64 `json:"score"`
+}
+
+// Filter restricts search results to records whose metadata matches every key exactly
+type Filter map[string]string
+
+func (f Filter) matches(record *Record) bool {
+	for key, value := range f {
+		if record.Metadata[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Store is a file-backed vector index kept fully in memory
+type Store struct {
+	path string
+
+	mu         sync.RWMutex
+	model      string
+	dimensions int
+	records    map[string]*Record
+}
+
+// storeFile is the on-disk format of the index
+type storeFile struct {
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	Records    []*Record `json:"records"`
+}
+
+// Open loads the index from path, or creates an empty one if the file does not exist yet
+func Open(path string) (*Store, error) {
+	store := &Store{
+		path:    path,
+		records: make(map[string]*Record),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index '%s': %w", path, err)
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse index '%s': %w", path, err)
+	}
+	store.model = file.Model
+	store.dimensions = file.Dimensions
+	for _, record := range file.Records {
+		store.records[record.ID] = record
+	}
+	return store, nil
+}
+
+// Model returns the embedding model the index was built with
+func (s *Store) Model() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.model
+}
+
+// Len returns the number of records in the index
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// CheckModel makes sure vectors from a different embedding model are not mixed into the index
+func (s *Store) CheckModel(model string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.model == "" {
+		s.model = model
+		return nil
+	}
+	if s.model != model {
+		return fmt.Errorf("index was built with '%s', cannot use '%s'", s.model, model)
+	}
+	return nil
+}
+
+// Upsert inserts records or replaces existing ones with the same ID
+func (s *Store) Upsert(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range records {
+		record := records[i]
+		if record.ID == "" {
+			return fmt.Errorf("record without ID")
+		}
+		if s.dimensions == 0 {
+			s.dimensions = len(record.Vector)
+		}
+		if len(record.Vector) != s.dimensions {
+			return fmt.Errorf("record '%s' has %d dimensions, index has %d", record.ID, len(record.Vector), s.dimensions)
+		}
+		s.records[record.ID] = &record
+	}
+	return nil
+}
+
+// Delete removes records by ID and returns how many were removed
+func (s *Store) Delete(ids ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, id := range ids {
+		if _, ok := s.records[id]; ok {
+			delete(s.records, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// DeleteDocument removes every record that belongs to the document
+func (s *Store) DeleteDocument(documentID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, record := range s.records {
+		if record.DocumentID == documentID {
+			delete(s.records, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Search returns the k records most similar to the query vector that match the filter
+func (s *Store) Search(query []float32, k int, filter Filter) []Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []Result
+	for _, record := range s.records {
+		if !filter.matches(record) {
+			continue
+		}
+		results = append(results, Result{Record: *record, Score: CosineSimilarity(query, record.Vector)})
+	}
+
+	// Sort by score, ties by ID so the output is stable
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Save writes the index to disk atomically (temp file + rename)
+func (s *Store) Save() error {
+	s.mu.RLock()
+	file := storeFile{
+		Model:      s.model,
+		Dimensions: s.dimensions,
+		Records:    make([]*Record, 0, len(s.records)),
+	}
+	for _, record := range s.records {
+		file.Records = append(file.Records, record)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(file.Records, func(i, j int) bool { return file.Records[i].ID < file.Records[j].ID })
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace index: %w", err)
+	}
+	return nil
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,133 @@
+package vectorstore
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []float32
+		expected float64
+	}{
+		{"same direction", []float32{1, 2}, []float32{2, 4}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 3}, 0},
+		{"opposite", []float32{1, 1}, []float32{-1, -1}, -1},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+		{"different lengths", []float32{1, 0}, []float32{1, 0, 0}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CosineSimilarity(test.a, test.b); math.Abs(got-test.expected) > 1e-9 {
+				t.Fatalf("expected %g, got %g", test.expected, got)
+			}
+		})
+	}
+}
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "index", "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Upsert(
+		Record{ID: "a", DocumentID: "doc-1", Text: "east", Vector: []float32{1, 0}, Metadata: map[string]string{"kind": "text"}},
+		Record{ID: "b", DocumentID: "doc-1", Text: "north-east", Vector: []float32{1, 1}, Metadata: map[string]string{"kind": "audio"}},
+		Record{ID: "c", DocumentID: "doc-2", Text: "north", Vector: []float32{0, 1}, Metadata: map[string]string{"kind": "text"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func ids(results []Result) []string {
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	store := testStore(t)
+	tests := []struct {
+		name     string
+		query    []float32
+		k        int
+		filter   Filter
+		expected []string
+	}{
+		{"ranked by cosine", []float32{1, 0.1}, 0, nil, []string{"a", "b", "c"}},
+		{"top k", []float32{0.1, 1}, 2, nil, []string{"c", "b"}},
+		{"filtered", []float32{1, 1}, 0, Filter{"kind": "text"}, []string{"a", "c"}},
+		{"nothing matches", []float32{1, 0}, 0, Filter{"kind": "image"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ids(store.Search(test.query, test.k, test.filter))
+			if len(got) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+			for i := range got {
+				if got[i] != test.expected[i] {
+					t.Fatalf("expected %v, got %v", test.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	store := testStore(t)
+	if err := store.Upsert(Record{ID: "a", DocumentID: "doc-1", Text: "west", Vector: []float32{-1, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 3 {
+		t.Fatalf("expected the record to be replaced, got %d records", store.Len())
+	}
+	if results := store.Search([]float32{-1, 0}, 1, nil); results[0].ID != "a" || results[0].Text != "west" {
+		t.Fatalf("expected the replaced record, got %+v", results[0])
+	}
+
+	if err := store.Upsert(Record{ID: "d", Vector: []float32{1, 2, 3}}); err == nil {
+		t.Fatal("expected a vector with other dimensions to be rejected")
+	}
+	if err := store.Upsert(Record{Vector: []float32{1, 2}}); err == nil {
+		t.Fatal("expected a record without ID to be rejected")
+	}
+	if removed := store.DeleteDocument("doc-1"); removed != 2 || store.Len() != 1 {
+		t.Fatalf("expected 2 records of doc-1 to be removed, got %d", removed)
+	}
+}
+
+func TestSaveAndOpen(t *testing.T) {
+	store := testStore(t)
+	if err := store.CheckModel("hash/2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 3 || reopened.Model() != "hash/2" {
+		t.Fatalf("unexpected reopened index: %d records, model %q", reopened.Len(), reopened.Model())
+	}
+	results := reopened.Search([]float32{0, 1}, 1, Filter{"kind": "text"})
+	if len(results) != 1 || results[0].ID != "c" || results[0].DocumentID != "doc-2" || results[0].Text != "north" {
+		t.Fatalf("unexpected result after reopening %+v", results)
+	}
+	if err := reopened.CheckModel("openai/text-embedding-3-small"); err == nil {
+		t.Fatal("expected vectors of another model to be rejected")
+	}
+	if err := reopened.Upsert(Record{ID: "d", Vector: []float32{1, 2, 3}}); err == nil {
+		t.Fatal("expected the saved dimensions to be enforced")
+	}
+}
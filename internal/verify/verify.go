@@ -0,0 +1,259 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultURL = "https://xyz.ag3nts.org/verify"
+
+	// ReadyText opens the conversation
+	ReadyText = "READY"
+
+	defaultMaxTurns = 10
+	// answerAttempts is how many times a non-English answer is regenerated
+	answerAttempts = 3
+)
+
+var (
+	// ErrFailed is returned when the robot rejects an answer
+	ErrFailed = errors.New("verification failed")
+	// ErrNotEnglish is returned when the responder keeps answering in another language
+	ErrNotEnglish = errors.New("answer is not in English")
+
+	flagPattern = regexp.MustCompile(`\{\{FLG:[^}]*\}\}`)
+	// Replies that end the conversation with a failure
+	errorPattern = regexp.MustCompile(`(?i)^\s*(error|fail|wrong|invalid|incorrect|alarm)\b|\bERROR\b`)
+
+	// Letters that only occur in Polish words
+	polishLetters = "ąćęłńóśźż"
+	// Polish words that give away a non-English answer even without diacritics
+	polishWords = map[string]bool{"jest": true, "nie": true, "tak": true, "się": true, "oraz": true, "czy": true, "jak": true, "rok": true, "stolica": true}
+)
+
+// Message is the payload exchanged with the robot in both directions
+type Message struct {
+	MsgID int    `json:"msgID"`
+	Text  string `json:"text"`
+}
+
+// Turn is one message of the dialogue
+type Turn struct {
+	Time    time.Time `json:"time"`
+	Sent    bool      `json:"sent"`
+	Message Message   `json:"message"`
+}
+
+// Outcome is the result of a finished conversation
+type Outcome struct {
+	Success bool
+	Flag    string
+	// Final is the last message from the robot
+	Final Message
+	Turns []Turn
+}
+
+// Responder answers a question from the robot. Feedback is empty on the first
+// attempt and explains what was wrong with a rejected answer otherwise.
+type Responder func(ctx context.Context, question, feedback string) (string, error)
+
+// Client runs the robot verification protocol
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+	// MaxTurns limits how many questions are answered before giving up
+	MaxTurns int
+	// TranscriptPath, if set, receives the dialogue as it happens
+	TranscriptPath string
+}
+
+// NewClient creates a client for the default verification endpoint
+func NewClient() *Client {
+	return &Client{
+		URL:        DefaultURL,
+		HTTPClient: httpclient.New(30 * time.Second),
+		MaxTurns:   defaultMaxTurns,
+	}
+}
+
+// Run opens the conversation with READY and answers questions until the robot
+// signals success or failure. Every reply must carry the msgID of the
+// conversation, and every answer is sent with it. The outcome is returned
+// even on error so the dialogue so far can be inspected.
+func (c *Client) Run(ctx context.Context, respond Responder) (*Outcome, error) {
+	outcome := &Outcome{}
+	transcript, err := c.openTranscript()
+	if err != nil {
+		return outcome, err
+	}
+	var w io.Writer
+	if transcript != nil {
+		defer transcript.Close()
+		w = transcript
+	}
+
+	record := func(sent bool, message Message) {
+		turn := Turn{Time: time.Now(), Sent: sent, Message: message}
+		outcome.Turns = append(outcome.Turns, turn)
+		writeTurn(w, turn)
+	}
+
+	maxTurns := c.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = defaultMaxTurns
+	}
+
+	outgoing := Message{MsgID: 0, Text: ReadyText}
+	for turn := 0; turn <= maxTurns; turn++ {
+		record(true, outgoing)
+		reply, err := c.send(ctx, outgoing)
+		if err != nil {
+			return outcome, err
+		}
+		record(false, *reply)
+		outcome.Final = *reply
+
+		// The robot assigns the msgID in its first reply; afterwards it must stay the same
+		if turn > 0 && reply.MsgID != outgoing.MsgID {
+			return outcome, fmt.Errorf("robot replied with msgID %d, expected %d", reply.MsgID, outgoing.MsgID)
+		}
+
+		if flag := flagPattern.FindString(reply.Text); flag != "" {
+			outcome.Success, outcome.Flag = true, flag
+			log.Info().Str("flag", flag).Int("msg_id", reply.MsgID).Msg("Robot returned a flag")
+			return outcome, nil
+		}
+		if turn > 0 && strings.EqualFold(strings.TrimSpace(reply.Text), "OK") {
+			outcome.Success = true
+			log.Info().Int("msg_id", reply.MsgID).Msg("Robot accepted the verification")
+			return outcome, nil
+		}
+		if errorPattern.MatchString(reply.Text) {
+			return outcome, fmt.Errorf("%w: %s", ErrFailed, reply.Text)
+		}
+
+		answer, err := c.answer(ctx, respond, reply.Text)
+		if err != nil {
+			return outcome, err
+		}
+		outgoing = Message{MsgID: reply.MsgID, Text: answer}
+	}
+	return outcome, fmt.Errorf("conversation did not finish within %d turns", maxTurns)
+}
+
+// answer asks the responder until it produces an English answer
+func (c *Client) answer(ctx context.Context, respond Responder, question string) (string, error) {
+	feedback := ""
+	for attempt := 1; attempt <= answerAttempts; attempt++ {
+		answer, err := respond(ctx, question, feedback)
+		if err != nil {
+			return "", fmt.Errorf("failed to answer '%s': %w", question, err)
+		}
+		answer = strings.TrimSpace(answer)
+		if IsEnglish(answer) {
+			return answer, nil
+		}
+		log.Warn().Str("answer", answer).Int("attempt", attempt).Msg("Answer is not in English, asking again")
+		feedback = fmt.Sprintf("Your previous answer '%s' was not in English. Answer in English only.", answer)
+	}
+	return "", fmt.Errorf("%w: question '%s'", ErrNotEnglish, question)
+}
+
+// send posts a message and decodes the robot's reply
+func (c *Client) send(ctx context.Context, message Message) (*Message, error) {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = httpclient.Default
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	var reply Message
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return nil, fmt.Errorf("unexpected response (status %d): %s", resp.StatusCode, string(body))
+	}
+	log.Debug().Int("status", resp.StatusCode).Int("msg_id", reply.MsgID).Str("text", reply.Text).Msg("Robot replied")
+	return &reply, nil
+}
+
+// IsEnglish is a cheap check that text contains no common Polish words and
+// no lower-case words with Polish letters. Capitalized words may carry
+// diacritics, so names such as Kraków or Łódź pass.
+func IsEnglish(text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		lower := strings.ToLower(word)
+		if polishWords[lower] {
+			return false
+		}
+		if first, _ := utf8.DecodeRuneInString(word); !unicode.IsUpper(first) && strings.ContainsAny(lower, polishLetters) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) openTranscript() (*os.File, error) {
+	if c.TranscriptPath == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.TranscriptPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+	file, err := os.Create(c.TranscriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcript: %w", err)
+	}
+	return file, nil
+}
+
+// writeTurn appends a turn to the transcript; a failed write only loses the log line
+func writeTurn(w io.Writer, turn Turn) {
+	if w == nil {
+		return
+	}
+	direction := "robot"
+	if turn.Sent {
+		direction = "us"
+	}
+	if _, err := fmt.Fprintf(w, "%s [%s] msgID=%d: %s\n", turn.Time.Format(time.RFC3339), direction, turn.Message.MsgID, turn.Message.Text); err != nil {
+		log.Warn().Err(err).Msg("Failed to write transcript")
+	}
+}
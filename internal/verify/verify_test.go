@@ -0,0 +1,24 @@
+package verify
+
+import "testing"
+
+func TestIsEnglish(t *testing.T) {
+	tests := []struct {
+		text    string
+		english bool
+	}{
+		{"Kraków", true},
+		{"The capital of Poland is Kraków.", true},
+		{"Łódź", true},
+		{"4", true},
+		{"Stolicą Polski jest Kraków", false},
+		{"stolica to Krakow", false},
+		{"Nie wiem", false},
+		{"  ", false},
+	}
+	for _, test := range tests {
+		if got := IsEnglish(test.text); got != test.english {
+			t.Errorf("IsEnglish(%q) = %v, expected %v", test.text, got, test.english)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"cloud.google.com/go/vertexai/genai"
+	"github.com/dawidjelenkowski/aidevs3go/internal/retry"
 	"github.com/rs/zerolog/log"
 )
 
@@ -15,12 +16,15 @@ type VertexConfig struct {
 	Model    string
 	System   string
 	Prompt   string
+	// MaxOutputTokens limits the reply, 0 keeps the short default
+	MaxOutputTokens int32
 }
 
-// read transcription files and asks Vertex a question
-func AskVertex(config *VertexConfig) (string, error) {
+const defaultMaxOutputTokens = 100
+
+// AskVertex asks a Gemini model on Vertex AI and returns the text of its reply
+func AskVertex(ctx context.Context, config *VertexConfig) (string, error) {
 	log.Debug().Interface("vertex_config", config).Msg("Calling AskVertex with config")
-	ctx := context.Background()
 	client, err := genai.NewClient(ctx, config.Project, config.Location)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create VertexAI client")
@@ -32,10 +36,19 @@ func AskVertex(config *VertexConfig) (string, error) {
 	model.SetTemperature(0.9)
 	model.SetTopP(0.5)
 	model.SetTopK(20)
-	model.SetMaxOutputTokens(100)
+	maxTokens := config.MaxOutputTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxOutputTokens
+	}
+	model.SetMaxOutputTokens(maxTokens)
 	model.SystemInstruction = genai.NewUserContent(genai.Text(config.System))
 	log.Debug().Str("prompt", config.Prompt).Str("system_instruction", config.System).Msg("Sending request to Gemini")
-	result, err := model.GenerateContent(ctx, genai.Text(config.Prompt))
+	// The Vertex client speaks gRPC, so it is retried here rather than by the HTTP transport
+	var result *genai.GenerateContentResponse
+	err = retry.Do(ctx, "vertex", nil, func(ctx context.Context) error {
+		result, err = model.GenerateContent(ctx, genai.Text(config.Prompt))
+		return err
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to generate content")
 		return "", fmt.Errorf("failed to generate content: %w", err)
@@ -50,5 +63,12 @@ func AskVertex(config *VertexConfig) (string, error) {
 	// Log the output.
 	fmt.Println(string(response))
 
-	return string(response), nil
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("Vertex returned no content")
+	}
+	text, ok := result.Candidates[0].Content.Parts[0].(genai.Text)
+	if !ok {
+		return "", fmt.Errorf("Vertex returned no text")
+	}
+	return string(text), nil
 }
@@ -0,0 +1,241 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/ledger"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+// Supported providers, named the same way transcribe names its models
+const (
+	ProviderOpenAI = "openai"
+	ProviderGemini = "gemini"
+)
+
+// Supported modes: OCR-style text extraction or a free-form description
+const (
+	ModeOCR      = "ocr"
+	ModeDescribe = "describe"
+)
+
+const (
+	openAIModel = openai.GPT4o
+	geminiModel = "gemini-2.0-flash-exp"
+)
+
+var prompts = map[string]string{
+	ModeOCR:      "Extract all text visible in this image exactly as written. Preserve the original language, line breaks and numbers. Do not translate, summarize or add any commentary. If there is no text, respond with an empty string.",
+	ModeDescribe: "Describe the content of this image in detail. Mention any visible text, people, objects and the overall context.",
+}
+
+// DetectImageFormat sniffs the real image format from the file header,
+// ignoring the file extension. It returns the MIME type of the image.
+func DetectImageFormat(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png", nil
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "image/jpeg", nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "image/gif", nil
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp", nil
+	}
+
+	// Fall back to the standard library sniffer for anything else
+	mimeType := http.DetectContentType(data)
+	if strings.HasPrefix(mimeType, "image/") {
+		return mimeType, nil
+	}
+	return "", fmt.Errorf("unsupported or non-image content: %s", mimeType)
+}
+
+// IsImageFile checks whether the file at path is an image by reading its header
+func IsImageFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	_, err = DetectImageFormat(header[:n])
+	return err == nil
+}
+
+// ExtractText runs OCR-style text extraction on the image
+func ExtractText(ctx context.Context, apiKey, imagePath, provider string) (string, error) {
+	return AnalyzeImage(ctx, apiKey, imagePath, provider, ModeOCR)
+}
+
+// DescribeImage returns a natural language description of the image
+func DescribeImage(ctx context.Context, apiKey, imagePath, provider string) (string, error) {
+	return AnalyzeImage(ctx, apiKey, imagePath, provider, ModeDescribe)
+}
+
+// AnalyzeImage sends the image to the selected provider using the prompt for the given mode
+func AnalyzeImage(ctx context.Context, apiKey, imagePath, provider, mode string) (string, error) {
+	prompt, ok := prompts[mode]
+	if !ok {
+		return "", fmt.Errorf("unknown vision mode '%s'", mode)
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image '%s': %w", imagePath, err)
+	}
+
+	mimeType, err := DetectImageFormat(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect format of '%s': %w", imagePath, err)
+	}
+	log.Debug().Str("imagePath", imagePath).Str("mimeType", mimeType).Str("provider", provider).Str("mode", mode).Msg("Analyzing image")
+
+	switch provider {
+	case ProviderOpenAI:
+		return openAIVision(ctx, apiKey, prompt, mimeType, data)
+	case ProviderGemini:
+		return geminiVision(ctx, apiKey, prompt, mimeType, data)
+	default:
+		return "", fmt.Errorf("unknown vision provider '%s'", provider)
+	}
+}
+
+// openAIVision sends the image as a base64 data URL to the OpenAI chat API
+func openAIVision(ctx context.Context, apiKey, prompt, mimeType string, data []byte) (string, error) {
+	client := openai.NewClientWithConfig(ledger.OpenAIConfig(apiKey))
+
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: openAIModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{Type: openai.ChatMessagePartTypeText, Text: prompt},
+					{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{
+						URL:    dataURL,
+						Detail: openai.ImageURLDetailHigh,
+					}},
+				},
+			},
+		},
+		Temperature: 0.0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("OpenAI vision request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI vision returned no choices")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// geminiVision sends the image as inline data to Gemini
+func geminiVision(ctx context.Context, apiKey, prompt, mimeType string, data []byte) (string, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGoogleAI,
+		HTTPClient: httpclient.SDK,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	parts := []*genai.Part{
+		{Text: prompt},
+		{InlineData: &genai.Blob{Data: data, MIMEType: mimeType}},
+	}
+	contents := []*genai.Content{{Parts: parts}}
+
+	result, err := client.Models.GenerateContent(ctx, geminiModel, contents, nil)
+	if err != nil {
+		return "", fmt.Errorf("Gemini vision request failed: %w", err)
+	}
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("Gemini vision returned no candidates")
+	}
+
+	return strings.TrimSpace(result.Candidates[0].Content.Parts[0].Text), nil
+}
+
+// cacheFileName returns the name of the cached result for an image,
+// e.g. report-13.png -> report-13.ocr.txt or report-13.describe.txt, so it
+// does not collide with the transcript of report-13.mp3 in the same directory
+func cacheFileName(imageName, mode string) string {
+	baseFileName := strings.TrimSuffix(imageName, filepath.Ext(imageName))
+	return baseFileName + "." + mode + ".txt"
+}
+
+// CachedAnalyzeImage analyzes a single image and saves the result in outputDir.
+// If the result already exists it is returned without calling the provider.
+func CachedAnalyzeImage(ctx context.Context, apiKey, imagePath, outputDir, provider, mode string) (string, error) {
+	outputFilePath := filepath.Join(outputDir, cacheFileName(filepath.Base(imagePath), mode))
+
+	// Check if the result already exists
+	if cached, err := os.ReadFile(outputFilePath); err == nil {
+		log.Info().Str("imagePath", imagePath).Str("outputFilePath", outputFilePath).Msg("Image result already exists, skipping")
+		return string(cached), nil
+	}
+
+	text, err := AnalyzeImage(ctx, apiKey, imagePath, provider, mode)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory '%s': %w", outputDir, err)
+	}
+	if err := os.WriteFile(outputFilePath, []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to save image result '%s': %w", outputFilePath, err)
+	}
+	log.Info().Str("imagePath", imagePath).Str("outputFilePath", outputFilePath).Msg("Image result saved")
+
+	return text, nil
+}
+
+// ProcessImageFiles analyzes every image in the input directory and saves
+// the results to the output directory. Files are detected by their content,
+// so images without an extension (or with a wrong one) are processed too.
+func ProcessImageFiles(ctx context.Context, apiKey, inputDir, outputDir, provider, mode string) error {
+	log.Info().Str("inputDir", inputDir).Str("outputDir", outputDir).Str("mode", mode).Msg("Starting image processing")
+
+	files, err := os.ReadDir(inputDir)
+	if err != nil {
+		return fmt.Errorf("failed to read input directory '%s': %w", inputDir, err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		inputFilePath := filepath.Join(inputDir, file.Name())
+		if !IsImageFile(inputFilePath) {
+			continue
+		}
+
+		if _, err := CachedAnalyzeImage(ctx, apiKey, inputFilePath, outputDir, provider, mode); err != nil {
+			log.Error().Err(err).Str("inputFilePath", inputFilePath).Msg("Failed to process image file")
+			continue
+		}
+	}
+	return nil
+}
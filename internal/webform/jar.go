@@ -0,0 +1,235 @@
+package webform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// storedCookie is a cookie with the host it was received from
+type storedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	HostOnly bool      `json:"host_only"`
+	Path     string    `json:"path"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"http_only,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+}
+
+func (c *storedCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *storedCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// Jar is an http.CookieJar that can be saved to and loaded from a JSON file,
+// so a session survives between runs. Session cookies (without an expiry) are
+// saved as well, since the sites we log into rely on them.
+type Jar struct {
+	path    string
+	mu      sync.Mutex
+	cookies map[string]*storedCookie
+}
+
+// LoadJar opens the jar stored at path; a missing file gives an empty jar.
+// An empty path gives a jar that is never saved.
+func LoadJar(path string) (*Jar, error) {
+	jar := &Jar{path: path, cookies: make(map[string]*storedCookie)}
+	if path == "" {
+		return jar, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie jar: %w", err)
+	}
+
+	var cookies []*storedCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, fmt.Errorf("failed to parse cookie jar %s: %w", path, err)
+	}
+	now := time.Now()
+	for _, cookie := range cookies {
+		if !cookie.expired(now) {
+			jar.cookies[cookie.key()] = cookie
+		}
+	}
+	return jar, nil
+}
+
+// Save writes the unexpired cookies to the jar file, readable only by the owner
+func (j *Jar) Save() error {
+	if j.path == "" {
+		return nil
+	}
+
+	j.mu.Lock()
+	now := time.Now()
+	cookies := make([]*storedCookie, 0, len(j.cookies))
+	for _, cookie := range j.cookies {
+		if !cookie.expired(now) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	j.mu.Unlock()
+
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling cookies: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cookie jar directory: %w", err)
+	}
+	if err := os.WriteFile(j.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+	return nil
+}
+
+// Clear removes all cookies, e.g. when a stored session was rejected
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cookies = make(map[string]*storedCookie)
+}
+
+// SetCookies implements http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := canonicalHost(u.Host)
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := &storedCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   host,
+			HostOnly: true,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HTTPOnly: cookie.HttpOnly,
+		}
+		if domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), "."); domain != "" {
+			// Ignore cookies for domains the response did not come from
+			if !domainMatch(host, domain) {
+				continue
+			}
+			// A public suffix such as "co.uk" or an IP address may only name the
+			// host itself, so the cookie is not shared with unrelated sites
+			if isIP(host) || isPublicSuffix(domain) {
+				if host != domain {
+					continue
+				}
+			} else {
+				stored.Domain, stored.HostOnly = domain, false
+			}
+		}
+		if stored.Path == "" || !strings.HasPrefix(stored.Path, "/") {
+			stored.Path = defaultPath(u.Path)
+		}
+		switch {
+		case cookie.MaxAge < 0:
+			stored.Expires = now
+		case cookie.MaxAge > 0:
+			stored.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		case !cookie.Expires.IsZero():
+			stored.Expires = cookie.Expires
+		}
+
+		if stored.expired(now) {
+			delete(j.cookies, stored.key())
+			continue
+		}
+		j.cookies[stored.key()] = stored
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := canonicalHost(u.Host)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	var cookies []*http.Cookie
+	for key, cookie := range j.cookies {
+		if cookie.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if cookie.HostOnly && host != cookie.Domain || !cookie.HostOnly && !domainMatch(host, cookie.Domain) {
+			continue
+		}
+		if !pathMatch(path, cookie.Path) || cookie.Secure && u.Scheme != "https" {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return cookies
+}
+
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+func isIP(host string) bool {
+	return net.ParseIP(host) != nil
+}
+
+// isPublicSuffix reports whether domain is one under which anyone can register
+// names, using the public suffix list
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultPath is the directory of the request path (RFC 6265 section 5.1.4)
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		return path[:i]
+	}
+	return "/"
+}
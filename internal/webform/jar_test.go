@@ -0,0 +1,73 @@
+package webform
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestJarDomains(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		domain  string
+		sent    []string
+		notSent []string
+	}{
+		{"host only", "http://www.example.com/", "", []string{"http://www.example.com/"}, []string{"http://example.com/", "http://a.www.example.com/"}},
+		{"parent domain", "http://www.example.com/", "example.com", []string{"http://example.com/", "http://api.example.com/"}, []string{"http://example.org/"}},
+		{"unrelated domain", "http://www.example.com/", "example.org", nil, []string{"http://example.org/", "http://www.example.com/"}},
+		{"public suffix", "http://shop.example.co.uk/", "co.uk", nil, []string{"http://other.co.uk/", "http://shop.example.co.uk/"}},
+		{"public suffix as the host", "http://github.io/", "github.io", []string{"http://github.io/"}, []string{"http://user.github.io/"}},
+		{"ip address", "http://127.0.0.1:8080/", "0.0.1", nil, []string{"http://127.0.0.1/"}},
+		{"ip address as the domain", "http://127.0.0.1:8080/", "127.0.0.1", []string{"http://127.0.0.1/"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jar, err := LoadJar("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, _ := url.Parse(test.from)
+			jar.SetCookies(from, []*http.Cookie{{Name: "session", Value: "abc", Domain: test.domain}})
+			for _, target := range test.sent {
+				u, _ := url.Parse(target)
+				if cookies := jar.Cookies(u); len(cookies) != 1 {
+					t.Errorf("expected the cookie to be sent to %s, got %v", target, cookies)
+				}
+			}
+			for _, target := range test.notSent {
+				u, _ := url.Parse(target)
+				if cookies := jar.Cookies(u); len(cookies) != 0 {
+					t.Errorf("expected no cookie for %s, got %v", target, cookies)
+				}
+			}
+		})
+	}
+}
+
+func TestJarSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := LoadJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://example.com/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc"},
+		{Name: "expired", Value: "x", MaxAge: -1},
+	})
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := loaded.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "abc" {
+		t.Fatalf("expected the session cookie after loading, got %v", cookies)
+	}
+}
@@ -0,0 +1,325 @@
+package webform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dawidjelenkowski/aidevs3go/internal/httpclient"
+	"github.com/dawidjelenkowski/aidevs3go/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRedirects matches the limit of the default http.Client
+const maxRedirects = 10
+
+// ErrNoForm is returned when a page has no form matching the request
+var ErrNoForm = errors.New("no matching form on the page")
+
+// Session is an HTTP client with a persistent cookie jar
+type Session struct {
+	Client *http.Client
+	Jar    *Jar
+}
+
+// NewSession creates a session whose cookies are loaded from and saved to jarPath
+func NewSession(jarPath string) (*Session, error) {
+	jar, err := LoadJar(jarPath)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: httpclient.Transport(),
+		Jar:       jar,
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			log.Debug().Str("url", req.URL.String()).Int("hop", len(via)).Msg("Following redirect")
+			return nil
+		},
+	}
+	return &Session{Client: client, Jar: jar}, nil
+}
+
+// Save persists the session cookies
+func (s *Session) Save() error {
+	return s.Jar.Save()
+}
+
+// Page is a fetched HTML page; URL is the final URL after redirects
+type Page struct {
+	URL    *url.URL
+	Status int
+	Body   string
+	Doc    *goquery.Document
+}
+
+// Field is a form input with its default value
+type Field struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// Form is an HTML form with its action resolved against the page URL
+type Form struct {
+	Action *url.URL
+	Method string
+	Fields []Field
+	// Selection is the form element, for reading labels or challenge text
+	Selection *goquery.Selection
+}
+
+// Link is an anchor on a page, resolved against the page URL
+type Link struct {
+	Text string
+	URL  *url.URL
+}
+
+// Fetch loads a page with a GET request
+func (s *Session) Fetch(ctx context.Context, pageURL string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	return s.do(req)
+}
+
+// Download saves the body of fileURL to path with the session's cookies,
+// through a .part file that is renamed once complete
+func (s *Session) Download(ctx context.Context, fileURL, path string) error {
+	if err := utils.DownloadFile(ctx, s.Client, fileURL, path); err != nil {
+		return fmt.Errorf("download of %s failed: %w", fileURL, err)
+	}
+	return nil
+}
+
+func (s *Session) do(req *http.Request) (*Page, error) {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	log.Debug().Str("url", resp.Request.URL.String()).Int("status", resp.StatusCode).Int("length", len(body)).Msg("Page fetched")
+	return &Page{URL: resp.Request.URL, Status: resp.StatusCode, Body: string(body), Doc: doc}, nil
+}
+
+// Forms lists the forms on the page with their inputs
+func (p *Page) Forms() []*Form {
+	var forms []*Form
+	p.Doc.Find("form").Each(func(_ int, selection *goquery.Selection) {
+		form := &Form{
+			Action:    p.resolve(selection.AttrOr("action", "")),
+			Method:    strings.ToUpper(selection.AttrOr("method", http.MethodGet)),
+			Selection: selection,
+		}
+		selection.Find("input, textarea, select").Each(func(_ int, input *goquery.Selection) {
+			name, ok := input.Attr("name")
+			if !ok || name == "" {
+				return
+			}
+			field := Field{Name: name, Type: strings.ToLower(input.AttrOr("type", "text"))}
+			switch goquery.NodeName(input) {
+			case "textarea":
+				field.Type, field.Value = "textarea", input.Text()
+			case "select":
+				field.Type = "select"
+				field.Value = input.Find("option[selected]").AttrOr("value", input.Find("option").First().AttrOr("value", ""))
+			default:
+				if field.Type == "submit" || field.Type == "button" || field.Type == "image" {
+					return
+				}
+				if (field.Type == "checkbox" || field.Type == "radio") && !input.Is("[checked]") {
+					return
+				}
+				field.Value = input.AttrOr("value", "")
+			}
+			form.Fields = append(form.Fields, field)
+		})
+		forms = append(forms, form)
+	})
+	return forms
+}
+
+// LoginForm returns the first form with a password field
+func (p *Page) LoginForm() (*Form, error) {
+	for _, form := range p.Forms() {
+		if form.Field("password") != nil || form.FieldOfType("password") != nil {
+			return form, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no form with a password field at %s", ErrNoForm, p.URL)
+}
+
+// Links lists the anchors on the page
+func (p *Page) Links() []Link {
+	var links []Link
+	p.Doc.Find("a[href]").Each(func(_ int, anchor *goquery.Selection) {
+		href := strings.TrimSpace(anchor.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			return
+		}
+		links = append(links, Link{Text: strings.TrimSpace(anchor.Text()), URL: p.resolve(href)})
+	})
+	return links
+}
+
+func (p *Page) resolve(ref string) *url.URL {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return p.URL
+	}
+	return p.URL.ResolveReference(parsed)
+}
+
+// Field returns the field with the given name, or nil
+func (f *Form) Field(name string) *Field {
+	for i := range f.Fields {
+		if f.Fields[i].Name == name {
+			return &f.Fields[i]
+		}
+	}
+	return nil
+}
+
+// FieldOfType returns the first field of the given input type, or nil
+func (f *Form) FieldOfType(fieldType string) *Field {
+	for i := range f.Fields {
+		if f.Fields[i].Type == fieldType {
+			return &f.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Values returns the form data, with overrides replacing or adding fields
+func (f *Form) Values(overrides map[string]string) url.Values {
+	values := url.Values{}
+	for _, field := range f.Fields {
+		values.Add(field.Name, field.Value)
+	}
+	for name, value := range overrides {
+		values.Set(name, value)
+	}
+	return values
+}
+
+// Submit sends the form and returns the page it leads to, after redirects
+func (s *Session) Submit(ctx context.Context, form *Form, overrides map[string]string) (*Page, error) {
+	values := form.Values(overrides)
+
+	var req *http.Request
+	var err error
+	if form.Method == http.MethodPost {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, form.Action.String(), strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		target := *form.Action
+		target.RawQuery = values.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	log.Debug().Str("action", form.Action.String()).Str("method", form.Method).Int("fields", len(values)).Msg("Submitting form")
+	return s.do(req)
+}
+
+// ChallengeFunc fills the fields of a form that need solving, such as an
+// anti-bot question. It returns the values to submit for those fields.
+type ChallengeFunc func(ctx context.Context, page *Page, form *Form) (map[string]string, error)
+
+// Credentials for a login form. Empty field names are detected from the form.
+type Credentials struct {
+	Username      string
+	Password      string
+	UsernameField string
+	PasswordField string
+}
+
+// Login fetches the page, fills the login form with the credentials and the
+// challenge answers, and submits it. The cookie jar is saved afterwards.
+func (s *Session) Login(ctx context.Context, pageURL string, creds Credentials, challenge ChallengeFunc) (*Page, error) {
+	page, err := s.Fetch(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	form, err := page.LoginForm()
+	if err != nil {
+		return nil, err
+	}
+
+	passwordField := creds.PasswordField
+	if passwordField == "" {
+		passwordField = "password"
+		if field := form.FieldOfType("password"); field != nil {
+			passwordField = field.Name
+		}
+	}
+	usernameField := creds.UsernameField
+	if usernameField == "" {
+		usernameField = guessUsernameField(form, passwordField)
+	}
+	if usernameField == "" {
+		return nil, fmt.Errorf("%w: cannot find the username field", ErrNoForm)
+	}
+
+	values := map[string]string{usernameField: creds.Username, passwordField: creds.Password}
+	if challenge != nil {
+		answers, err := challenge(ctx, page, form)
+		if err != nil {
+			return nil, fmt.Errorf("failed to solve form challenge: %w", err)
+		}
+		for name, value := range answers {
+			values[name] = value
+		}
+	}
+
+	result, err := s.Submit(ctx, form, values)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Save(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// guessUsernameField picks the text input that most likely holds the user name
+func guessUsernameField(form *Form, passwordField string) string {
+	var fallback string
+	for _, field := range form.Fields {
+		if field.Name == passwordField || field.Type != "text" && field.Type != "email" {
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		if strings.Contains(name, "user") || strings.Contains(name, "login") || strings.Contains(name, "email") {
+			return field.Name
+		}
+		if fallback == "" {
+			fallback = field.Name
+		}
+	}
+	return fallback
+}